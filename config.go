@@ -1,41 +1,286 @@
 package goetna
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	gjson "github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is the named set of the ETNA environment URLs.
+type Profile string
+
+const (
+	ProfileLive   Profile = "live"   // production environment
+	ProfileDemo   Profile = "demo"   // demo (paper trading) environment
+	ProfileCustom Profile = "custom" // all URLs are provided explicitly
 )
 
 type EtnaConfig struct {
+	Profile                                Profile
 	RestUrlPub, RestUrlNonRTH, RestUrlPriv string
 	WSUrlPub, WSUrlPubFMP, WSUrlPriv       string
 	RestTimeout                            time.Duration
 	WSPingTimeout                          time.Duration
-	WSMaxSilentPeriod                      int64 // Maximum period of silence, seconds
+	WSMaxSilentPeriod                      time.Duration // maximum period of silence
+	TokenLifetime                          time.Duration // the token's lifetime, it isn't reported by the API
+	UseLocalCert                           bool          // the local CA certificate etna_cacert.pem is trusted
 }
 
-func defaultConfig() *EtnaConfig {
-	_ = godotenv.Load()
+// NewConfig returns the configuration of the environment profile with the default timeouts.
+// The URLs of the ProfileCustom are empty.
+func NewConfig(profile Profile) (*EtnaConfig, error) {
 	cfg := EtnaConfig{
-		RestTimeout: 12000000000, WSPingTimeout: 30, WSMaxSilentPeriod: 7000000000,
-		RestUrlNonRTH: "https://back-dev2.nvbrokerage.com/api/",
+		Profile:     profile,
+		RestTimeout: 12 * time.Second, WSPingTimeout: 7 * time.Second, WSMaxSilentPeriod: 30 * time.Second,
+		TokenLifetime: TokenLifetime,
 	}
-	if isTest, err := strconv.ParseBool(os.Getenv("TEST_ENV")); err != nil || !isTest {
+	switch profile {
+	case ProfileLive:
+		cfg.RestUrlNonRTH = "https://back-dev2.nvbrokerage.com/api/"
 		cfg.RestUrlPub = "https://pub-api-nvb-live-prod.etnasoft.us/api/"
 		cfg.RestUrlPriv = "https://priv-api-nvb-live-prod.etnasoft.us/api/"
 		cfg.WSUrlPub = "wss://md-str-nvb-live-prod.etnasoft.us"
 		cfg.WSUrlPubFMP = "wss://websockets.financialmodelingprep.com"
 		cfg.WSUrlPriv = "wss://oms-str-nvb-live-prod.etnasoft.us"
-	} else {
+	case ProfileDemo:
+		cfg.RestUrlNonRTH = "https://back-dev2.nvbrokerage.com/api/"
 		cfg.RestUrlPub = "https://pub-api-nvb-demo-prod.etnasoft.us/api/"
 		cfg.RestUrlPriv = "https://priv-api-nvb-demo-prod.etnasoft.us/api/"
 		cfg.WSUrlPub = "wss://md-str-nvb-demo-prod.etnasoft.us"
 		cfg.WSUrlPubFMP = "wss://websockets.financialmodelingprep.com"
 		cfg.WSUrlPriv = "wss://oms-str-nvb-demo-prod.etnasoft.us"
+	case ProfileCustom:
+	default:
+		return nil, fmt.Errorf("unknown config profile: %q", profile)
+	}
+	return &cfg, nil
+}
+
+// configFile is the representation of EtnaConfig in YAML and JSON files.
+// The absent fields keep the values of the profile.
+type configFile struct {
+	Profile           Profile `json:"profile" yaml:"profile"`
+	RestUrlPub        *string `json:"rest_url_pub" yaml:"rest_url_pub"`
+	RestUrlNonRTH     *string `json:"rest_url_non_rth" yaml:"rest_url_non_rth"`
+	RestUrlPriv       *string `json:"rest_url_priv" yaml:"rest_url_priv"`
+	WSUrlPub          *string `json:"ws_url_pub" yaml:"ws_url_pub"`
+	WSUrlPubFMP       *string `json:"ws_url_pub_fmp" yaml:"ws_url_pub_fmp"`
+	WSUrlPriv         *string `json:"ws_url_priv" yaml:"ws_url_priv"`
+	RestTimeout       *string `json:"rest_timeout" yaml:"rest_timeout"`
+	WSPingTimeout     *string `json:"ws_ping_timeout" yaml:"ws_ping_timeout"`
+	WSMaxSilentPeriod *string `json:"ws_max_silent_period" yaml:"ws_max_silent_period"`
+	TokenLifetime     *string `json:"token_lifetime" yaml:"token_lifetime"`
+	UseLocalCert      *bool   `json:"use_localcert" yaml:"use_localcert"`
+}
+
+// LoadConfig reads the configuration from the YAML (.yaml, .yml) or JSON (.json) file and validates it.
+// The file's profile (live by default) provides the values of the absent fields.
+// Durations are written as Go duration strings, e.g. "12s".
+func LoadConfig(path string) (*EtnaConfig, error) {
+	var (
+		err  error
+		buf  []byte
+		file configFile
+	)
+	if buf, err = os.ReadFile(path); err != nil {
+		return nil, fmt.Errorf("can't read config: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buf, &file)
+	case ".json":
+		err = gjson.Unmarshal(buf, &file)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't parse config %s: %w", path, err)
+	}
+
+	if file.Profile == "" {
+		file.Profile = ProfileLive
+	}
+	cfg, err := NewConfig(file.Profile)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]*string{
+		"rest_url_pub": file.RestUrlPub, "rest_url_non_rth": file.RestUrlNonRTH, "rest_url_priv": file.RestUrlPriv,
+		"ws_url_pub": file.WSUrlPub, "ws_url_pub_fmp": file.WSUrlPubFMP, "ws_url_priv": file.WSUrlPriv,
+		"rest_timeout": file.RestTimeout, "ws_ping_timeout": file.WSPingTimeout,
+		"ws_max_silent_period": file.WSMaxSilentPeriod, "token_lifetime": file.TokenLifetime,
+	}
+	for name, v := range values {
+		if v == nil {
+			continue
+		} else if err = cfg.set(name, *v); err != nil {
+			return nil, err
+		}
+	}
+	if file.UseLocalCert != nil {
+		cfg.UseLocalCert = *file.UseLocalCert
+	}
+	return cfg, cfg.Validate()
+}
+
+// LoadConfigFromEnv builds the configuration from the environment variables with the prefix, e.g. "ETNA_".
+// The variable <prefix>PROFILE selects the profile (live by default), the others override its values:
+// <prefix>REST_URL_PUB, <prefix>REST_URL_NON_RTH, <prefix>REST_URL_PRIV, <prefix>WS_URL_PUB,
+// <prefix>WS_URL_PUB_FMP, <prefix>WS_URL_PRIV, <prefix>REST_TIMEOUT, <prefix>WS_PING_TIMEOUT,
+// <prefix>WS_MAX_SILENT_PERIOD, <prefix>TOKEN_LIFETIME, <prefix>USE_LOCALCERT. The process environment isn't modified.
+func LoadConfigFromEnv(prefix string) (*EtnaConfig, error) {
+	profile := ProfileLive
+	if v, exist := os.LookupEnv(prefix + "PROFILE"); exist && v != "" {
+		profile = Profile(strings.ToLower(v))
+	}
+	cfg, err := NewConfig(profile)
+	if err != nil {
+		return nil, err
+	} else if err = cfg.ApplyEnv(prefix); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// ApplyEnv overrides the configuration values by the environment variables with the prefix.
+// See LoadConfigFromEnv for the variable names.
+func (c *EtnaConfig) ApplyEnv(prefix string) error {
+	for _, name := range configFields {
+		if v, exist := os.LookupEnv(prefix + strings.ToUpper(name)); exist {
+			if err := c.set(name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var configFields = []string{
+	"rest_url_pub", "rest_url_non_rth", "rest_url_priv", "ws_url_pub", "ws_url_pub_fmp", "ws_url_priv",
+	"rest_timeout", "ws_ping_timeout", "ws_max_silent_period", "token_lifetime", "use_localcert",
+}
+
+// set assigns the textual value to the field with the file/env name.
+func (c *EtnaConfig) set(name, value string) error {
+	var (
+		err error
+		dst *time.Duration
+	)
+	switch name {
+	case "rest_url_pub":
+		(*c).RestUrlPub = value
+	case "rest_url_non_rth":
+		(*c).RestUrlNonRTH = value
+	case "rest_url_priv":
+		(*c).RestUrlPriv = value
+	case "ws_url_pub":
+		(*c).WSUrlPub = value
+	case "ws_url_pub_fmp":
+		(*c).WSUrlPubFMP = value
+	case "ws_url_priv":
+		(*c).WSUrlPriv = value
+	case "rest_timeout":
+		dst = &(*c).RestTimeout
+	case "ws_ping_timeout":
+		dst = &(*c).WSPingTimeout
+	case "ws_max_silent_period":
+		dst = &(*c).WSMaxSilentPeriod
+	case "token_lifetime":
+		dst = &(*c).TokenLifetime
+	case "use_localcert":
+		if (*c).UseLocalCert, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("wrong %s: %w", name, err)
+		}
+	default:
+		return fmt.Errorf("unknown config field: %s", name)
+	}
+	if dst != nil {
+		if *dst, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("wrong %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the URLs and timeouts. The empty URLs are allowed except the REST ones.
+func (c *EtnaConfig) Validate() error {
+	if (*c).RestUrlPub == "" && (*c).RestUrlPriv == "" {
+		return fmt.Errorf("config: REST URL is absent")
+	}
+	urls := []struct {
+		name, value string
+		schemes     []string
+	}{
+		{"RestUrlPub", (*c).RestUrlPub, []string{"https", "http"}},
+		{"RestUrlNonRTH", (*c).RestUrlNonRTH, []string{"https", "http"}},
+		{"RestUrlPriv", (*c).RestUrlPriv, []string{"https", "http"}},
+		{"WSUrlPub", (*c).WSUrlPub, []string{"wss", "ws"}},
+		{"WSUrlPubFMP", (*c).WSUrlPubFMP, []string{"wss", "ws"}},
+		{"WSUrlPriv", (*c).WSUrlPriv, []string{"wss", "ws"}},
 	}
-	return &cfg
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(u.value)
+		if err != nil {
+			return fmt.Errorf("config: wrong %s: %w", u.name, err)
+		} else if parsed.Host == "" || (parsed.Scheme != u.schemes[0] && parsed.Scheme != u.schemes[1]) {
+			return fmt.Errorf("config: wrong %s %q, %s:// URL is expected", u.name, u.value, u.schemes[0])
+		} else if strings.HasPrefix(u.name, "Rest") && !strings.HasSuffix(u.value, "/") {
+			return fmt.Errorf("config: %s must end with a slash: %q", u.name, u.value)
+		}
+	}
+	if (*c).RestTimeout <= 0 {
+		return fmt.Errorf("config: RestTimeout must be positive: %s", (*c).RestTimeout)
+	} else if (*c).WSPingTimeout <= 0 {
+		return fmt.Errorf("config: WSPingTimeout must be positive: %s", (*c).WSPingTimeout)
+	} else if (*c).WSMaxSilentPeriod <= (*c).WSPingTimeout {
+		return fmt.Errorf("config: WSMaxSilentPeriod %s must exceed WSPingTimeout %s",
+			(*c).WSMaxSilentPeriod, (*c).WSPingTimeout)
+	} else if (*c).TokenLifetime != 0 && (*c).TokenLifetime <= TokenRefreshAhead {
+		// zero is the default lifetime for the configs created without NewConfig
+		return fmt.Errorf("config: TokenLifetime %s must exceed %s", (*c).TokenLifetime, TokenRefreshAhead)
+	}
+	return nil
+}
+
+// legacyProfile returns the profile of the legacy constructors, which is selected by the TEST_ENV environment
+// variable: the demo environment if it's true, the live one otherwise. The .env file isn't loaded.
+func legacyProfile() Profile {
+	if isTest, err := strconv.ParseBool(os.Getenv("TEST_ENV")); err == nil && isTest {
+		return ProfileDemo
+	}
+	return ProfileLive
 }
 
-var DefaultConfig = defaultConfig()
+// legacyConfig returns the configuration of the legacy constructors, see legacyProfile. The local CA certificate
+// is trusted if the ETNA_USE_LOCALCERT environment variable is true.
+func legacyConfig() *EtnaConfig {
+	cfg, _ := NewConfig(legacyProfile()) // the profile is known
+	if useCert, err := strconv.ParseBool(os.Getenv("ETNA_USE_LOCALCERT")); err == nil {
+		(*cfg).UseLocalCert = useCert
+	}
+	return cfg
+}
+
+// DefaultConfig is the configuration of the legacy constructors, it's selected by the TEST_ENV
+// and ETNA_USE_LOCALCERT environment variables when the package is initialized.
+//
+// Deprecated: use NewConfig, LoadConfig or LoadConfigFromEnv and pass the configuration to the clients.
+var DefaultConfig = legacyConfig()
+
+// checkConfig validates the configuration passed to the client constructor.
+func checkConfig(cfg *EtnaConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("config is absent")
+	}
+	return cfg.Validate()
+}
@@ -0,0 +1,144 @@
+package goetna
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := map[string]struct {
+		file, content string
+		check         func(cfg *EtnaConfig) bool
+		expectErr     bool
+	}{
+		"yaml": {
+			file: "etna.yaml",
+			content: "profile: demo\nrest_timeout: 5s\nws_url_pub_fmp: wss://fmp.example.com\n" +
+				"use_localcert: true\n",
+			check: func(cfg *EtnaConfig) bool {
+				return (*cfg).Profile == ProfileDemo && (*cfg).RestTimeout == 5*time.Second &&
+					(*cfg).WSUrlPubFMP == "wss://fmp.example.com" && (*cfg).UseLocalCert &&
+					(*cfg).RestUrlPub == "https://pub-api-nvb-demo-prod.etnasoft.us/api/"
+			}},
+		"json_live": {
+			file:    "etna.json",
+			content: `{"token_lifetime": "1h"}`,
+			check: func(cfg *EtnaConfig) bool {
+				return (*cfg).Profile == ProfileLive && (*cfg).TokenLifetime == time.Hour && !(*cfg).UseLocalCert
+			}},
+		"custom": {
+			file:    "etna.yml",
+			content: "profile: custom\nrest_url_priv: https://etna.example.com/api/\n",
+			check: func(cfg *EtnaConfig) bool {
+				return (*cfg).RestUrlPriv == "https://etna.example.com/api/" && (*cfg).WSUrlPub == ""
+			}},
+		"custom_without_rest": {file: "etna.yaml", content: "profile: custom\n", expectErr: true},
+		"unknown_profile":     {file: "etna.yaml", content: "profile: stage\n", expectErr: true},
+		"wrong_duration":      {file: "etna.yaml", content: "rest_timeout: 5\n", expectErr: true},
+		"wrong_format":        {file: "etna.toml", content: "profile = \"demo\"\n", expectErr: true},
+		"invalid":             {file: "etna.json", content: `{"ws_ping_timeout": "1m"}`, expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				(*t).Fatal(err)
+			}
+			cfg, err := LoadConfig(path)
+			if (err != nil) != tc.expectErr {
+				(*t).Fatalf("wrong error: %v", err)
+			} else if err == nil && !tc.check(cfg) {
+				(*t).Errorf("wrong config: %+v", *cfg)
+			}
+		})
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "absent.yaml")); err == nil {
+		(*t).Error("the absent file is loaded")
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	tests := map[string]struct {
+		env       map[string]string
+		check     func(cfg *EtnaConfig) bool
+		expectErr bool
+	}{
+		"default": {
+			check: func(cfg *EtnaConfig) bool {
+				return (*cfg).Profile == ProfileLive && (*cfg).RestTimeout == 12*time.Second
+			}},
+		"overrides": {
+			env: map[string]string{"GE_PROFILE": "DEMO", "GE_WS_PING_TIMEOUT": "3s", "GE_USE_LOCALCERT": "1",
+				"GE_REST_URL_NON_RTH": "http://localhost:8080/api/"},
+			check: func(cfg *EtnaConfig) bool {
+				return (*cfg).Profile == ProfileDemo && (*cfg).WSPingTimeout == 3*time.Second && (*cfg).UseLocalCert &&
+					(*cfg).RestUrlNonRTH == "http://localhost:8080/api/"
+			}},
+		"unknown_profile":    {env: map[string]string{"GE_PROFILE": "stage"}, expectErr: true},
+		"wrong_localcert":    {env: map[string]string{"GE_USE_LOCALCERT": "sure"}, expectErr: true},
+		"wrong_url":          {env: map[string]string{"GE_WS_URL_PUB": "https://md.example.com"}, expectErr: true},
+		"wrong_silent_limit": {env: map[string]string{"GE_WS_MAX_SILENT_PERIOD": "1s"}, expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := LoadConfigFromEnv("GE_")
+			if (err != nil) != tc.expectErr {
+				(*t).Fatalf("wrong error: %v", err)
+			} else if err == nil && !tc.check(cfg) {
+				(*t).Errorf("wrong config: %+v", *cfg)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		update    func(cfg *EtnaConfig)
+		expectErr bool
+	}{
+		"valid":             {update: func(cfg *EtnaConfig) {}},
+		"private_rest_only": {update: func(cfg *EtnaConfig) { (*cfg).RestUrlPub = "" }},
+		"no_rest": {
+			update:    func(cfg *EtnaConfig) { (*cfg).RestUrlPub, (*cfg).RestUrlPriv = "", "" },
+			expectErr: true},
+		"no_slash": {
+			update:    func(cfg *EtnaConfig) { (*cfg).RestUrlPub = "https://etna.example.com/api" },
+			expectErr: true},
+		"wrong_scheme": {
+			update:    func(cfg *EtnaConfig) { (*cfg).WSUrlPriv = "https://oms.example.com" },
+			expectErr: true},
+		"no_host": {
+			update:    func(cfg *EtnaConfig) { (*cfg).RestUrlPriv = "https:///api/" },
+			expectErr: true},
+		"no_rest_timeout": {
+			update:    func(cfg *EtnaConfig) { (*cfg).RestTimeout = 0 },
+			expectErr: true},
+		"no_ping_timeout": {
+			update:    func(cfg *EtnaConfig) { (*cfg).WSPingTimeout = 0 },
+			expectErr: true},
+		"short_silent_period": {
+			update:    func(cfg *EtnaConfig) { (*cfg).WSMaxSilentPeriod = (*cfg).WSPingTimeout },
+			expectErr: true},
+		"default_token_lifetime": {update: func(cfg *EtnaConfig) { (*cfg).TokenLifetime = 0 }},
+		"short_token_lifetime": {
+			update:    func(cfg *EtnaConfig) { (*cfg).TokenLifetime = TokenRefreshAhead },
+			expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			cfg, err := NewConfig(ProfileDemo)
+			if err != nil {
+				(*t).Fatal(err)
+			}
+			tc.update(cfg)
+			if err = cfg.Validate(); (err != nil) != tc.expectErr {
+				(*t).Errorf("wrong error: %v", err)
+			}
+		})
+	}
+}
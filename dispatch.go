@@ -0,0 +1,295 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy defines what the stream does with the message when its queue is full. The policy applies
+// to the legacy channel (e.g. QuotesChan) as well, while the stream has no handler: the full channel
+// blocks the stream, drops the incoming message or, for OverflowDropOldest and OverflowCoalesce, drops
+// the oldest message of the channel, since the channel can't be coalesced by the key.
+type OverflowPolicy uint8
+
+const (
+	OverflowBlock      OverflowPolicy = iota // wait for the free space, it stalls the socket reading
+	OverflowDropOldest                       // drop the oldest queued message
+	OverflowDropNewest                       // drop the incoming message
+	OverflowCoalesce                         // keep only the latest message per key (e.g. per symbol), the queue is unbounded by keys
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowCoalesce:
+		return "Coalesce"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", p)
+}
+
+// RawMessage is the WebSocket message as received, before decoding.
+type RawMessage struct {
+	Topic string
+	Data  []byte
+}
+
+// stream delivers the messages of one kind to the handlers in its own goroutine, so a slow consumer
+// of one stream doesn't delay the others. While no handler is registered the messages are passed
+// to the channel adapter, if any.
+type stream[T any] struct {
+	name     string
+	policy   OverflowPolicy
+	keyFn    func(T) string // the coalescing key
+	queue    chan envelope[T]
+	mu       sync.Mutex
+	handlers []func(T)
+	channel  chanDelivery[T]        // the adapter to the legacy channel
+	pending  map[string]envelope[T] // the coalesced messages
+	order    []string               // the keys of the coalesced messages in the arrival order
+	wake     chan struct{}
+	stats    *streamMetrics
+	started  atomic.Bool
+	draining chan struct{} // closed to dispatch the queued messages and finish
+	drainMu  sync.Once
+	done     chan struct{} // closed when run finishes
+}
+
+// envelope is the queued message with its receive time.
+type envelope[T any] struct {
+	v  T
+	ts int64
+}
+
+func newStream[T any](name string, size int, keyFn func(T) string) *stream[T] {
+	return &stream[T]{name: name, keyFn: keyFn, queue: make(chan envelope[T], size),
+		pending: make(map[string]envelope[T]), wake: make(chan struct{}, 1), stats: newStreamMetrics(),
+		draining: make(chan struct{}), done: make(chan struct{})}
+}
+
+// setPolicy changes the overflow policy and the queue size, it must be called before run.
+func (s *stream[T]) setPolicy(policy OverflowPolicy, size int) error {
+	if (*s).started.Load() {
+		return fmt.Errorf("stream %s is already running", (*s).name)
+	} else if policy == OverflowCoalesce && (*s).keyFn == nil {
+		return fmt.Errorf("stream %s can't be coalesced", (*s).name)
+	} else if size <= 0 {
+		return fmt.Errorf("wrong queue size of stream %s: %d", (*s).name, size)
+	}
+	(*s).policy = policy
+	(*s).queue = make(chan envelope[T], size)
+	return nil
+}
+
+func (s *stream[T]) subscribe(h func(T)) {
+	(*s).mu.Lock()
+	(*s).handlers = append((*s).handlers, h)
+	(*s).mu.Unlock()
+}
+
+// active reports whether the stream has any handler or the channel adapter.
+func (s *stream[T]) active() bool {
+	(*s).mu.Lock()
+	defer (*s).mu.Unlock()
+	return len((*s).handlers) > 0 || (*s).channel != nil
+}
+
+func (s *stream[T]) metrics() *streamMetrics {
+	return (*s).stats
+}
+
+// occupancy returns the number of the queued messages and the queue capacity.
+func (s *stream[T]) occupancy() (int, int) {
+	if (*s).policy == OverflowCoalesce {
+		(*s).mu.Lock()
+		defer (*s).mu.Unlock()
+		return len((*s).order), 0
+	}
+	return len((*s).queue), cap((*s).queue)
+}
+
+// push queues the message according to the policy. It returns false if a message was dropped.
+func (s *stream[T]) push(ctx context.Context, v T) bool {
+	env := envelope[T]{v: v, ts: (*s).stats.lastRecv.Load()}
+	if env.ts == 0 {
+		env.ts = time.Now().UnixNano()
+	}
+	defer func() { (*s).stats.observeQueue((*s).occupancy()) }()
+
+	switch (*s).policy {
+	case OverflowDropNewest:
+		select {
+		case (*s).queue <- env:
+			return true
+		default:
+			(*s).stats.dropped.Add(1)
+			return false
+		}
+	case OverflowDropOldest:
+		// the stream has the single producer, so the freed slot can only be taken by this message,
+		// unless the worker has read the queue in the meantime
+		select {
+		case (*s).queue <- env:
+			return true
+		default:
+		}
+		select {
+		case <-(*s).queue:
+			(*s).stats.dropped.Add(1)
+		default:
+		}
+		select {
+		case (*s).queue <- env:
+		default:
+			(*s).stats.dropped.Add(1)
+		}
+		return false
+	case OverflowCoalesce:
+		key := (*s).keyFn(v)
+		(*s).mu.Lock()
+		_, exist := (*s).pending[key]
+		if !exist {
+			(*s).order = append((*s).order, key)
+		}
+		(*s).pending[key] = env
+		(*s).mu.Unlock()
+		select {
+		case (*s).wake <- struct{}{}:
+		default:
+		}
+		if exist {
+			(*s).stats.dropped.Add(1)
+		}
+		return !exist
+	}
+
+	select {
+	case (*s).queue <- env:
+		return true
+	default:
+	}
+	// the queue is full, the receiver is blocked by the slow consumer
+	(*s).stats.pushBlockedSince.Store(time.Now().UnixNano())
+	defer (*s).stats.pushBlockedSince.Store(0)
+	select {
+	case (*s).queue <- env:
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+// run dispatches the queued messages until the context is cancelled or the stream is drained.
+func (s *stream[T]) run(ctx context.Context, logger Logger) {
+	(*s).started.Store(true)
+	defer close((*s).done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-(*s).draining:
+			// the stream is the single reader of the queue
+			for len((*s).queue) > 0 {
+				(*s).dispatch(ctx, <-(*s).queue, logger)
+			}
+			(*s).dispatchPending(ctx, logger)
+			return
+		case env := <-(*s).queue:
+			(*s).dispatch(ctx, env, logger)
+		case <-(*s).wake:
+			(*s).dispatchPending(ctx, logger)
+		}
+	}
+}
+
+// dispatchPending dispatches the coalesced messages.
+func (s *stream[T]) dispatchPending(ctx context.Context, logger Logger) {
+	(*s).mu.Lock()
+	pending, order := (*s).pending, (*s).order
+	(*s).pending, (*s).order = make(map[string]envelope[T], len(pending)), nil
+	(*s).mu.Unlock()
+	for _, key := range order {
+		(*s).dispatch(ctx, pending[key], logger)
+	}
+}
+
+// drain makes run dispatch the queued messages and finish, the returned channel is closed when it's finished.
+func (s *stream[T]) drain() <-chan struct{} {
+	(*s).drainMu.Do(func() { close((*s).draining) })
+	return (*s).done
+}
+
+func (s *stream[T]) dispatch(ctx context.Context, env envelope[T], logger Logger) {
+	(*s).mu.Lock()
+	handlers, channel := (*s).handlers, (*s).channel
+	(*s).mu.Unlock()
+
+	(*s).stats.dispatchSince.Store(time.Now().UnixNano())
+	defer (*s).stats.dispatched(env.ts)
+	if len(handlers) == 0 {
+		if channel != nil && !channel(ctx, env.v, (*s).policy) {
+			(*s).stats.dropped.Add(1)
+		}
+		return
+	}
+	for _, h := range handlers {
+		(*s).call(h, env.v, logger)
+	}
+}
+
+func (s *stream[T]) call(h func(T), v T, logger Logger) {
+	defer func() {
+		if errMsg := recover(); errMsg != nil {
+			logger.Error("%s handler got panic: %+v\n%s", (*s).name, errMsg, debug.Stack())
+		}
+	}()
+	h(v)
+}
+
+// chanDelivery passes the message to the legacy channel according to the policy of the stream,
+// it returns false if a message was dropped.
+type chanDelivery[T any] func(ctx context.Context, v T, policy OverflowPolicy) bool
+
+// chanAdapter returns the channel adapter, see OverflowPolicy. With OverflowBlock it waits until the message
+// is read or the context is cancelled.
+func chanAdapter[T any](ch chan T) chanDelivery[T] {
+	return func(ctx context.Context, v T, policy OverflowPolicy) bool {
+		if policy == OverflowBlock {
+			select {
+			case ch <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		select {
+		case ch <- v:
+			return true
+		default:
+		}
+		if policy == OverflowDropNewest {
+			return false
+		}
+		// the consumer may read the channel in the meantime, then the message takes the freed slot
+		dropped := false
+		select {
+		case <-ch:
+			dropped = true
+		default:
+		}
+		select {
+		case ch <- v:
+			return !dropped
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// collect runs the stream until the queued messages are dispatched and returns them.
+func collect(s *stream[int]) []int {
+	var res []int
+	s.subscribe(func(v int) { res = append(res, v) })
+	go s.run(context.Background(), NopLogger{})
+	<-s.drain()
+	return res
+}
+
+func TestStreamOverflow(t *testing.T) {
+	tests := map[string]struct {
+		policy  OverflowPolicy
+		expect  string
+		dropped uint64
+	}{
+		"block":       {policy: OverflowBlock, expect: "[1 2]"},
+		"drop_oldest": {policy: OverflowDropOldest, expect: "[3 4]", dropped: 2},
+		"drop_newest": {policy: OverflowDropNewest, expect: "[1 2]", dropped: 2},
+		"coalesce":    {policy: OverflowCoalesce, expect: "[3 4]", dropped: 2},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			s := newStream("test", 2, func(v int) string { return strconv.Itoa(v % 2) })
+			if err := s.setPolicy(tc.policy, 2); err != nil {
+				(*t).Fatal(err)
+			}
+			// the blocked message is abandoned by the cancelled context
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			for v := 1; v <= 4; v++ {
+				s.push(ctx, v)
+			}
+			if res := fmt.Sprint(collect(s)); res != tc.expect {
+				(*t).Errorf("wrong messages: %s", res)
+			} else if dropped := s.metrics().dropped.Load(); dropped != tc.dropped {
+				(*t).Errorf("wrong number of the dropped messages: %d", dropped)
+			}
+		})
+	}
+}
+
+func TestChanAdapter(t *testing.T) {
+	tests := map[string]struct {
+		policy OverflowPolicy
+		expect string
+	}{
+		"block":       {policy: OverflowBlock, expect: "[1 2]"},
+		"drop_oldest": {policy: OverflowDropOldest, expect: "[2 3]"},
+		"drop_newest": {policy: OverflowDropNewest, expect: "[1 2]"},
+		"coalesce":    {policy: OverflowCoalesce, expect: "[2 3]"},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			ch := make(chan int, 2)
+			deliver := chanAdapter(ch)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if !deliver(ctx, 1, tc.policy) || !deliver(ctx, 2, tc.policy) {
+				(*t).Fatal("the message isn't delivered to the free channel")
+			}
+			cancel()
+			if deliver(ctx, 3, tc.policy) {
+				(*t).Error("the message is delivered to the full channel")
+			}
+			close(ch)
+			var res []int
+			for v := range ch {
+				res = append(res, v)
+			}
+			if fmt.Sprint(res) != tc.expect {
+				(*t).Errorf("wrong messages: %v", res)
+			}
+		})
+	}
+}
+
+func TestStreamChannelOverflow(t *testing.T) {
+	ch := make(chan int, 1)
+	s := newStream("test", 10, func(v int) string { return strconv.Itoa(v) })
+	(*s).channel = chanAdapter(ch)
+	if err := s.setPolicy(OverflowDropNewest, 10); err != nil {
+		(*t).Fatal(err)
+	}
+	for v := 1; v <= 3; v++ {
+		s.push(context.Background(), v)
+	}
+	go s.run(context.Background(), NopLogger{})
+	<-s.drain()
+
+	// the stream isn't blocked by the full channel, the messages are dropped according to the policy
+	if v := <-ch; v != 1 {
+		(*t).Errorf("wrong message: %d", v)
+	} else if dropped := s.metrics().dropped.Load(); dropped != 2 {
+		(*t).Errorf("wrong number of the dropped messages: %d", dropped)
+	}
+}
@@ -0,0 +1,118 @@
+package goetna
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// Sentinel errors to classify the API failures with errors.Is.
+var (
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrNotFound      = errors.New("not found")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrOrderRejected = errors.New("order rejected")
+	ErrServer        = errors.New("server error")
+)
+
+// Sentinel errors of the WebSocket subscriptions, see SubscriptionError.
+var (
+	ErrAckTimeout           = errors.New("acknowledgement timeout")
+	ErrSubscriptionRejected = errors.New("subscription rejected")
+	ErrSubscriptionCanceled = errors.New("subscription canceled")
+)
+
+// APIError describes a failed REST request.
+// It matches the sentinel errors above, so the callers can use errors.Is(err, ErrNotFound) etc.
+type APIError struct {
+	StatusCode int              // HTTP status code
+	Status     string           // HTTP status text, e.g. "400 Bad Request"
+	Method     string           // HTTP method of the request
+	Endpoint   string           // API endpoint without the base URL and query
+	Response   sch.EtnaResponse // decoded error response, if any
+	SFA        sch.SFA          // decoded authentication response, if any
+	Body       []byte           // raw response body
+	RetryAfter time.Duration    // the value of the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s: %s", e.Method, e.Endpoint, e.Status)
+	if reason := e.reason(); reason != "" {
+		fmt.Fprintf(&b, ", %s", reason)
+	}
+	return b.String()
+}
+
+// Is reports whether the error belongs to the class of the target sentinel error.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || (e.SFA.State != "" && e.SFA.State != "Succeeded")
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrOrderRejected:
+		return isOrderMutation(e.Method, e.Endpoint) && (e.StatusCode == http.StatusBadRequest ||
+			e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusUnprocessableEntity)
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// reason returns the most descriptive message of the decoded response.
+func (e *APIError) reason() string {
+	switch {
+	case e.Response.Message != "":
+		return e.Response.Message
+	case e.Response.Reason != "":
+		return strings.TrimSpace(fmt.Sprintf("%s %s %s", e.Response.State, e.Response.Step, e.Response.Reason))
+	case e.SFA.Reason != "" || e.SFA.Error != "":
+		return strings.TrimSpace(fmt.Sprintf("%s %s %s%s", e.SFA.State, e.SFA.Step, e.SFA.Reason, e.SFA.Error))
+	case e.SFA.State != "":
+		return e.SFA.State
+	}
+	return ""
+}
+
+// SubscriptionError describes the failed (un)subscription request of a key.
+// It wraps ErrAckTimeout, ErrSubscriptionRejected, ErrSubscriptionCanceled or ErrClosed.
+type SubscriptionError struct {
+	Op      string // "subscribe" or "unsubscribe"
+	Topic   string // the topic of the subscription, e.g. sch.WSTopicQuote
+	Key     string // the subscription key
+	Status  int    // the status of the rejection, if any
+	Message string // the message of the rejection, if any
+	Err     error
+}
+
+func (e *SubscriptionError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s %s: %v", e.Op, e.Topic, e.Key, e.Err)
+	if e.Status != 0 || e.Message != "" {
+		fmt.Fprintf(&b, ", %d %s", e.Status, e.Message)
+	}
+	return b.String()
+}
+
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// isOrderEndpoint reports whether the endpoint belongs to the orders API.
+func isOrderEndpoint(endpoint string) bool {
+	return strings.Contains(endpoint, "/orders")
+}
+
+// isOrderMutation reports whether the request places or replaces the order, the queries aren't included.
+func isOrderMutation(method, endpoint string) bool {
+	return (method == http.MethodPost || method == http.MethodPut) && isOrderEndpoint(endpoint)
+}
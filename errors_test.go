@@ -0,0 +1,98 @@
+package goetna
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := map[string]struct {
+		err    APIError
+		target error
+		expect bool
+	}{
+		"unauthorized_status": {
+			err: APIError{StatusCode: 401, Method: http.MethodGet, Endpoint: "v1.0/users/@me"}, target: ErrUnauthorized,
+			expect: true},
+		"unauthorized_sfa": {
+			err: APIError{StatusCode: 200, Method: http.MethodPost, Endpoint: "token",
+				SFA: sch.SFA{State: "Failed", Reason: "wrong password"}},
+			target: ErrUnauthorized, expect: true},
+		"succeeded_sfa": {
+			err:    APIError{StatusCode: 500, Method: http.MethodPost, Endpoint: "token", SFA: sch.SFA{State: "Succeeded"}},
+			target: ErrUnauthorized},
+		"no_sfa": {
+			err:    APIError{StatusCode: 403, Method: http.MethodGet, Endpoint: "v1.0/users/@me"},
+			target: ErrUnauthorized},
+		"place_rejected": {
+			err:    APIError{StatusCode: 400, Method: http.MethodPost, Endpoint: "v1.0/accounts/1/orders"},
+			target: ErrOrderRejected, expect: true},
+		"replace_conflict": {
+			err:    APIError{StatusCode: 409, Method: http.MethodPut, Endpoint: "v1.0/accounts/1/orders/7"},
+			target: ErrOrderRejected, expect: true},
+		"place_unprocessable": {
+			err:    APIError{StatusCode: 422, Method: http.MethodPost, Endpoint: "v1.0/accounts/1/orders"},
+			target: ErrOrderRejected, expect: true},
+		"place_server_error": {
+			err:    APIError{StatusCode: 500, Method: http.MethodPost, Endpoint: "v1.0/accounts/1/orders"},
+			target: ErrOrderRejected},
+		"place_not_found": {
+			err:    APIError{StatusCode: 404, Method: http.MethodPost, Endpoint: "v1.0/accounts/1/orders"},
+			target: ErrOrderRejected},
+		"query_orders": {
+			err:    APIError{StatusCode: 400, Method: http.MethodGet, Endpoint: "v1.0/accounts/1/orders"},
+			target: ErrOrderRejected},
+		"cancel_order": {
+			err:    APIError{StatusCode: 400, Method: http.MethodDelete, Endpoint: "v1.0/accounts/1/orders/7"},
+			target: ErrOrderRejected},
+		"other_endpoint": {
+			err:    APIError{StatusCode: 400, Method: http.MethodPost, Endpoint: "v1.0/accounts/1/transfers"},
+			target: ErrOrderRejected},
+		"not_found":    {err: APIError{StatusCode: 404}, target: ErrNotFound, expect: true},
+		"rate_limited": {err: APIError{StatusCode: 429}, target: ErrRateLimited, expect: true},
+		"server":       {err: APIError{StatusCode: 503}, target: ErrServer, expect: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := errors.Is(&tc.err, tc.target); res != tc.expect {
+				(*t).Errorf("wrong match of %v: %t", tc.target, res)
+			}
+		})
+	}
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	srv := httptest.NewServer(respond(`{"State":"Failed","Step":"Password","Reason":"wrong password"}`))
+	defer srv.Close()
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	login := []byte(base64.StdEncoding.EncodeToString([]byte("login")))
+	if _, err = New(WithEtnaConfig(cfg), WithBaseURL((*srv).URL+"/"), WithCredentials(login, login),
+		WithRetryPolicy(nil)); !errors.Is(err, ErrUnauthorized) {
+		(*t).Errorf("the failed authentication isn't unauthorized: %v", err)
+	}
+
+	ctx := context.Background()
+	rejected := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"Message":"not enough buying power"}`))
+	}
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"POST /v1.0/accounts/1/orders": rejected,
+		"GET /v1.0/accounts/1/orders":  rejected,
+	})
+	params := &sch.OrderParams{ClientId: "c1", Symbol: "AAPL", Side: sch.SideBuy, Type: sch.OrderMarket, Quantity: 1}
+	if _, err := rest.PlaceOrder(ctx, 1, params); !errors.Is(err, ErrOrderRejected) {
+		(*t).Errorf("the order isn't rejected: %v", err)
+	} else if _, err = rest.OrdersIter(ctx, 1, nil).All(); err == nil || errors.Is(err, ErrOrderRejected) {
+		(*t).Errorf("the failed query is the rejected order: %v", err)
+	}
+}
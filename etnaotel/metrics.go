@@ -0,0 +1,131 @@
+// Package etnaotel exports the metrics of the goetna clients to OpenTelemetry.
+//
+//	metrics, err := etnaotel.NewMetrics(otel.GetMeterProvider())
+//	rest, err := goetna.New(goetna.WithInstrumentation(metrics), ...)
+//	ws.SetInstrumentation(metrics)
+package etnaotel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/long-js/goetna"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ScopeName is the instrumentation scope of the meter and the tracer.
+const ScopeName = "github.com/long-js/goetna"
+
+var _ goetna.Instrumentation = (*Metrics)(nil)
+
+// Metrics implements goetna.Instrumentation with the OpenTelemetry instruments.
+type Metrics struct {
+	requests      metric.Int64Counter
+	latency       metric.Float64Histogram
+	retries       metric.Int64Counter
+	authRefreshes metric.Int64Counter
+	reconnects    metric.Int64Counter
+	messages      metric.Int64Counter
+
+	mu            sync.Mutex
+	connectedAt   map[string]time.Time // the connection time of the connected clients
+	subscriptions map[[2]string]int64  // client, topic -> count
+}
+
+// NewMetrics creates the instruments using the meter provider.
+func NewMetrics(mp metric.MeterProvider) (*Metrics, error) {
+	var (
+		err   error
+		m     = Metrics{connectedAt: make(map[string]time.Time), subscriptions: make(map[[2]string]int64)}
+		meter = mp.Meter(ScopeName)
+	)
+	if m.requests, err = meter.Int64Counter("etna.rest.requests",
+		metric.WithDescription("The number of REST requests by endpoint and response status.")); err != nil {
+		return nil, err
+	} else if m.latency, err = meter.Float64Histogram("etna.rest.duration", metric.WithUnit("s"),
+		metric.WithDescription("The latency of REST requests.")); err != nil {
+		return nil, err
+	} else if m.retries, err = meter.Int64Counter("etna.rest.retries",
+		metric.WithDescription("The number of repeated REST requests.")); err != nil {
+		return nil, err
+	} else if m.authRefreshes, err = meter.Int64Counter("etna.rest.auth_refreshes",
+		metric.WithDescription("The number of authentications by result.")); err != nil {
+		return nil, err
+	} else if m.reconnects, err = meter.Int64Counter("etna.ws.reconnects",
+		metric.WithDescription("The number of WebSocket reconnection attempts.")); err != nil {
+		return nil, err
+	} else if m.messages, err = meter.Int64Counter("etna.ws.messages",
+		metric.WithDescription("The number of received WebSocket messages by topic.")); err != nil {
+		return nil, err
+	}
+
+	uptime, err := meter.Float64ObservableGauge("etna.ws.uptime", metric.WithUnit("s"),
+		metric.WithDescription("The duration of the current WebSocket connection."))
+	if err != nil {
+		return nil, err
+	}
+	subs, err := meter.Int64ObservableGauge("etna.ws.subscriptions",
+		metric.WithDescription("The number of active WebSocket subscriptions by topic."))
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for client, ts := range m.connectedAt {
+			o.ObserveFloat64(uptime, time.Since(ts).Seconds(), metric.WithAttributes(attribute.String("client", client)))
+		}
+		for key, count := range m.subscriptions {
+			o.ObserveInt64(subs, count, metric.WithAttributes(
+				attribute.String("client", key[0]), attribute.String("topic", key[1])))
+		}
+		return nil
+	}, uptime, subs)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Metrics) RequestDone(method, endpoint string, status int, latency time.Duration) {
+	ctx := context.Background()
+	attrs := metric.WithAttributes(attribute.String("method", method), attribute.String("endpoint", endpoint))
+	(*m).requests.Add(ctx, 1, attrs, metric.WithAttributes(attribute.Int("status", status)))
+	(*m).latency.Record(ctx, latency.Seconds(), attrs)
+}
+
+func (m *Metrics) RequestRetried(method, endpoint string, attempt int) {
+	(*m).retries.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("method", method), attribute.String("endpoint", endpoint)))
+}
+
+func (m *Metrics) AuthRefreshed(err error) {
+	(*m).authRefreshes.Add(context.Background(), 1, metric.WithAttributes(attribute.Bool("success", err == nil)))
+}
+
+func (m *Metrics) Reconnecting(client string, attempt int) {
+	(*m).reconnects.Add(context.Background(), 1, metric.WithAttributes(attribute.String("client", client)))
+}
+
+func (m *Metrics) ConnStateChanged(client string, connected bool) {
+	(*m).mu.Lock()
+	if connected {
+		(*m).connectedAt[client] = time.Now()
+	} else {
+		delete((*m).connectedAt, client)
+	}
+	(*m).mu.Unlock()
+}
+
+func (m *Metrics) SubscriptionsChanged(client, topic string, count int) {
+	(*m).mu.Lock()
+	(*m).subscriptions[[2]string{client, topic}] = int64(count)
+	(*m).mu.Unlock()
+}
+
+func (m *Metrics) MessageReceived(client, topic string) {
+	(*m).messages.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("client", client), attribute.String("topic", topic)))
+}
@@ -0,0 +1,152 @@
+package etnaotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// recordMeter records the values of the instruments by "name{attributes}".
+type recordMeter struct {
+	noop.Meter
+	mu       sync.Mutex
+	values   map[string]float64
+	callback metric.Callback
+}
+
+type recordProvider struct {
+	noop.MeterProvider
+	meter *recordMeter
+}
+
+func (p recordProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+func (m *recordMeter) add(name string, attrs attribute.Set, v float64) {
+	(*m).mu.Lock()
+	defer (*m).mu.Unlock()
+	(*m).values[name+"{"+attrs.Encoded(attribute.DefaultEncoder())+"}"] += v
+}
+
+func (m *recordMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return recordCounter{meter: m, name: name}, nil
+}
+
+func (m *recordMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram,
+	error) {
+	return recordHistogram{meter: m, name: name}, nil
+}
+
+func (m *recordMeter) Int64ObservableGauge(name string, _ ...metric.Int64ObservableGaugeOption) (
+	metric.Int64ObservableGauge, error) {
+	return recordInt64Gauge{name: name}, nil
+}
+
+func (m *recordMeter) Float64ObservableGauge(name string, _ ...metric.Float64ObservableGaugeOption) (
+	metric.Float64ObservableGauge, error) {
+	return recordFloat64Gauge{name: name}, nil
+}
+
+func (m *recordMeter) RegisterCallback(f metric.Callback, _ ...metric.Observable) (metric.Registration, error) {
+	(*m).callback = f
+	return noop.Registration{}, nil
+}
+
+// observe calls the registered callback.
+func (m *recordMeter) observe(t *testing.T) {
+	if err := (*m).callback(context.Background(), recordObserver{meter: m}); err != nil {
+		(*t).Fatal(err)
+	}
+}
+
+type recordCounter struct {
+	noop.Int64Counter
+	meter *recordMeter
+	name  string
+}
+
+func (c recordCounter) Add(_ context.Context, incr int64, opts ...metric.AddOption) {
+	c.meter.add(c.name, metric.NewAddConfig(opts).Attributes(), float64(incr))
+}
+
+type recordHistogram struct {
+	noop.Float64Histogram
+	meter *recordMeter
+	name  string
+}
+
+func (h recordHistogram) Record(_ context.Context, _ float64, opts ...metric.RecordOption) {
+	h.meter.add(h.name, metric.NewRecordConfig(opts).Attributes(), 1)
+}
+
+type recordInt64Gauge struct {
+	noop.Int64ObservableGauge
+	name string
+}
+
+type recordFloat64Gauge struct {
+	noop.Float64ObservableGauge
+	name string
+}
+
+type recordObserver struct {
+	noop.Observer
+	meter *recordMeter
+}
+
+func (o recordObserver) ObserveFloat64(obsrv metric.Float64Observable, v float64, opts ...metric.ObserveOption) {
+	o.meter.add(obsrv.(recordFloat64Gauge).name, metric.NewObserveConfig(opts).Attributes(), v)
+}
+
+func (o recordObserver) ObserveInt64(obsrv metric.Int64Observable, v int64, opts ...metric.ObserveOption) {
+	o.meter.add(obsrv.(recordInt64Gauge).name, metric.NewObserveConfig(opts).Attributes(), float64(v))
+}
+
+func TestMetrics(t *testing.T) {
+	meter := &recordMeter{values: make(map[string]float64)}
+	m, err := NewMetrics(recordProvider{meter: meter})
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	m.RequestDone("GET", "v1.0/accounts/{id}/orders", 200, time.Millisecond)
+	m.RequestDone("GET", "v1.0/accounts/{id}/orders", 200, time.Millisecond)
+	m.RequestRetried("POST", "v1.0/accounts/{id}/orders", 2)
+	m.AuthRefreshed(errors.New("failed"))
+	m.Reconnecting("md", 1)
+	m.MessageReceived("md", "Quote")
+	m.ConnStateChanged("md", true)
+	m.ConnStateChanged("oms", true)
+	m.ConnStateChanged("oms", false)
+	m.SubscriptionsChanged("md", "Quote", 3)
+	m.SubscriptionsChanged("md", "Quote", 2)
+	meter.observe(t)
+
+	expect := map[string]float64{
+		"etna.rest.requests{endpoint=v1.0/accounts/{id}/orders,method=GET,status=200}": 2,
+		"etna.rest.duration{endpoint=v1.0/accounts/{id}/orders,method=GET}":            2,
+		"etna.rest.retries{endpoint=v1.0/accounts/{id}/orders,method=POST}":            1,
+		"etna.rest.auth_refreshes{success=false}":                                      1,
+		"etna.ws.reconnects{client=md}":                                                1,
+		"etna.ws.messages{client=md,topic=Quote}":                                      1,
+		"etna.ws.subscriptions{client=md,topic=Quote}":                                 2,
+	}
+	(*meter).mu.Lock()
+	defer (*meter).mu.Unlock()
+	for key, v := range expect {
+		if res := (*meter).values[key]; res != v {
+			(*t).Errorf("wrong %s: %g", key, res)
+		}
+	}
+	if _, exist := (*meter).values["etna.ws.uptime{client=md}"]; !exist {
+		(*t).Error("the uptime of the connected client isn't observed")
+	} else if _, exist = (*meter).values["etna.ws.uptime{client=oms}"]; exist {
+		(*t).Error("the uptime of the disconnected client is observed")
+	}
+}
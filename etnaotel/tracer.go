@@ -0,0 +1,88 @@
+package etnaotel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/long-js/goetna"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ goetna.Tracer = (*Tracer)(nil)
+
+// Tracer implements goetna.Tracer with the OpenTelemetry tracer.
+//
+//	tracer := etnaotel.NewTracer(otel.GetTracerProvider(), otel.GetTextMapPropagator())
+//	rest, err := goetna.New(goetna.WithTracer(tracer), ...)
+//	rest.SetOrderTracer(goetna.NewOrderTracer(tracer, 24*time.Hour))
+type Tracer struct {
+	tracer trace.Tracer
+	prop   propagation.TextMapPropagator
+}
+
+// NewTracer creates the tracer. The propagator injects the trace context into the REST requests,
+// nil disables the propagation.
+func NewTracer(tp trace.TracerProvider, prop propagation.TextMapPropagator) *Tracer {
+	return &Tracer{tracer: tp.Tracer(ScopeName), prop: prop}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...goetna.Attr) (context.Context, goetna.Span) {
+	ctx, span := (*t).tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(convertAttrs(attrs)...))
+	return ctx, otelSpan{span: span}
+}
+
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	if (*t).prop != nil {
+		(*t).prop.Inject(ctx, propagation.HeaderCarrier(header))
+	}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttributes(attrs ...goetna.Attr) {
+	s.span.SetAttributes(convertAttrs(attrs)...)
+}
+
+func (s otelSpan) AddEvent(name string, attrs ...goetna.Attr) {
+	s.span.AddEvent(name, trace.WithAttributes(convertAttrs(attrs)...))
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func convertAttrs(attrs []goetna.Attr) []attribute.KeyValue {
+	res := make([]attribute.KeyValue, len(attrs))
+	for i, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			res[i] = attribute.String(a.Key, v)
+		case bool:
+			res[i] = attribute.Bool(a.Key, v)
+		case int:
+			res[i] = attribute.Int(a.Key, v)
+		case int64:
+			res[i] = attribute.Int64(a.Key, v)
+		case uint64:
+			res[i] = attribute.Int64(a.Key, int64(v))
+		case float64:
+			res[i] = attribute.Float64(a.Key, v)
+		case fmt.Stringer:
+			res[i] = attribute.String(a.Key, v.String())
+		default:
+			res[i] = attribute.String(a.Key, fmt.Sprint(v))
+		}
+	}
+	return res
+}
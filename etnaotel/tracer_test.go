@@ -0,0 +1,33 @@
+package etnaotel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/long-js/goetna"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestConvertAttrs(t *testing.T) {
+	tests := map[string]struct {
+		value  any
+		expect attribute.Value
+	}{
+		"string":   {value: "AAPL", expect: attribute.StringValue("AAPL")},
+		"bool":     {value: true, expect: attribute.BoolValue(true)},
+		"int":      {value: 200, expect: attribute.IntValue(200)},
+		"int64":    {value: int64(-7), expect: attribute.Int64Value(-7)},
+		"uint64":   {value: uint64(42), expect: attribute.Int64Value(42)},
+		"float64":  {value: 1.5, expect: attribute.Float64Value(1.5)},
+		"stringer": {value: time.Second, expect: attribute.StringValue("1s")},
+		"other":    {value: uint8(3), expect: attribute.StringValue("3")},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			res := convertAttrs([]goetna.Attr{{Key: "key", Value: tc.value}})
+			if len(res) != 1 || res[0].Key != "key" || res[0].Value != tc.expect {
+				(*t).Errorf("wrong attribute: %+v", res)
+			}
+		})
+	}
+}
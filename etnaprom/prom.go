@@ -0,0 +1,116 @@
+// Package etnaprom exports the metrics of the goetna clients to Prometheus.
+//
+//	metrics, err := etnaprom.New(prometheus.DefaultRegisterer, "etna")
+//	rest, err := goetna.New(goetna.WithInstrumentation(metrics), ...)
+//	ws.SetInstrumentation(metrics)
+package etnaprom
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/long-js/goetna"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ goetna.Instrumentation = (*Metrics)(nil)
+
+// Metrics implements goetna.Instrumentation with the Prometheus collectors.
+type Metrics struct {
+	requests       *prometheus.CounterVec
+	latency        *prometheus.HistogramVec
+	retries        *prometheus.CounterVec
+	authRefreshes  *prometheus.CounterVec
+	reconnects     *prometheus.CounterVec
+	connected      *prometheus.GaugeVec
+	connectedSince *prometheus.GaugeVec
+	subscriptions  *prometheus.GaugeVec
+	messages       *prometheus.CounterVec
+}
+
+// New creates the collectors with the namespace and registers them.
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "rest", Name: "requests_total",
+			Help: "The number of REST requests by endpoint and response status, 0 if there is no response.",
+		}, []string{"method", "endpoint", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "rest", Name: "request_duration_seconds",
+			Help: "The latency of REST requests.", Buckets: prometheus.DefBuckets,
+		}, []string{"method", "endpoint"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "rest", Name: "retries_total",
+			Help: "The number of repeated REST requests.",
+		}, []string{"method", "endpoint"}),
+		authRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "rest", Name: "auth_refreshes_total",
+			Help: "The number of authentications by result.",
+		}, []string{"result"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "reconnects_total",
+			Help: "The number of WebSocket reconnection attempts.",
+		}, []string{"client"}),
+		connected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "connected",
+			Help: "1 if the WebSocket client is connected.",
+		}, []string{"client"}),
+		connectedSince: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "connected_since_seconds",
+			Help: "The unix time of the WebSocket connection, the uptime is time() - this value.",
+		}, []string{"client"}),
+		subscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "subscriptions",
+			Help: "The number of active WebSocket subscriptions by topic.",
+		}, []string{"client", "topic"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "ws", Name: "messages_total",
+			Help: "The number of received WebSocket messages by topic.",
+		}, []string{"client", "topic"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.latency, m.retries, m.authRefreshes, m.reconnects,
+		m.connected, m.connectedSince, m.subscriptions, m.messages} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+func (m *Metrics) RequestDone(method, endpoint string, status int, latency time.Duration) {
+	(*m).requests.WithLabelValues(method, endpoint, strconv.Itoa(status)).Inc()
+	(*m).latency.WithLabelValues(method, endpoint).Observe(latency.Seconds())
+}
+
+func (m *Metrics) RequestRetried(method, endpoint string, attempt int) {
+	(*m).retries.WithLabelValues(method, endpoint).Inc()
+}
+
+func (m *Metrics) AuthRefreshed(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	(*m).authRefreshes.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) Reconnecting(client string, attempt int) {
+	(*m).reconnects.WithLabelValues(client).Inc()
+}
+
+func (m *Metrics) ConnStateChanged(client string, connected bool) {
+	if connected {
+		(*m).connected.WithLabelValues(client).Set(1)
+		(*m).connectedSince.WithLabelValues(client).SetToCurrentTime()
+	} else {
+		(*m).connected.WithLabelValues(client).Set(0)
+	}
+}
+
+func (m *Metrics) SubscriptionsChanged(client, topic string, count int) {
+	(*m).subscriptions.WithLabelValues(client, topic).Set(float64(count))
+}
+
+func (m *Metrics) MessageReceived(client, topic string) {
+	(*m).messages.WithLabelValues(client, topic).Inc()
+}
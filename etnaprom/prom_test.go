@@ -0,0 +1,83 @@
+package etnaprom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather returns the values of the metrics by "name{label=value,...}".
+func gather(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	families, err := reg.Gather()
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	res := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			labels := make([]string, len(m.GetLabel()))
+			for i, l := range m.GetLabel() {
+				labels[i] = l.GetName() + "=" + l.GetValue()
+			}
+			key := f.GetName() + "{" + strings.Join(labels, ",") + "}"
+			switch {
+			case m.GetCounter() != nil:
+				res[key] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				res[key] = m.GetGauge().GetValue()
+			case m.GetHistogram() != nil:
+				res[key] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return res
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg, "etna")
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	m.RequestDone("GET", "v1.0/accounts/{id}/orders", 200, time.Millisecond)
+	m.RequestDone("GET", "v1.0/accounts/{id}/orders", 200, time.Millisecond)
+	m.RequestDone("POST", "v1.0/accounts/{id}/orders", 0, time.Second)
+	m.RequestRetried("POST", "v1.0/accounts/{id}/orders", 2)
+	m.AuthRefreshed(nil)
+	m.AuthRefreshed(errors.New("failed"))
+	m.Reconnecting("md", 1)
+	m.ConnStateChanged("md", true)
+	m.ConnStateChanged("oms", true)
+	m.ConnStateChanged("oms", false)
+	m.SubscriptionsChanged("md", "Quote", 3)
+	m.MessageReceived("md", "Quote")
+
+	expect := map[string]float64{
+		"etna_rest_requests_total{endpoint=v1.0/accounts/{id}/orders,method=GET,status=200}": 2,
+		"etna_rest_requests_total{endpoint=v1.0/accounts/{id}/orders,method=POST,status=0}":  1,
+		"etna_rest_request_duration_seconds{endpoint=v1.0/accounts/{id}/orders,method=GET}":  2,
+		"etna_rest_retries_total{endpoint=v1.0/accounts/{id}/orders,method=POST}":            1,
+		"etna_rest_auth_refreshes_total{result=success}":                                     1,
+		"etna_rest_auth_refreshes_total{result=failure}":                                     1,
+		"etna_ws_reconnects_total{client=md}":                                                1,
+		"etna_ws_connected{client=md}":                                                       1,
+		"etna_ws_connected{client=oms}":                                                      0,
+		"etna_ws_subscriptions{client=md,topic=Quote}":                                       3,
+		"etna_ws_messages_total{client=md,topic=Quote}":                                      1,
+	}
+	res := gather(t, reg)
+	for key, v := range expect {
+		if res[key] != v {
+			(*t).Errorf("wrong %s: %g", key, res[key])
+		}
+	}
+	if since := res["etna_ws_connected_since_seconds{client=md}"]; since < float64(time.Now().Add(-time.Minute).Unix()) {
+		(*t).Errorf("wrong connection time: %g", since)
+	}
+	if _, err = New(reg, "etna"); err == nil {
+		(*t).Error("the collectors are registered twice")
+	}
+}
@@ -0,0 +1,279 @@
+package goetna
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+var fieldNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]*$`)
+
+// Filter builds the ETNA filter expression, the conditions are joined with "and".
+// String values are quoted and escaped, so any user input is safe to pass.
+type Filter struct {
+	conds     []string
+	sortField string
+	desc      bool
+	err       error
+}
+
+// Eq adds the condition `field = value`.
+func (f *Filter) Eq(field string, value any) *Filter {
+	return f.cond(field, "=", value)
+}
+
+// Ne adds the condition `field != value`.
+func (f *Filter) Ne(field string, value any) *Filter {
+	return f.cond(field, "!=", value)
+}
+
+// Ge adds the condition `field >= value`.
+func (f *Filter) Ge(field string, value any) *Filter {
+	return f.cond(field, ">=", value)
+}
+
+// Le adds the condition `field <= value`.
+func (f *Filter) Le(field string, value any) *Filter {
+	return f.cond(field, "<=", value)
+}
+
+// In adds the condition `field in (values...)`. The empty values are ignored.
+func (f *Filter) In(field string, values ...any) *Filter {
+	if len(values) == 0 {
+		return f
+	} else if !fieldNameRe.MatchString(field) {
+		(*f).err = fmt.Errorf("wrong filter field: %q", field)
+		return f
+	}
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = filterValue(v)
+	}
+	(*f).conds = append((*f).conds, fmt.Sprintf("%s in (%s)", field, strings.Join(items, ",")))
+	return f
+}
+
+// SortBy sets the sort field and direction.
+func (f *Filter) SortBy(field string, desc bool) *Filter {
+	if !fieldNameRe.MatchString(field) {
+		(*f).err = fmt.Errorf("wrong sort field: %q", field)
+		return f
+	}
+	(*f).sortField, (*f).desc = field, desc
+	return f
+}
+
+// String renders the filter expression.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join((*f).conds, " and ")
+}
+
+// Err returns the error of the filter building, e.g. the wrong field name.
+func (f *Filter) Err() error {
+	if f == nil {
+		return nil
+	}
+	return (*f).err
+}
+
+func (f *Filter) cond(field, op string, value any) *Filter {
+	if !fieldNameRe.MatchString(field) {
+		(*f).err = fmt.Errorf("wrong filter field: %q", field)
+		return f
+	}
+	(*f).conds = append((*f).conds, fmt.Sprintf("%s %s %s", field, op, filterValue(value)))
+	return f
+}
+
+// apply puts the filter and sorting into the query using the resource specific sorting parameters.
+func (f *Filter) apply(qry url.Values, sortKey, descKey string) {
+	if f == nil {
+		return
+	}
+	if expr := f.String(); expr != "" {
+		qry.Set("filter", expr)
+	}
+	if (*f).sortField != "" {
+		qry.Set(sortKey, (*f).sortField)
+		qry.Set(descKey, strconv.FormatBool((*f).desc))
+	}
+}
+
+// filterValue renders the value in the filter expression syntax.
+func filterValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return quoteFilterString(v)
+	case time.Time:
+		return quoteFilterString(v.UTC().Format(time.RFC3339))
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case sch.OrderStatus:
+		return strconv.Itoa(int(v))
+	case fmt.Stringer:
+		return quoteFilterString(v.String())
+	}
+	return quoteFilterString(fmt.Sprint(value))
+}
+
+// quoteFilterString wraps the string into double quotes, escaping the backslashes, quotes and control characters.
+func quoteFilterString(s string) string {
+	var b strings.Builder
+
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// OrderFilter selects the orders, see EtnaREST.FindOrders.
+type OrderFilter struct {
+	Filter
+}
+
+// NewOrderFilter creates the empty order filter.
+func NewOrderFilter() *OrderFilter {
+	return &OrderFilter{}
+}
+
+// ActiveOrdersFilter selects the new, partially filled and pending orders.
+func ActiveOrdersFilter() *OrderFilter {
+	return NewOrderFilter().Statuses(sch.StatusNew, sch.StatusPartiallyFilled, sch.StatusPendingNew)
+}
+
+func (f *OrderFilter) Symbol(symbol string) *OrderFilter {
+	(*f).Eq("Symbol", symbol)
+	return f
+}
+
+func (f *OrderFilter) Sides(sides ...sch.OrderSide) *OrderFilter {
+	(*f).In("Side", anySlice(sides)...)
+	return f
+}
+
+func (f *OrderFilter) Statuses(statuses ...sch.OrderStatus) *OrderFilter {
+	(*f).In("Status", anySlice(statuses)...)
+	return f
+}
+
+func (f *OrderFilter) Types(types ...sch.OrderType) *OrderFilter {
+	(*f).In("Type", anySlice(types)...)
+	return f
+}
+
+func (f *OrderFilter) ClientId(clientId string) *OrderFilter {
+	(*f).Eq("ClientId", clientId)
+	return f
+}
+
+// CreatedFrom selects the orders created at or after the time.
+func (f *OrderFilter) CreatedFrom(ts time.Time) *OrderFilter {
+	(*f).Ge("CreateDate", ts)
+	return f
+}
+
+// CreatedTill selects the orders created at or before the time.
+func (f *OrderFilter) CreatedTill(ts time.Time) *OrderFilter {
+	(*f).Le("CreateDate", ts)
+	return f
+}
+
+func (f *OrderFilter) Sort(field string, desc bool) *OrderFilter {
+	(*f).SortBy(field, desc)
+	return f
+}
+
+// PositionFilter selects the positions, see EtnaREST.FindPositions.
+type PositionFilter struct {
+	Filter
+}
+
+// NewPositionFilter creates the empty position filter.
+func NewPositionFilter() *PositionFilter {
+	return &PositionFilter{}
+}
+
+func (f *PositionFilter) Symbol(symbol string) *PositionFilter {
+	(*f).Eq("Symbol", symbol)
+	return f
+}
+
+func (f *PositionFilter) SecurityTypes(types ...string) *PositionFilter {
+	(*f).In("SecurityType", anySlice(types)...)
+	return f
+}
+
+func (f *PositionFilter) Sort(field string, desc bool) *PositionFilter {
+	(*f).SortBy(field, desc)
+	return f
+}
+
+// TransferFilter selects the transfers, see EtnaREST.FindTransfers.
+type TransferFilter struct {
+	Filter
+}
+
+// NewTransferFilter creates the empty transfer filter.
+func NewTransferFilter() *TransferFilter {
+	return &TransferFilter{}
+}
+
+func (f *TransferFilter) Statuses(statuses ...string) *TransferFilter {
+	(*f).In("Status", anySlice(statuses)...)
+	return f
+}
+
+func (f *TransferFilter) IsDeposit(isDeposit bool) *TransferFilter {
+	(*f).Eq("IsDeposit", isDeposit)
+	return f
+}
+
+// From selects the transfers made at or after the time.
+func (f *TransferFilter) From(ts time.Time) *TransferFilter {
+	(*f).Ge("TransferDate", ts)
+	return f
+}
+
+// Till selects the transfers made at or before the time.
+func (f *TransferFilter) Till(ts time.Time) *TransferFilter {
+	(*f).Le("TransferDate", ts)
+	return f
+}
+
+func (f *TransferFilter) Sort(field string, desc bool) *TransferFilter {
+	(*f).SortBy(field, desc)
+	return f
+}
+
+func anySlice[T any](values []T) []any {
+	res := make([]any, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package goetna
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestFilterValue(t *testing.T) {
+	tests := map[string]struct {
+		value  any
+		expect string
+	}{
+		"string":    {value: "AAPL", expect: `"AAPL"`},
+		"quote":     {value: `A" or 1=1 or "`, expect: `"A\" or 1=1 or \""`},
+		"backslash": {value: `C:\tmp`, expect: `"C:\\tmp"`},
+		"control":   {value: "a\nb\x7f", expect: `"a\u000ab\u007f"`},
+		"unicode":   {value: "Müller", expect: `"Müller"`},
+		"int":       {value: -42, expect: "-42"},
+		"uint32":    {value: uint32(7), expect: "7"},
+		"float":     {value: 1.25, expect: "1.25"},
+		"bool":      {value: true, expect: "true"},
+		"time":      {value: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600)), expect: `"2024-05-01T17:00:00Z"`},
+		"status":    {value: sch.StatusPendingNew, expect: "10"},
+		"side":      {value: sch.SideBuy, expect: `"Buy"`},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := filterValue(tc.value); res != tc.expect {
+				(*t).Errorf("wrong value: %s", res)
+			}
+		})
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	tests := map[string]struct {
+		filter    *Filter
+		expect    string
+		expectErr bool
+	}{
+		"empty": {filter: &Filter{}, expect: ""},
+		"nil":   {filter: nil, expect: ""},
+		"orders": {
+			filter: &NewOrderFilter().Symbol("AAPL").Statuses(sch.StatusNew, sch.StatusPartiallyFilled).Filter,
+			expect: `Symbol = "AAPL" and Status in (0,1)`},
+		"empty_in": {
+			filter: &NewOrderFilter().Sides().ClientId(`x"y`).Filter,
+			expect: `ClientId = "x\"y"`},
+		"transfers": {
+			filter: &NewTransferFilter().IsDeposit(false).Statuses("Done").Filter,
+			expect: `IsDeposit = false and Status in ("Done")`},
+		"wrong_field": {
+			filter:    (&Filter{}).Eq("Symbol = 1 or Id", 1),
+			expectErr: true},
+		"wrong_sort": {
+			filter:    (&Filter{}).SortBy("Id desc;", true),
+			expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if err := tc.filter.Err(); (err != nil) != tc.expectErr {
+				(*t).Errorf("wrong error: %v", err)
+			} else if !tc.expectErr && tc.filter.String() != tc.expect {
+				(*t).Errorf("wrong filter: %s", tc.filter.String())
+			}
+		})
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	qry := url.Values{}
+	NewPositionFilter().Symbol("TSLA").Sort("Quantity", true).apply(qry, "sortField", "desc")
+	if qry.Get("filter") != `Symbol = "TSLA"` || qry.Get("sortField") != "Quantity" || qry.Get("desc") != "true" {
+		(*t).Errorf("wrong query: %v", qry)
+	}
+}
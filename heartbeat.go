@@ -0,0 +1,88 @@
+package goetna
+
+import (
+	"strconv"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+)
+
+// Liveness is the time of the last signs of life of the WebSocket connection.
+type Liveness struct {
+	LastMessage   time.Time // any message or pong
+	LastPong      time.Time // the pong to the control ping of the watchdog
+	LastHeartbeat time.Time // the application-level ping (ETNA) or heartbeat (FMP)
+}
+
+// Liveness returns the time of the last activity of the connection, the zero time means there was none.
+func (ws *WSClient) Liveness() Liveness {
+	return Liveness{
+		LastMessage:   unixNanoTime((*ws).lastMsgTs.Load()),
+		LastPong:      unixNanoTime((*ws).lastPongTs.Load()),
+		LastHeartbeat: unixNanoTime((*ws).lastHeartbeatTs.Load()),
+	}
+}
+
+func unixNanoTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ts)
+}
+
+// heartbeat records the application-level ping or heartbeat message.
+func (ws *WSClient) heartbeat() {
+	(*ws).lastHeartbeatTs.Store(time.Now().UnixNano())
+}
+
+// onPong handles incoming WebSocket pong messages.
+func (ws *WSClient) onPong(data string) error {
+	(*ws).logger.Debug("<-- PONG %s", data)
+	now := time.Now().UnixNano()
+	(*ws).lastPongTs.Store(now)
+	(*ws).lastMsgTs.Store(now)
+	return nil
+}
+
+// goWatchdog is a goroutine that watches the connection: it sends the control ping when nothing is received
+// during EtnaConfig.WSPingTimeout, and closes the connection silent longer than EtnaConfig.WSMaxSilentPeriod,
+// so the receiver fails and the client reconnects. It finishes when the connection is replaced.
+func (ws *WSClient) goWatchdog(conn *gws.Conn) {
+	pingTimeout, maxSilence := (*ws).cfg.WSPingTimeout, (*ws).cfg.WSMaxSilentPeriod
+	if conn == nil || pingTimeout <= 0 || maxSilence <= 0 {
+		return
+	}
+	defer (*ws).logger.Debug("watchdog finished: %s", (*ws).name)
+	ticker := time.NewTicker(pingTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-(*ws).ctx.Done():
+			return
+		case now := <-ticker.C:
+			(*ws).mu.Lock()
+			current := (*ws).conn == conn
+			(*ws).mu.Unlock()
+			if !current || !(*ws).connected.Load() {
+				return
+			}
+
+			silence := now.Sub(unixNanoTime((*ws).lastMsgTs.Load()))
+			if silence > maxSilence {
+				(*ws).logger.Error("connection is silent for %s, closing: %s", silence.Round(time.Millisecond),
+					(*ws).name)
+				if err := conn.Close(); err != nil {
+					(*ws).logger.Error("closing silent connection fault: %s %v", (*ws).name, err)
+				}
+				return
+			} else if silence >= pingTimeout {
+				err := conn.WriteControl(gws.PingMessage, []byte(strconv.FormatInt(now.Unix(), 10)),
+					now.Add(pingTimeout))
+				if err != nil {
+					(*ws).logger.Error("ping fault: %s %v", (*ws).name, err)
+				}
+			}
+		}
+	}
+}
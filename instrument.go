@@ -0,0 +1,50 @@
+package goetna
+
+import (
+	"strings"
+	"time"
+)
+
+// Instrumentation receives the metrics of the REST and WebSocket clients. The methods are called on the request
+// and message paths, so the implementations must be fast and safe for the concurrent use.
+// The adapters for Prometheus and OpenTelemetry are in the etnaprom and etnaotel packages.
+type Instrumentation interface {
+	// RequestDone is called after every HTTP request, `status` is 0 if there is no response.
+	// The numeric path segments of the endpoint are replaced with "{id}".
+	RequestDone(method, endpoint string, status int, latency time.Duration)
+	// RequestRetried is called before the repeated attempt of the request.
+	RequestRetried(method, endpoint string, attempt int)
+	// AuthRefreshed is called after every authentication, `err` is nil if it succeeded.
+	AuthRefreshed(err error)
+	// Reconnecting is called before every reconnection attempt of the WebSocket client.
+	Reconnecting(client string, attempt int)
+	// ConnStateChanged is called when the WebSocket client is connected or disconnected.
+	ConnStateChanged(client string, connected bool)
+	// SubscriptionsChanged is called with the number of the active subscriptions of the topic.
+	SubscriptionsChanged(client, topic string, count int)
+	// MessageReceived is called for every WebSocket message.
+	MessageReceived(client, topic string)
+}
+
+// NopInstrumentation discards the metrics, it's used by default.
+type NopInstrumentation struct{}
+
+func (NopInstrumentation) RequestDone(method, endpoint string, status int, latency time.Duration) {}
+func (NopInstrumentation) RequestRetried(method, endpoint string, attempt int)                    {}
+func (NopInstrumentation) AuthRefreshed(err error)                                                {}
+func (NopInstrumentation) Reconnecting(client string, attempt int)                                {}
+func (NopInstrumentation) ConnStateChanged(client string, connected bool)                         {}
+func (NopInstrumentation) SubscriptionsChanged(client, topic string, count int)                   {}
+func (NopInstrumentation) MessageReceived(client, topic string)                                   {}
+
+// endpointTemplate replaces the numeric path segments (account and order ids) with "{id}",
+// so the endpoint can be used as the metric label.
+func endpointTemplate(endpoint string) string {
+	parts := strings.Split(endpoint, "/")
+	for i, p := range parts {
+		if p != "" && strings.Trim(p, "0123456789") == "" {
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,74 @@
+package goetna
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEndpointTemplate(t *testing.T) {
+	tests := map[string]struct {
+		endpoint, expect string
+	}{
+		"account":  {endpoint: "v1.0/accounts/123/orders", expect: "v1.0/accounts/{id}/orders"},
+		"order":    {endpoint: "v1.0/accounts/123/orders/456", expect: "v1.0/accounts/{id}/orders/{id}"},
+		"version":  {endpoint: "v1.0/users/@me", expect: "v1.0/users/@me"},
+		"symbol":   {endpoint: "v1.0/equities/AAPL", expect: "v1.0/equities/AAPL"},
+		"mixed":    {endpoint: "v1.0/equities/A1", expect: "v1.0/equities/A1"},
+		"trailing": {endpoint: "v1.0/accounts/123/", expect: "v1.0/accounts/{id}/"},
+		"token":    {endpoint: "token", expect: "token"},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := endpointTemplate(tc.endpoint); res != tc.expect {
+				(*t).Errorf("wrong template: %s", res)
+			}
+		})
+	}
+}
+
+// recordInstrumentation records the REST requests and authentications.
+type recordInstrumentation struct {
+	NopInstrumentation
+	mu       sync.Mutex
+	requests []string
+	auths    int
+}
+
+func (r *recordInstrumentation) RequestDone(method, endpoint string, status int, _ time.Duration) {
+	(*r).mu.Lock()
+	defer (*r).mu.Unlock()
+	(*r).requests = append((*r).requests, method+" "+endpoint+" "+http.StatusText(status))
+}
+
+func (r *recordInstrumentation) AuthRefreshed(err error) {
+	(*r).mu.Lock()
+	defer (*r).mu.Unlock()
+	if err == nil {
+		(*r).auths++
+	}
+}
+
+func TestRESTInstrumentation(t *testing.T) {
+	instr := &recordInstrumentation{}
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"GET /v1.0/accounts/7/orders/42": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	}, WithInstrumentation(instr))
+	if _, err := rest.GetOrder(context.Background(), 7, 42); err == nil {
+		(*t).Fatal("the absent order is found")
+	}
+
+	(*instr).mu.Lock()
+	defer (*instr).mu.Unlock()
+	expect := "POST token OK;GET v1.0/accounts/{id}/orders/{id} Not Found"
+	if res := strings.Join((*instr).requests, ";"); res != expect {
+		(*t).Errorf("wrong requests: %s", res)
+	} else if (*instr).auths != 1 {
+		(*t).Errorf("wrong number of authentications: %d", (*instr).auths)
+	}
+}
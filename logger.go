@@ -1,9 +1,12 @@
 package goetna
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 type Logger interface {
@@ -26,6 +29,14 @@ func (l StdLogger) Debug(format string, v ...any) { l.debugLogger.Printf(format,
 func (l StdLogger) Error(format string, v ...any) { l.errorLogger.Printf(format, v...) }
 func (l StdLogger) Fatal(format string, v ...any) { l.fatalLogger.Fatalf(format, v...) }
 
+// NopLogger discards all messages.
+type NopLogger struct{}
+
+func (NopLogger) Info(format string, v ...any)  {}
+func (NopLogger) Debug(format string, v ...any) {}
+func (NopLogger) Error(format string, v ...any) {}
+func (NopLogger) Fatal(format string, v ...any) {}
+
 func ColouredLogger(name string) Logger {
 	const colGreen = "\033[1;32m"
 	const colTeal = "\033[1;36m"
@@ -43,3 +54,72 @@ func ColouredLogger(name string) Logger {
 			log.Lmsgprefix|log.LstdFlags|log.Lmicroseconds),
 	}
 }
+
+// StructuredLogger is the Logger, which accepts the structured fields, e.g. SlogLogger.
+// The clients use it for the records of the requests and messages, the other loggers
+// get the fields appended to the message.
+type StructuredLogger interface {
+	Logger
+	LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// SlogLogger is the Logger on top of slog.Handler. The secrets are removed from the messages and fields,
+// see NewRedactHandler.
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger creates the logger writing to the handler, e.g.
+//
+//	goetna.NewSlogLogger(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+func NewSlogLogger(h slog.Handler) *SlogLogger {
+	return &SlogLogger{l: slog.New(NewRedactHandler(h))}
+}
+
+// With returns the logger, which adds the fields to every record, e.g. the client name.
+func (l *SlogLogger) With(args ...any) *SlogLogger {
+	return &SlogLogger{l: (*l).l.With(args...)}
+}
+
+func (l *SlogLogger) Info(format string, v ...any)  { l.log(slog.LevelInfo, format, v) }
+func (l *SlogLogger) Debug(format string, v ...any) { l.log(slog.LevelDebug, format, v) }
+func (l *SlogLogger) Error(format string, v ...any) { l.log(slog.LevelError, format, v) }
+func (l *SlogLogger) Fatal(format string, v ...any) {
+	l.log(slog.LevelError+4, format, v)
+	os.Exit(1)
+}
+
+func (l *SlogLogger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	(*l).l.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (l *SlogLogger) log(level slog.Level, format string, v []any) {
+	if (*l).l.Enabled(context.Background(), level) {
+		(*l).l.Log(context.Background(), level, fmt.Sprintf(format, v...))
+	}
+}
+
+// logAttrs writes the record with the structured fields.
+func logAttrs(l Logger, level slog.Level, msg string, attrs ...slog.Attr) {
+	if sl, ok := l.(StructuredLogger); ok {
+		sl.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	} else if _, ok = l.(NopLogger); ok {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, a := range attrs {
+		sb.WriteByte(' ')
+		sb.WriteString(a.String())
+	}
+	switch {
+	case level >= slog.LevelError:
+		l.Error("%s", sb.String())
+	case level >= slog.LevelInfo:
+		l.Info("%s", sb.String())
+	default:
+		l.Debug("%s", sb.String())
+	}
+}
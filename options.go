@@ -0,0 +1,208 @@
+package goetna
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	gschema "github.com/gorilla/schema"
+)
+
+// Option configures the EtnaREST client created by New.
+type Option func(o *restOptions)
+
+type restOptions struct {
+	ctx                 context.Context
+	cfg                 *EtnaConfig
+	httpClient          *http.Client
+	tlsConfig           *tls.Config
+	baseUrl, nonRTHUrl  string
+	apiKey, nonRTHToken string
+	login, passwd       []byte
+	isPrivate           bool
+	logger              Logger
+	retry               *RetryPolicy
+	retrySet            bool
+	limiter             *RateLimiter
+	validation          *ValidationMode
+	validationTTL       time.Duration
+	instr               Instrumentation
+	tracer              Tracer
+}
+
+// WithContext sets the context of the initial authentication.
+func WithContext(ctx context.Context) Option {
+	return func(o *restOptions) { (*o).ctx = ctx }
+}
+
+// WithEtnaConfig sets the configuration, which provides the URLs and timeouts. It's required,
+// see NewConfig, LoadConfig and LoadConfigFromEnv.
+func WithEtnaConfig(cfg *EtnaConfig) Option {
+	return func(o *restOptions) { (*o).cfg = cfg }
+}
+
+// WithHTTPClient sets the HTTP client. The client isn't modified, a copy is made if WithTLSConfig is used as well.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *restOptions) { (*o).httpClient = c }
+}
+
+// WithTLSConfig sets the TLS configuration of the HTTP transport, e.g. to trust the local CA certificate.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *restOptions) { (*o).tlsConfig = cfg }
+}
+
+// WithBaseURL overrides the base URL of the ETNA API taken from the configuration.
+func WithBaseURL(url string) Option {
+	return func(o *restOptions) { (*o).baseUrl = url }
+}
+
+// WithNonRTHURL overrides the base URL of the non-RTH (market data) API taken from the configuration.
+func WithNonRTHURL(url string) Option {
+	return func(o *restOptions) { (*o).nonRTHUrl = url }
+}
+
+// WithLogger sets the logger, e.g. NewSlogLogger. The logging is disabled by default.
+// The secrets are removed from the messages, see Redact.
+func WithLogger(l Logger) Option {
+	return func(o *restOptions) { (*o).logger = l }
+}
+
+// WithAPIKey sets the ETNA application key.
+func WithAPIKey(apiKey string) Option {
+	return func(o *restOptions) { (*o).apiKey = apiKey }
+}
+
+// WithNonRTHToken sets the bearer token of the non-RTH API.
+func WithNonRTHToken(token string) Option {
+	return func(o *restOptions) { (*o).nonRTHToken = token }
+}
+
+// WithCredentials sets the base64 encoded login and password.
+func WithCredentials(login, passwd []byte) Option {
+	return func(o *restOptions) { (*o).login, (*o).passwd = login, passwd }
+}
+
+// WithPrivateAPI selects the private API URL of the configuration instead of the public one.
+func WithPrivateAPI(isPrivate bool) Option {
+	return func(o *restOptions) { (*o).isPrivate = isPrivate }
+}
+
+// WithRetryPolicy replaces the default retry policy. The nil policy disables the retries.
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(o *restOptions) { (*o).retry, (*o).retrySet = p, true }
+}
+
+// WithRateLimiter sets the client-side rate limiter, which may be shared with other clients.
+func WithRateLimiter(l *RateLimiter) Option {
+	return func(o *restOptions) { (*o).limiter = l }
+}
+
+// WithOrderValidation enables the pre-trade order validation, the securities and settings are cached for `ttl`.
+func WithOrderValidation(mode ValidationMode, ttl time.Duration) Option {
+	return func(o *restOptions) { (*o).validation, (*o).validationTTL = &mode, ttl }
+}
+
+// WithInstrumentation sets the receiver of the client metrics, e.g. etnaprom.Metrics.
+func WithInstrumentation(i Instrumentation) Option {
+	return func(o *restOptions) { (*o).instr = i }
+}
+
+// WithTracer sets the tracer of the REST requests, e.g. etnaotel.NewTracer.
+func WithTracer(t Tracer) Option {
+	return func(o *restOptions) { (*o).tracer = t }
+}
+
+// New creates the EtnaREST client configured by the options and authenticates it.
+func New(opts ...Option) (*EtnaREST, error) {
+	o := restOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.login) == 0 || len(o.passwd) == 0 {
+		return nil, fmt.Errorf("credentials are absent")
+	} else if o.cfg == nil {
+		return nil, fmt.Errorf("config is absent, see WithEtnaConfig")
+	} else if err := o.cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rest := EtnaREST{
+		httpClient: o.httpClient,
+		cfg:        o.cfg,
+		enc:        gschema.NewEncoder(),
+		baseUrl:    o.baseUrl,
+		nonRTHUrl:  o.nonRTHUrl,
+		log:        newRedactLogger(o.logger),
+		creds:      credentials{login: o.login, passwd: o.passwd},
+		retry:      DefaultRetryPolicy(),
+		limiter:    o.limiter,
+		instr:      o.instr,
+		tracer:     o.tracer,
+	}
+	if o.retrySet {
+		rest.retry = o.retry
+	}
+	if rest.httpClient == nil {
+		rest.httpClient = &http.Client{Timeout: o.cfg.RestTimeout}
+	}
+	if o.tlsConfig == nil && o.cfg.UseLocalCert {
+		var err error
+		if o.tlsConfig, err = useLocalEtnaCert(); err != nil {
+			return nil, err
+		}
+	}
+	if o.tlsConfig != nil {
+		client := *rest.httpClient
+		if tr, ok := client.Transport.(*http.Transport); ok {
+			client.Transport = tr.Clone()
+		} else if client.Transport == nil {
+			client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+		} else {
+			return nil, fmt.Errorf("TLS config can't be applied to %T", client.Transport)
+		}
+		client.Transport.(*http.Transport).TLSClientConfig = o.tlsConfig
+		rest.httpClient = &client
+	}
+	if rest.baseUrl == "" {
+		if o.isPrivate {
+			rest.baseUrl = o.cfg.RestUrlPriv
+		} else {
+			rest.baseUrl = o.cfg.RestUrlPub
+		}
+	}
+	if rest.nonRTHUrl == "" {
+		rest.nonRTHUrl = o.cfg.RestUrlNonRTH
+	}
+	if rest.instr == nil {
+		rest.instr = NopInstrumentation{}
+	}
+	if rest.tracer == nil {
+		rest.tracer = NopTracer{}
+	}
+	if o.validation != nil {
+		rest.validator = NewOrderValidator(&rest, *o.validation, o.validationTTL)
+	}
+
+	header := make(http.Header)
+	// header["User-Agent"] = []string{"qant-backend/2.0"}
+	header["Content-Type"] = []string{"application/json"}
+	header["Accept"] = []string{"application/json"}
+	header["Connection"] = []string{"keep-alive"}
+	header["Et-App-Key"] = []string{o.apiKey}
+	rest.header = header
+
+	header = header.Clone()
+	header.Del("Et-App-Key")
+	header["Authorization"] = []string{fmt.Sprintf("Bearer %s", o.nonRTHToken)}
+	rest.nonRTHHeader = header
+
+	rest.muAuth.Lock()
+	err := rest.authenticate(o.ctx)
+	rest.muAuth.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &rest, nil
+}
@@ -0,0 +1,30 @@
+package goetna
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	(*cfg).RestTimeout = 3 * time.Second
+	tests := map[string]struct {
+		opts   []Option
+		expect time.Duration
+	}{
+		"default":  {expect: 12 * time.Second},
+		"config":   {opts: []Option{WithEtnaConfig(cfg)}, expect: 3 * time.Second},
+		"explicit": {opts: []Option{WithHTTPClient(&http.Client{Timeout: time.Second})}, expect: time.Second},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if rest := newStubREST(t, nil, tc.opts...); (*rest).httpClient.Timeout != tc.expect {
+				(*t).Errorf("wrong timeout: %s", (*rest).httpClient.Timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,534 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// GroupKind is the type of the linked orders group.
+type GroupKind uint8
+
+const (
+	GroupBracket GroupKind = iota // entry with take-profit and stop-loss exits, which cancel each other
+	GroupOCO                      // two orders, the execution of one cancels the other
+	GroupOTO                      // primary order, which triggers the secondary ones
+)
+
+// GroupState is the combined state of the group legs.
+type GroupState uint8
+
+const (
+	GroupActive    GroupState = iota // some legs are working
+	GroupCompleted                   // the group reached its goal: the exit of the bracket, one of OCO, all of OTO filled
+	GroupCancelled                   // the legs are cancelled, rejected or expired without reaching the goal
+	GroupFailed                      // the legs couldn't be placed
+)
+
+func (s GroupState) String() string {
+	switch s {
+	case GroupCompleted:
+		return "Completed"
+	case GroupCancelled:
+		return "Cancelled"
+	case GroupFailed:
+		return "Failed"
+	}
+	return "Active"
+}
+
+// LegRole names the order within the group.
+type LegRole string
+
+const (
+	LegEntry      LegRole = "entry"       // the entry order of the bracket
+	LegTakeProfit LegRole = "take_profit" // the limit exit of the bracket
+	LegStopLoss   LegRole = "stop_loss"   // the stop exit of the bracket
+	LegFirst      LegRole = "first"       // the first order of OCO
+	LegSecond     LegRole = "second"      // the second order of OCO
+	LegPrimary    LegRole = "primary"     // the triggering order of OTO
+	LegSecondary  LegRole = "secondary"   // the triggered order of OTO, the roles of several ones are suffixed with #N
+)
+
+// OrderLeg is the order of the group and its last known state.
+type OrderLeg struct {
+	Role   LegRole
+	Params sch.OrderParams
+	Order  sch.Order
+	parent int     // index of the parent leg, -1 if absent
+	oco    int     // index of the leg, which is cancelled when this one is executed, -1 if absent
+	placed float64 // the quantity protected by the OCO legs, it isn't changed by their reduction
+}
+
+// OrderGroup is the handle of the linked orders. Its state is tracked by the order updates
+// passed to Update or Run, e.g. from EtnaWS.OrdersChan.
+type OrderGroup struct {
+	rest  *EtnaREST
+	accId uint32
+	kind  GroupKind
+	mu    sync.Mutex
+	legs  []*OrderLeg
+	state GroupState
+	done  chan struct{}
+}
+
+// PlaceBracket places the entry order and the take-profit and stop-loss exits linked to it as children.
+// The exits cancel each other once either of them is executed.
+func (api *EtnaREST) PlaceBracket(ctx context.Context, accId uint32,
+	entry, takeProfit, stopLoss *sch.OrderParams) (*OrderGroup, error) {
+	g := newOrderGroup(api, accId, GroupBracket)
+	g.addLeg(LegEntry, entry, -1, -1)
+	g.addLeg(LegTakeProfit, takeProfit, 0, 2)
+	g.addLeg(LegStopLoss, stopLoss, 0, 1)
+	return g, g.place(ctx)
+}
+
+// PlaceOCO places two independent orders, the execution of one of them cancels the other.
+func (api *EtnaREST) PlaceOCO(ctx context.Context, accId uint32, first, second *sch.OrderParams) (*OrderGroup, error) {
+	g := newOrderGroup(api, accId, GroupOCO)
+	g.addLeg(LegFirst, first, -1, 1)
+	g.addLeg(LegSecond, second, -1, 0)
+	return g, g.place(ctx)
+}
+
+// PlaceOTO places the primary order and the secondary ones linked to it as children.
+func (api *EtnaREST) PlaceOTO(ctx context.Context, accId uint32, primary *sch.OrderParams,
+	secondary ...*sch.OrderParams) (*OrderGroup, error) {
+	if len(secondary) == 0 {
+		return nil, fmt.Errorf("placeOTO failed: secondary orders are absent")
+	}
+	g := newOrderGroup(api, accId, GroupOTO)
+	g.addLeg(LegPrimary, primary, -1, -1)
+	for i, params := range secondary {
+		role := LegSecondary
+		if len(secondary) > 1 {
+			role = LegRole(fmt.Sprintf("%s#%d", LegSecondary, i+1))
+		}
+		g.addLeg(role, params, 0, -1)
+	}
+	return g, g.place(ctx)
+}
+
+func newOrderGroup(rest *EtnaREST, accId uint32, kind GroupKind) *OrderGroup {
+	return &OrderGroup{rest: rest, accId: accId, kind: kind, legs: make([]*OrderLeg, 0, 3), done: make(chan struct{})}
+}
+
+func (g *OrderGroup) addLeg(role LegRole, params *sch.OrderParams, parent, oco int) {
+	(*g).legs = append((*g).legs, &OrderLeg{Role: role, Params: *params, parent: parent, oco: oco,
+		placed: (*params).Quantity})
+}
+
+// place submits the legs, the parents go first. If any leg fails, the placed ones are cancelled.
+func (g *OrderGroup) place(ctx context.Context) error {
+	for i, leg := range (*g).legs {
+		(*g).mu.Lock()
+		if leg.parent >= 0 {
+			leg.Params.ParentId = int64((*g).legs[leg.parent].Order.Id)
+		}
+		params := leg.Params
+		(*g).mu.Unlock()
+
+		order, err := (*g).rest.PlaceOrder(ctx, (*g).accId, &params)
+		if err != nil {
+			err = fmt.Errorf("%s leg: %w", leg.Role, err)
+			if cErr := (*g).cancelLegs(ctx, (*g).legs[:i]); cErr != nil {
+				err = errors.Join(err, fmt.Errorf("rollback: %w", cErr))
+			}
+			(*g).mu.Lock()
+			(*g).setState(GroupFailed)
+			(*g).mu.Unlock()
+			return err
+		}
+		(*g).mu.Lock()
+		leg.Params.ClientId, leg.Order = order.ClientId, order
+		(*g).mu.Unlock()
+	}
+	return nil
+}
+
+// Update applies the order update to the leg with the same Id. It returns false if the order isn't in the group.
+// The sibling of the filled OCO leg is cancelled, the sibling of the partially filled one is reduced
+// to the rest of the position, so it stays protected, see ocoRequest.
+// The request to the sibling is made without the lock, so the updates of the group aren't blocked by it.
+func (g *OrderGroup) Update(ctx context.Context, order sch.Order) bool {
+	(*g).mu.Lock()
+	idx := -1
+	for i, leg := range (*g).legs {
+		if leg.Order.Id != 0 && leg.Order.Id == order.Id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		(*g).mu.Unlock()
+		return false
+	}
+	leg := (*g).legs[idx]
+	mergeOrder(&leg.Order, order)
+
+	var req *legRequest
+	if sibling := (*g).ocoSibling(leg); sibling != nil && isOrderActive(sibling.Order) {
+		req = (*g).ocoRequest(leg, sibling)
+	}
+	(*g).setState((*g).combinedState())
+	(*g).mu.Unlock()
+
+	if req != nil {
+		op := "reduce"
+		if (*req).params == nil {
+			op = "cancel"
+		}
+		if _, err := (*g).request(ctx, *req); err != nil {
+			(*g).rest.log.Error("OCO %s fault %s %d: %+v", op, (*req).leg.Role, (*req).id, err)
+		}
+	}
+	return true
+}
+
+// Run applies the updates from the channel until the group is done, the context is cancelled
+// or the channel is closed.
+// The orders of other groups are skipped, so the channel shouldn't be shared with other consumers.
+func (g *OrderGroup) Run(ctx context.Context, orders <-chan sch.Order) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-(*g).done:
+			return
+		case order, ok := <-orders:
+			if !ok {
+				return
+			}
+			(*g).Update(ctx, order)
+		}
+	}
+}
+
+// Cancel cancels all working legs, the children go first.
+func (g *OrderGroup) Cancel(ctx context.Context) error {
+	return (*g).cancelLegs(ctx, (*g).legs)
+}
+
+// Replace modifies the legs with the given roles. The parent is replaced first, and all its working children
+// are linked to the new parent order: the changed ones with the new parameters, the others with their current ones.
+// The params aren't modified.
+func (g *OrderGroup) Replace(ctx context.Context, changes map[LegRole]*sch.OrderParams) error {
+	(*g).mu.Lock()
+	for role := range changes {
+		if (*g).leg(role) == nil {
+			(*g).mu.Unlock()
+			return fmt.Errorf("replace: unknown leg %s", role)
+		}
+	}
+	(*g).mu.Unlock()
+
+	var errs []error
+	relinked := make([]bool, len((*g).legs)) // the order id of the leg has changed
+	for i := range (*g).legs {
+		req, err := (*g).replaceRequest(i, changes, relinked)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		} else if req == nil {
+			continue
+		}
+		if relinked[i], err = (*g).request(ctx, *req); err != nil {
+			errs = append(errs, fmt.Errorf("%s leg: %w", (*req).leg.Role, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replaceRequest decides the replacement of the leg, it returns nil if the leg isn't changed or relinked.
+func (g *OrderGroup) replaceRequest(i int, changes map[LegRole]*sch.OrderParams, relinked []bool) (*legRequest, error) {
+	(*g).mu.Lock()
+	defer (*g).mu.Unlock()
+
+	leg := (*g).legs[i]
+	changed, resize := changes[leg.Role]
+	var params sch.OrderParams
+	if resize {
+		params = *changed
+	} else if leg.parent >= 0 && relinked[leg.parent] && isOrderActive(leg.Order) {
+		params = leg.Params
+	} else {
+		return nil, nil
+	}
+	if !isOrderActive(leg.Order) {
+		return nil, fmt.Errorf("%s leg isn't active: %s", leg.Role, leg.Order.Status)
+	}
+	if leg.parent >= 0 {
+		params.ParentId = int64((*g).legs[leg.parent].Order.Id)
+	}
+	return &legRequest{leg: leg, id: leg.Order.Id, params: &params, resize: resize}, nil
+}
+
+// State returns the combined state of the legs.
+func (g *OrderGroup) State() GroupState {
+	(*g).mu.Lock()
+	defer (*g).mu.Unlock()
+	return (*g).state
+}
+
+// Done returns the channel, which is closed when the group is completed, cancelled or failed.
+func (g *OrderGroup) Done() <-chan struct{} {
+	return (*g).done
+}
+
+// Legs returns the copies of the legs.
+func (g *OrderGroup) Legs() []OrderLeg {
+	(*g).mu.Lock()
+	defer (*g).mu.Unlock()
+	res := make([]OrderLeg, len((*g).legs))
+	for i, leg := range (*g).legs {
+		res[i] = *leg
+	}
+	return res
+}
+
+// Kind returns the type of the group.
+func (g *OrderGroup) Kind() GroupKind {
+	return (*g).kind
+}
+
+func (g *OrderGroup) leg(role LegRole) *OrderLeg {
+	for _, leg := range (*g).legs {
+		if leg.Role == role {
+			return leg
+		}
+	}
+	return nil
+}
+
+// ocoSibling returns the leg, which is cancelled when this one is executed, or nil.
+func (g *OrderGroup) ocoSibling(leg *OrderLeg) *OrderLeg {
+	if leg.oco < 0 {
+		return nil
+	}
+	return (*g).legs[leg.oco]
+}
+
+// legRequest is the request to the leg, which is decided under the lock and made without it.
+type legRequest struct {
+	leg    *OrderLeg
+	id     uint64           // the order id of the leg when the request is decided
+	params *sch.OrderParams // the replacement of the order, the order is cancelled if nil
+	resize bool             // the replacement changes the placed quantity of the leg
+}
+
+// ocoRequest decides the request to the sibling of the executed OCO leg, it returns nil if the sibling is kept.
+// Both legs protect the placed quantity of the executed leg, so the rest of the position is reduced by
+// the executions of both of them. The sibling is cancelled if nothing is left, otherwise its quantity,
+// which includes the executed one, is reduced to cover the rest.
+func (g *OrderGroup) ocoRequest(leg, sibling *OrderLeg) *legRequest {
+	if !isExecuted(leg.Order) {
+		return nil
+	}
+	left := leg.placed - leg.Order.ExecutedQuantity - sibling.Order.ExecutedQuantity
+	if isFilled(leg.Order) || left <= 0 {
+		return &legRequest{leg: sibling, id: sibling.Order.Id}
+	}
+	quantity := sibling.Order.ExecutedQuantity + left
+	if quantity >= sibling.Params.Quantity {
+		return nil
+	}
+	params := sibling.Params
+	params.Quantity = quantity
+	if sibling.parent >= 0 {
+		params.ParentId = int64((*g).legs[sibling.parent].Order.Id)
+	}
+	return &legRequest{leg: sibling, id: sibling.Order.Id, params: &params}
+}
+
+// request makes the request without the lock and applies its result to the leg. The missing order is
+// considered cancelled. It reports whether the order id of the leg has changed.
+func (g *OrderGroup) request(ctx context.Context, req legRequest) (bool, error) {
+	if req.params == nil {
+		err := (*g).rest.CancelOrder(ctx, (*g).accId, req.id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+		(*g).mu.Lock()
+		if req.leg.Order.Id == req.id && isOrderActive(req.leg.Order) {
+			req.leg.Order.Status = sch.StatusPendingCancel.String()
+		}
+		(*g).mu.Unlock()
+		return false, nil
+	}
+
+	order, err := (*g).rest.ReplaceOrder(ctx, (*g).accId, req.id, req.params)
+	if err != nil {
+		return false, err
+	}
+	(*g).mu.Lock()
+	if req.leg.Order.Id == req.id {
+		req.leg.Params, req.leg.Order = *req.params, order
+		if req.resize {
+			req.leg.placed = req.params.Quantity
+		}
+	}
+	(*g).mu.Unlock()
+	return order.Id != req.id, nil
+}
+
+// cancelLegs cancels the working legs in the reverse order, so the children are cancelled before the parents.
+// It's called without the lock.
+func (g *OrderGroup) cancelLegs(ctx context.Context, legs []*OrderLeg) error {
+	var errs []error
+	for i := len(legs) - 1; i >= 0; i-- {
+		(*g).mu.Lock()
+		leg := legs[i]
+		req, active := legRequest{leg: leg, id: leg.Order.Id}, isOrderActive(leg.Order)
+		(*g).mu.Unlock()
+		if !active {
+			continue
+		}
+		if _, err := (*g).request(ctx, req); err != nil {
+			errs = append(errs, fmt.Errorf("%s leg: %w", leg.Role, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// combinedState calculates the group state from the legs' statuses.
+func (g *OrderGroup) combinedState() GroupState {
+	filled, final := 0, 0
+	for _, leg := range (*g).legs {
+		if st, _ := sch.ParseOrderStatus(leg.Order.Status); st == sch.StatusFilled {
+			filled++
+		}
+		if isOrderFinal(leg.Order) {
+			final++
+		}
+	}
+	switch (*g).kind {
+	case GroupBracket:
+		entry, tp, sl := (*g).legs[0].Order, (*g).legs[1].Order, (*g).legs[2].Order
+		if isOrderFinal(entry) && !isFilled(entry) {
+			return GroupCancelled
+		} else if isFilled(entry) && (isFilled(tp) || isFilled(sl)) {
+			return GroupCompleted
+		}
+	case GroupOCO:
+		if filled > 0 {
+			return GroupCompleted
+		}
+	case GroupOTO:
+		if primary := (*g).legs[0].Order; isOrderFinal(primary) && !isFilled(primary) {
+			return GroupCancelled
+		} else if filled == len((*g).legs) {
+			return GroupCompleted
+		}
+	}
+	if final == len((*g).legs) {
+		return GroupCancelled
+	}
+	return GroupActive
+}
+
+func (g *OrderGroup) setState(state GroupState) {
+	if (*g).state != GroupActive {
+		return
+	}
+	(*g).state = state
+	if state != GroupActive {
+		close((*g).done)
+	}
+}
+
+// mergeOrder copies the non-empty fields of the partial update into the order.
+func mergeOrder(dst *sch.Order, src sch.Order) {
+	if src.Symbol != "" {
+		dst.Symbol = src.Symbol
+	}
+	if src.Side != "" {
+		dst.Side = src.Side
+	}
+	if src.Type != "" {
+		dst.Type = src.Type
+	}
+	if src.ClientId != "" {
+		dst.ClientId = src.ClientId
+	}
+	if src.AccountId != 0 {
+		dst.AccountId = src.AccountId
+	}
+	if src.ParentId != 0 {
+		dst.ParentId = src.ParentId
+	}
+	if src.Status != "" {
+		dst.Status = src.Status
+	}
+	if src.Quantity != 0 {
+		dst.Quantity = src.Quantity
+	}
+	if src.Price != 0 {
+		dst.Price = src.Price
+	}
+	if src.StopPrice != 0 {
+		dst.StopPrice = src.StopPrice
+	}
+	if src.ExecutedQuantity != 0 {
+		dst.ExecutedQuantity = src.ExecutedQuantity
+	}
+	if src.LeavesQuantity != 0 {
+		dst.LeavesQuantity = src.LeavesQuantity
+	}
+	if src.AveragePrice != 0 {
+		dst.AveragePrice = src.AveragePrice
+	}
+	if src.LastPrice != 0 {
+		dst.LastPrice = src.LastPrice
+	}
+	if src.LastQuantity != 0 {
+		dst.LastQuantity = src.LastQuantity
+	}
+	if !src.TransactionDate.IsZero() {
+		dst.TransactionDate = src.TransactionDate
+	}
+	if src.Description != "" {
+		dst.Description = src.Description
+	}
+	if src.StateId != 0 {
+		dst.StateId = src.StateId
+	}
+	if src.RequestId != 0 {
+		dst.RequestId = src.RequestId
+	}
+	if src.ExecId != "" {
+		dst.ExecId = src.ExecId
+	}
+}
+
+// isOrderActive reports whether the placed order is working or may still be executed.
+func isOrderActive(o sch.Order) bool {
+	return o.Id != 0 && !isOrderFinal(o)
+}
+
+// isOrderFinal reports whether the order reached the terminal status.
+func isOrderFinal(o sch.Order) bool {
+	st, ok := sch.ParseOrderStatus(o.Status)
+	if !ok {
+		return false
+	}
+	switch st {
+	case sch.StatusFilled, sch.StatusCanceled, sch.StatusRejected, sch.StatusExpired, sch.StatusDoneForDay,
+		sch.StatusReplaced:
+		return true
+	}
+	return false
+}
+
+func isFilled(o sch.Order) bool {
+	st, _ := sch.ParseOrderStatus(o.Status)
+	return st == sch.StatusFilled
+}
+
+// isExecuted reports whether the order is filled at least partially.
+func isExecuted(o sch.Order) bool {
+	st, _ := sch.ParseOrderStatus(o.Status)
+	return st == sch.StatusFilled || st == sch.StatusPartiallyFilled
+}
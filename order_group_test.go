@@ -0,0 +1,166 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+// stubExchange serves the order endpoints of account 1, the replacement gets the new order id.
+type stubExchange struct {
+	mu     sync.Mutex
+	lastId uint64
+	reqs   []string // "METHOD id quantity parentId" of the requests
+	group  *OrderGroup
+	locked bool // a request is made with the lock of the group
+}
+
+func (e *stubExchange) routes() map[string]http.HandlerFunc {
+	order := func(w http.ResponseWriter, r *http.Request) {
+		var params sch.OrderParams
+		if err := gjson.NewDecoder(r.Body).Decode(&params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id := (*e).record(r, fmt.Sprintf("%g %d", params.Quantity, params.ParentId))
+		body, _ := gjson.Marshal(sch.Order{Id: id, ClientId: params.ClientId, Symbol: params.Symbol,
+			Quantity: params.Quantity, ParentId: params.ParentId, Status: sch.StatusNew.String()})
+		_, _ = w.Write(body)
+	}
+	return map[string]http.HandlerFunc{
+		"POST /v1.0/accounts/1/orders":        order,
+		"PUT /v1.0/accounts/1/orders/{id}":    order,
+		"DELETE /v1.0/accounts/1/orders/{id}": func(w http.ResponseWriter, r *http.Request) { (*e).record(r, "") },
+	}
+}
+
+// record saves the request and returns the id of the new order.
+func (e *stubExchange) record(r *http.Request, params string) uint64 {
+	(*e).mu.Lock()
+	defer (*e).mu.Unlock()
+	if g := (*e).group; g != nil {
+		if !(*g).mu.TryLock() {
+			(*e).locked = true
+		} else {
+			(*g).mu.Unlock()
+		}
+	}
+	if id := r.PathValue("id"); id != "" {
+		(*e).reqs = append((*e).reqs, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, id, params)))
+	}
+	(*e).lastId++
+	return (*e).lastId
+}
+
+// requests returns the recorded requests and resets them.
+func (e *stubExchange) requests() []string {
+	(*e).mu.Lock()
+	defer (*e).mu.Unlock()
+	res := (*e).reqs
+	(*e).reqs = nil
+	return res
+}
+
+func TestOrderGroupOCO(t *testing.T) {
+	partial, filled := sch.StatusPartiallyFilled.String(), sch.StatusFilled.String()
+	tests := map[string]struct {
+		updates []sch.Order
+		expect  []string
+		state   GroupState
+	}{
+		"filled": {
+			updates: []sch.Order{{Id: 1, Status: filled, ExecutedQuantity: 10}},
+			expect:  []string{"DELETE 2"},
+			state:   GroupCompleted},
+		"partially_filled": {
+			updates: []sch.Order{{Id: 1, Status: partial, ExecutedQuantity: 4}},
+			expect:  []string{"PUT 2 6 0"}},
+		"repeated": {
+			updates: []sch.Order{{Id: 1, Status: partial, ExecutedQuantity: 4}, {Id: 1, Status: partial, ExecutedQuantity: 4}},
+			expect:  []string{"PUT 2 6 0"}},
+		"both_executed": {
+			// the second leg reduces the first one to 7 (the new order 3), then both executions are subtracted
+			updates: []sch.Order{{Id: 2, Status: partial, ExecutedQuantity: 3}, {Id: 3, Status: partial, ExecutedQuantity: 2}},
+			expect:  []string{"PUT 1 7 0", "PUT 2 8 0"}},
+		"nothing_left": {
+			updates: []sch.Order{{Id: 2, Status: partial, ExecutedQuantity: 6}, {Id: 3, Status: partial, ExecutedQuantity: 4}},
+			expect:  []string{"PUT 1 4 0", "DELETE 2"}},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			var e stubExchange
+			rest := newStubREST(t, e.routes())
+			params := sch.OrderParams{Symbol: "AAPL", Quantity: 10, Side: sch.SideSell, Type: sch.OrderLimit, Price: 110}
+			stop := params
+			stop.Type, stop.Price, stop.StopPrice = sch.OrderStop, 0, 90
+
+			g, err := rest.PlaceOCO(context.Background(), 1, &params, &stop)
+			if err != nil {
+				(*t).Fatal(err)
+			}
+			e.mu.Lock()
+			e.group = g
+			e.mu.Unlock()
+			for _, upd := range tc.updates {
+				if !g.Update(context.Background(), upd) {
+					(*t).Fatalf("the order %d isn't in the group", upd.Id)
+				}
+			}
+			if reqs := e.requests(); strings.Join(reqs, ";") != strings.Join(tc.expect, ";") {
+				(*t).Errorf("wrong requests: %v", reqs)
+			} else if g.State() != tc.state {
+				(*t).Errorf("wrong state: %s", g.State())
+			} else if e.locked {
+				(*t).Error("the request is made with the lock")
+			}
+		})
+	}
+}
+
+func TestOrderGroupReplace(t *testing.T) {
+	var e stubExchange
+	rest := newStubREST(t, e.routes())
+	entry := sch.OrderParams{Symbol: "AAPL", Quantity: 10, Side: sch.SideBuy, Type: sch.OrderLimit, Price: 100}
+	tp := sch.OrderParams{Symbol: "AAPL", Quantity: 10, Side: sch.SideSell, Type: sch.OrderLimit, Price: 110}
+	sl := sch.OrderParams{Symbol: "AAPL", Quantity: 10, Side: sch.SideSell, Type: sch.OrderStop, StopPrice: 90}
+	g, err := rest.PlaceBracket(context.Background(), 1, &entry, &tp, &sl)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	e.requests()
+	e.mu.Lock()
+	e.group = g
+	e.mu.Unlock()
+
+	// the new entry order 4 is the parent of the replaced exits
+	entry.Price = 101
+	if err = g.Replace(context.Background(), map[LegRole]*sch.OrderParams{LegEntry: &entry}); err != nil {
+		(*t).Fatal(err)
+	}
+	expect := "PUT 1 10 0;PUT 2 10 4;PUT 3 10 4"
+	if reqs := e.requests(); strings.Join(reqs, ";") != expect {
+		(*t).Errorf("wrong requests: %v", reqs)
+	}
+	for _, leg := range g.Legs()[1:] {
+		if leg.Params.ParentId != 4 || leg.Order.ParentId != 4 {
+			(*t).Errorf("%s leg isn't relinked: %d", leg.Role, leg.Params.ParentId)
+		}
+	}
+
+	// the unchanged parent keeps the children
+	tp.Price = 111
+	if err = g.Replace(context.Background(), map[LegRole]*sch.OrderParams{LegTakeProfit: &tp}); err != nil {
+		(*t).Fatal(err)
+	}
+	if reqs := e.requests(); strings.Join(reqs, ";") != "PUT 5 10 4" {
+		(*t).Errorf("wrong requests: %v", reqs)
+	} else if e.locked {
+		(*t).Error("the request is made with the lock")
+	}
+}
@@ -0,0 +1,344 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+var (
+	ErrStaleUpdate       = errors.New("stale order update")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrOrderNotFilled    = errors.New("order is done without being filled")
+)
+
+// DefaultFinalOrderTTL is the default time the orders are kept by OrderManager after reaching the terminal status.
+const DefaultFinalOrderTTL = time.Hour
+
+// FillEvent describes the execution of the order.
+type FillEvent struct {
+	OrderId          uint64
+	ClientId         string
+	Symbol           string
+	Side             sch.OrderSide
+	Price            float64 // the price of the last execution
+	Quantity         float64 // the quantity of the last execution
+	ExecutedQuantity float64 // the total executed quantity of the order
+	LeavesQuantity   float64
+	AveragePrice     float64
+	Status           string
+	StateId          int64 // the server sequence of the order state, it orders the fills of the order
+	Time             time.Time
+}
+
+// orderTransitions lists the statuses reachable from the status, the repeated status is always allowed.
+var orderTransitions = map[sch.OrderStatus][]sch.OrderStatus{
+	sch.StatusPendingNew: {sch.StatusNew, sch.StatusPartiallyFilled, sch.StatusFilled, sch.StatusRejected,
+		sch.StatusCanceled, sch.StatusPendingCancel, sch.StatusExpired, sch.StatusSuspended},
+	sch.StatusNew: {sch.StatusPartiallyFilled, sch.StatusFilled, sch.StatusCanceled, sch.StatusRejected,
+		sch.StatusPendingCancel, sch.StatusPendingReplace, sch.StatusReplaced, sch.StatusExpired,
+		sch.StatusDoneForDay, sch.StatusStopped, sch.StatusSuspended},
+	sch.StatusPartiallyFilled: {sch.StatusFilled, sch.StatusCanceled, sch.StatusPendingCancel,
+		sch.StatusPendingReplace, sch.StatusReplaced, sch.StatusExpired, sch.StatusDoneForDay, sch.StatusStopped},
+	sch.StatusPendingCancel: {sch.StatusCanceled, sch.StatusNew, sch.StatusPartiallyFilled, sch.StatusFilled},
+	sch.StatusPendingReplace: {sch.StatusReplaced, sch.StatusNew, sch.StatusPartiallyFilled, sch.StatusFilled,
+		sch.StatusCanceled, sch.StatusRejected},
+	sch.StatusSuspended:  {sch.StatusNew, sch.StatusPartiallyFilled, sch.StatusCanceled, sch.StatusPendingCancel},
+	sch.StatusStopped:    {sch.StatusFilled, sch.StatusCanceled},
+	sch.StatusDoneForDay: {sch.StatusNew, sch.StatusExpired, sch.StatusCanceled},
+}
+
+// NewOrderManager creates the manager of the account's orders. Call Seed to load the active orders
+// and Run (or Apply) to process the updates from EtnaWS.OrdersChan.
+func NewOrderManager(rest *EtnaREST, accId uint32, logger Logger) *OrderManager {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	return &OrderManager{
+		rest:     rest,
+		accId:    accId,
+		log:      logger,
+		orders:   make(map[uint64]*sch.Order),
+		doneAt:   make(map[uint64]time.Time),
+		finalTTL: DefaultFinalOrderTTL,
+		changed:  make(chan struct{}),
+		fills:    make(chan FillEvent, 1000),
+	}
+}
+
+// OrderManager keeps the current state of the orders merging the partial WebSocket updates.
+// It's safe for the concurrent use.
+type OrderManager struct {
+	rest    *EtnaREST
+	accId   uint32
+	log     Logger
+	mu      sync.RWMutex
+	orders  map[uint64]*sch.Order
+	changed chan struct{} // closed and replaced on every change to wake up the waiters
+	fills   chan FillEvent
+	dropped atomic.Uint64 // the number of the dropped fill events
+
+	doneAt    map[uint64]time.Time // the time the orders reached the terminal status
+	finalTTL  time.Duration
+	lastSweep time.Time
+}
+
+// SetFinalTTL sets the time the orders are kept after reaching the terminal status, DefaultFinalOrderTTL
+// by default. The expired orders are removed by the next update, 0 keeps them until Forget.
+func (m *OrderManager) SetFinalTTL(ttl time.Duration) {
+	(*m).mu.Lock()
+	(*m).finalTTL = ttl
+	(*m).mu.Unlock()
+}
+
+// Seed loads the active orders of the account. The known orders are replaced unless their state is newer.
+func (m *OrderManager) Seed(ctx context.Context) error {
+	orders, err := (*m).rest.GetOrders(ctx, (*m).accId, true)
+	if err != nil {
+		return fmt.Errorf("order manager seed: %w", err)
+	}
+	(*m).mu.Lock()
+	now := time.Now()
+	for i := range orders {
+		order := orders[i]
+		if known, exist := (*m).orders[order.Id]; !exist || !isStale(order, *known) {
+			(*m).orders[order.Id] = &order
+			(*m).trackFinal(&order, now)
+		}
+	}
+	(*m).evict(now)
+	(*m).notify()
+	(*m).mu.Unlock()
+	return nil
+}
+
+// Run applies the updates from the channel until the context is cancelled or the channel is closed.
+func (m *OrderManager) Run(ctx context.Context, orders <-chan sch.Order) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case order, ok := <-orders:
+			if !ok {
+				return
+			} else if err := (*m).Apply(order); err != nil {
+				(*m).log.Error("order manager: %+v", err)
+			}
+		}
+	}
+}
+
+// Apply merges the partial order update into the known state. The updates older than the known state
+// (by StateId) and the invalid status transitions are rejected with ErrStaleUpdate and ErrInvalidTransition.
+// The redelivered execution (with the same ExecId or StateId) doesn't emit the FillEvent again.
+func (m *OrderManager) Apply(update sch.Order) error {
+	if update.Id == 0 {
+		return fmt.Errorf("order update without id: %+v", update)
+	}
+	(*m).mu.Lock()
+	defer (*m).mu.Unlock()
+
+	order, exist := (*m).orders[update.Id]
+	if !exist {
+		order = &sch.Order{Id: update.Id}
+		(*m).orders[update.Id] = order
+	} else if isStale(update, *order) {
+		return fmt.Errorf("%w: order %d state %d/%d < %d/%d", ErrStaleUpdate, update.Id,
+			update.StateId, update.RequestId, order.StateId, order.RequestId)
+	} else if update.Status != "" && !isValidTransition(order.Status, update.Status) {
+		return fmt.Errorf("%w: order %d %s -> %s", ErrInvalidTransition, update.Id, order.Status, update.Status)
+	}
+
+	isFill := update.LastQuantity > 0 && !isRedelivered(update, *order) &&
+		(update.ExecutedQuantity == 0 || update.ExecutedQuantity > order.ExecutedQuantity)
+	mergeOrder(order, update)
+	if isFill {
+		(*m).emitFill(*order, update)
+	}
+	now := time.Now()
+	(*m).trackFinal(order, now)
+	(*m).evict(now)
+	(*m).notify()
+	return nil
+}
+
+// isRedelivered reports whether the update repeats the known execution: it has the same execution id
+// or, without the id, the same state.
+func isRedelivered(update, known sch.Order) bool {
+	if update.ExecId != "" {
+		return update.ExecId == known.ExecId
+	}
+	return update.StateId != 0 && update.StateId == known.StateId && update.RequestId == known.RequestId
+}
+
+// Get returns the state of the order.
+func (m *OrderManager) Get(id uint64) (sch.Order, bool) {
+	(*m).mu.RLock()
+	defer (*m).mu.RUnlock()
+	if order, exist := (*m).orders[id]; exist {
+		return *order, true
+	}
+	return sch.Order{}, false
+}
+
+// Snapshot returns the copies of all known orders sorted by Id.
+func (m *OrderManager) Snapshot() []sch.Order {
+	(*m).mu.RLock()
+	res := make([]sch.Order, 0, len((*m).orders))
+	for _, order := range (*m).orders {
+		res = append(res, *order)
+	}
+	(*m).mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res
+}
+
+// Active returns the copies of the working orders sorted by Id.
+func (m *OrderManager) Active() []sch.Order {
+	res := (*m).Snapshot()
+	active := res[:0]
+	for _, order := range res {
+		if !isOrderFinal(order) {
+			active = append(active, order)
+		}
+	}
+	return active
+}
+
+// Forget removes the order from the manager, e.g. after it's done and processed.
+func (m *OrderManager) Forget(id uint64) {
+	(*m).mu.Lock()
+	delete((*m).orders, id)
+	delete((*m).doneAt, id)
+	(*m).mu.Unlock()
+}
+
+// Fills returns the channel of the order executions. The events are dropped if the channel is full,
+// they're logged and counted by DroppedFills.
+func (m *OrderManager) Fills() <-chan FillEvent {
+	return (*m).fills
+}
+
+// DroppedFills returns the number of the fill events dropped since the Fills channel was full.
+func (m *OrderManager) DroppedFills() uint64 {
+	return (*m).dropped.Load()
+}
+
+// WaitFilled blocks until the order is filled. It returns ErrOrderNotFilled if the order is done
+// with another status, e.g. cancelled or rejected.
+func (m *OrderManager) WaitFilled(ctx context.Context, id uint64) (sch.Order, error) {
+	order, err := (*m).WaitDone(ctx, id)
+	if err == nil && !isFilled(order) {
+		return order, fmt.Errorf("%w: %d %s", ErrOrderNotFilled, id, order.Status)
+	}
+	return order, err
+}
+
+// WaitDone blocks until the order reaches the terminal status or the context is cancelled.
+func (m *OrderManager) WaitDone(ctx context.Context, id uint64) (sch.Order, error) {
+	return (*m).WaitFor(ctx, id, isOrderFinal)
+}
+
+// WaitFor blocks until the order state satisfies the condition or the context is cancelled.
+func (m *OrderManager) WaitFor(ctx context.Context, id uint64, cond func(order sch.Order) bool) (sch.Order, error) {
+	for {
+		(*m).mu.RLock()
+		order, exist := (*m).orders[id]
+		changed := (*m).changed
+		var state sch.Order
+		if exist {
+			state = *order
+		}
+		(*m).mu.RUnlock()
+
+		if exist && cond(state) {
+			return state, nil
+		}
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes up the waiters, the caller must hold the write lock.
+func (m *OrderManager) notify() {
+	close((*m).changed)
+	(*m).changed = make(chan struct{})
+}
+
+func (m *OrderManager) emitFill(order, update sch.Order) {
+	ts := update.TransactionDate
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	evt := FillEvent{
+		OrderId: order.Id, ClientId: order.ClientId, Symbol: order.Symbol, Side: order.Side,
+		Price: update.LastPrice, Quantity: update.LastQuantity, ExecutedQuantity: order.ExecutedQuantity,
+		LeavesQuantity: order.LeavesQuantity, AveragePrice: order.AveragePrice, Status: order.Status,
+		StateId: update.StateId, Time: ts,
+	}
+	select {
+	case (*m).fills <- evt:
+	default:
+		(*m).log.Error("order manager: fill event #%d is dropped %+v", (*m).dropped.Add(1), evt)
+	}
+}
+
+// trackFinal records the time the order reached the terminal status, the caller must hold the write lock.
+func (m *OrderManager) trackFinal(order *sch.Order, now time.Time) {
+	if !isOrderFinal(*order) {
+		delete((*m).doneAt, order.Id)
+	} else if _, exist := (*m).doneAt[order.Id]; !exist {
+		(*m).doneAt[order.Id] = now
+	}
+}
+
+// evict removes the orders, which are done longer than finalTTL ago. The orders are swept at most
+// 10 times per TTL. The caller must hold the write lock.
+func (m *OrderManager) evict(now time.Time) {
+	if (*m).finalTTL <= 0 || now.Sub((*m).lastSweep) < (*m).finalTTL/10 {
+		return
+	}
+	(*m).lastSweep = now
+	for id, ts := range (*m).doneAt {
+		if now.Sub(ts) >= (*m).finalTTL {
+			delete((*m).orders, id)
+			delete((*m).doneAt, id)
+		}
+	}
+}
+
+// isStale reports whether the update is older than the known order state by StateId, then by RequestId.
+func isStale(update, known sch.Order) bool {
+	if update.StateId != 0 && known.StateId != 0 && update.StateId != known.StateId {
+		return update.StateId < known.StateId
+	}
+	return update.RequestId != 0 && known.RequestId != 0 && update.RequestId < known.RequestId
+}
+
+// isValidTransition reports whether the order status can be changed from `from` to `to`.
+// The unknown statuses aren't restricted.
+func isValidTransition(from, to string) bool {
+	if from == "" || from == to {
+		return true
+	}
+	stFrom, okFrom := sch.ParseOrderStatus(from)
+	stTo, okTo := sch.ParseOrderStatus(to)
+	if !okFrom || !okTo {
+		return true
+	}
+	for _, st := range orderTransitions[stFrom] {
+		if st == stTo {
+			return true
+		}
+	}
+	return false
+}
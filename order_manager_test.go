@@ -0,0 +1,188 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestIsValidTransition(t *testing.T) {
+	tests := map[string]struct {
+		from, to sch.OrderStatus
+		expect   bool
+	}{
+		"new_filled":         {from: sch.StatusNew, to: sch.StatusFilled, expect: true},
+		"new_partial":        {from: sch.StatusNew, to: sch.StatusPartiallyFilled, expect: true},
+		"partial_filled":     {from: sch.StatusPartiallyFilled, to: sch.StatusFilled, expect: true},
+		"partial_new":        {from: sch.StatusPartiallyFilled, to: sch.StatusNew},
+		"pending_cancel_new": {from: sch.StatusPendingCancel, to: sch.StatusNew, expect: true},
+		"repeated":           {from: sch.StatusFilled, to: sch.StatusFilled, expect: true},
+		"filled_canceled":    {from: sch.StatusFilled, to: sch.StatusCanceled},
+		"canceled_new":       {from: sch.StatusCanceled, to: sch.StatusNew},
+		"rejected_filled":    {from: sch.StatusRejected, to: sch.StatusFilled},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := isValidTransition(tc.from.String(), tc.to.String()); res != tc.expect {
+				(*t).Errorf("wrong result: %t", res)
+			}
+		})
+	}
+	if !isValidTransition("", sch.StatusFilled.String()) || !isValidTransition("Unknown", sch.StatusNew.String()) {
+		(*t).Error("the empty and unknown statuses are restricted")
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	tests := map[string]struct {
+		update, known sch.Order
+		expect        bool
+	}{
+		"older_state":   {update: sch.Order{StateId: 1}, known: sch.Order{StateId: 2}, expect: true},
+		"newer_state":   {update: sch.Order{StateId: 3}, known: sch.Order{StateId: 2}},
+		"no_state":      {update: sch.Order{}, known: sch.Order{StateId: 2}},
+		"older_request": {update: sch.Order{StateId: 2, RequestId: 1}, known: sch.Order{StateId: 2, RequestId: 5}, expect: true},
+		"same":          {update: sch.Order{StateId: 2, RequestId: 5}, known: sch.Order{StateId: 2, RequestId: 5}},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := isStale(tc.update, tc.known); res != tc.expect {
+				(*t).Errorf("wrong result: %t", res)
+			}
+		})
+	}
+}
+
+func TestOrderManagerApply(t *testing.T) {
+	type step struct {
+		update sch.Order
+		err    error
+	}
+	tests := map[string]struct {
+		steps    []step
+		status   sch.OrderStatus
+		executed float64
+		fills    int
+	}{
+		"filled": {
+			steps: []step{
+				{update: sch.Order{Id: 1, StateId: 1, Symbol: "AAPL", Side: sch.SideBuy, Quantity: 10, Status: sch.StatusNew.String()}},
+				{update: sch.Order{Id: 1, StateId: 2, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4, LastPrice: 100, ExecutedQuantity: 4}},
+				{update: sch.Order{Id: 1, StateId: 3, Status: sch.StatusFilled.String(), LastQuantity: 6, LastPrice: 101, ExecutedQuantity: 10}},
+			},
+			status: sch.StatusFilled, executed: 10, fills: 2},
+		"stale": {
+			steps: []step{
+				{update: sch.Order{Id: 1, StateId: 2, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4, ExecutedQuantity: 4}},
+				{update: sch.Order{Id: 1, StateId: 1, Status: sch.StatusNew.String()}, err: ErrStaleUpdate},
+			},
+			status: sch.StatusPartiallyFilled, executed: 4, fills: 1},
+		"invalid_transition": {
+			steps: []step{
+				{update: sch.Order{Id: 1, Status: sch.StatusCanceled.String()}},
+				{update: sch.Order{Id: 1, Status: sch.StatusFilled.String(), LastQuantity: 1, ExecutedQuantity: 1}, err: ErrInvalidTransition},
+			},
+			status: sch.StatusCanceled},
+		"repeated_fill": {
+			steps: []step{
+				{update: sch.Order{Id: 1, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4, ExecutedQuantity: 4}},
+				{update: sch.Order{Id: 1, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4, ExecutedQuantity: 4}},
+			},
+			status: sch.StatusPartiallyFilled, executed: 4, fills: 1},
+		"redelivered_state": {
+			steps: []step{
+				{update: sch.Order{Id: 1, StateId: 2, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+				{update: sch.Order{Id: 1, StateId: 2, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+				{update: sch.Order{Id: 1, StateId: 3, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+			},
+			status: sch.StatusPartiallyFilled, fills: 2},
+		"redelivered_execution": {
+			steps: []step{
+				{update: sch.Order{Id: 1, ExecId: "e1", Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+				{update: sch.Order{Id: 1, ExecId: "e1", Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+				{update: sch.Order{Id: 1, ExecId: "e2", Status: sch.StatusPartiallyFilled.String(), LastQuantity: 4}},
+			},
+			status: sch.StatusPartiallyFilled, fills: 2},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			m := NewOrderManager(nil, 1, nil)
+			for i, s := range tc.steps {
+				if err := m.Apply(s.update); !errors.Is(err, s.err) {
+					(*t).Fatalf("step %d: wrong error: %v", i, err)
+				}
+			}
+			order, _ := m.Get(1)
+			if order.Status != tc.status.String() || order.ExecutedQuantity != tc.executed {
+				(*t).Errorf("wrong order: %s %g", order.Status, order.ExecutedQuantity)
+			} else if len(m.Fills()) != tc.fills {
+				(*t).Errorf("wrong number of fills: %d", len(m.Fills()))
+			}
+		})
+	}
+}
+
+func TestOrderManagerDroppedFills(t *testing.T) {
+	m := NewOrderManager(nil, 1, nil)
+	(*m).fills = make(chan FillEvent, 1)
+	for i := 1; i <= 3; i++ {
+		update := sch.Order{Id: 1, Status: sch.StatusPartiallyFilled.String(), LastQuantity: 1, ExecutedQuantity: float64(i)}
+		if err := m.Apply(update); err != nil {
+			(*t).Fatal(err)
+		}
+	}
+	if dropped := m.DroppedFills(); dropped != 2 {
+		(*t).Errorf("wrong number of dropped fills: %d", dropped)
+	}
+}
+
+func TestOrderManagerEvict(t *testing.T) {
+	m := NewOrderManager(nil, 1, nil)
+	m.SetFinalTTL(20 * time.Millisecond)
+	if err := m.Apply(sch.Order{Id: 1, Status: sch.StatusCanceled.String()}); err != nil {
+		(*t).Fatal(err)
+	}
+	if err := m.Apply(sch.Order{Id: 2, Status: sch.StatusNew.String()}); err != nil {
+		(*t).Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := m.Apply(sch.Order{Id: 3, Status: sch.StatusNew.String()}); err != nil {
+		(*t).Fatal(err)
+	}
+	if _, exist := m.Get(1); exist {
+		(*t).Error("the final order isn't evicted")
+	}
+	if _, exist := m.Get(2); !exist {
+		(*t).Error("the working order is evicted")
+	}
+}
+
+func TestOrderManagerWaitFilled(t *testing.T) {
+	tests := map[string]struct {
+		final sch.OrderStatus
+		err   error
+	}{
+		"filled":   {final: sch.StatusFilled},
+		"canceled": {final: sch.StatusCanceled, err: ErrOrderNotFilled},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			m := NewOrderManager(nil, 1, nil)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				_ = m.Apply(sch.Order{Id: 1, Status: sch.StatusNew.String()})
+				_ = m.Apply(sch.Order{Id: 1, Status: tc.final.String()})
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if order, err := m.WaitFilled(ctx, 1); !errors.Is(err, tc.err) {
+				(*t).Errorf("wrong error: %v", err)
+			} else if order.Status != tc.final.String() {
+				(*t).Errorf("wrong status: %s", order.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+const (
+	PageSize = 100   // the number of items requested per page
+	MaxPages = 10000 // the iteration fails after this number of pages, it guards against the endless pagination
+)
+
+// ErrPaginationLoop stops the iteration, which doesn't end: the next page link repeats or MaxPages is exceeded.
+var ErrPaginationLoop = errors.New("pagination doesn't end")
+
+// pageFetcher requests the page of items, it returns the items, the total count and the link to the next page.
+type pageFetcher[T any] func(ctx context.Context, qry url.Values) ([]T, uint32, string, error)
+
+// PageIter iterates over the items of the paginated API resource, requesting the pages on demand.
+//
+//	it := rest.OrdersIter(ctx, accId, NewOrderFilter().Symbol("AAPL"))
+//	for it.Next() {
+//		order := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type PageIter[T any] struct {
+	ctx     context.Context
+	fetch   pageFetcher[T]
+	qry     url.Values
+	page    int
+	maxPage int
+	link    string // the link to the next page of the last fetched page
+	items   []T
+	idx     int
+	fetched uint32
+	item    T
+	done    bool
+	err     error
+}
+
+func newPageIter[T any](ctx context.Context, qry url.Values, fetch pageFetcher[T]) *PageIter[T] {
+	qry.Set("pageSize", strconv.Itoa(PageSize))
+	return &PageIter[T]{ctx: ctx, fetch: fetch, qry: qry, maxPage: MaxPages}
+}
+
+// Next advances the iterator to the next item, requesting the next page if needed.
+// It returns false when the items are exhausted or an error occurred, e.g. ErrPaginationLoop.
+func (it *PageIter[T]) Next() bool {
+	if (*it).idx < len((*it).items) {
+		(*it).item = (*it).items[(*it).idx]
+		(*it).idx++
+		return true
+	} else if (*it).done {
+		return false
+	}
+
+	if (*it).page >= (*it).maxPage {
+		(*it).err = fmt.Errorf("%w: more than %d pages", ErrPaginationLoop, (*it).maxPage)
+		(*it).done = true
+		return false
+	}
+	(*it).qry.Set("pageNumber", strconv.Itoa((*it).page))
+	items, total, nextLink, err := (*it).fetch((*it).ctx, (*it).qry)
+	if err == nil && nextLink != "" && nextLink == (*it).link {
+		err = fmt.Errorf("%w: page %d links to %s again", ErrPaginationLoop, (*it).page, nextLink)
+	}
+	if err != nil {
+		(*it).err = err
+		(*it).done = true
+		return false
+	}
+	(*it).page++
+	(*it).link = nextLink
+	(*it).fetched += uint32(len(items))
+	(*it).items, (*it).idx = items, 0
+	more := nextLink != "" || (*it).fetched < total || (total == 0 && len(items) == PageSize)
+	(*it).done = len(items) == 0 || !more
+	return (*it).Next()
+}
+
+// Item returns the current item.
+func (it *PageIter[T]) Item() T {
+	return (*it).item
+}
+
+// Err returns the error, which stopped the iteration.
+func (it *PageIter[T]) Err() error {
+	return (*it).err
+}
+
+// All collects the remaining items.
+func (it *PageIter[T]) All() ([]T, error) {
+	res := make([]T, 0, len((*it).items))
+	for (*it).Next() {
+		res = append(res, (*it).Item())
+	}
+	return res, (*it).Err()
+}
+
+// OrdersIter returns the iterator over the orders of the account selected by the filter (nil selects all).
+// The orders are sorted by creation date unless the filter sets another sorting.
+func (api *EtnaREST) OrdersIter(ctx context.Context, accId uint32, filter *OrderFilter) *PageIter[sch.Order] {
+	qry := url.Values{"sortField": {"CreateDate"}, "desc": {"false"}}
+	if filter != nil {
+		if err := filter.Err(); err != nil {
+			return &PageIter[sch.Order]{err: err, done: true}
+		}
+		filter.apply(qry, "sortField", "desc")
+	}
+	endpoint := fmt.Sprintf("v1.0/accounts/%d/orders", accId)
+	return newPageIter(ctx, qry, func(ctx context.Context, qry url.Values) ([]sch.Order, uint32, string, error) {
+		var resp sch.RespOrders
+		err := (*api).callAPI(ctx, http.MethodGet, endpoint, qry, nil, &resp, false)
+		return resp.Result, resp.TotalCount, resp.NextPageLink, err
+	})
+}
+
+// PositionsIter returns the iterator over the positions of the account selected by the filter (nil selects all).
+// The positions are sorted by symbol unless the filter sets another sorting.
+func (api *EtnaREST) PositionsIter(ctx context.Context, accId uint32, filter *PositionFilter) *PageIter[sch.Position] {
+	qry := url.Values{"sortField": {"Symbol"}, "desc": {"false"}}
+	if filter != nil {
+		if err := filter.Err(); err != nil {
+			return &PageIter[sch.Position]{err: err, done: true}
+		}
+		filter.apply(qry, "sortField", "desc")
+	}
+	endpoint := fmt.Sprintf("v1.0/accounts/%d/positions", accId)
+	return newPageIter(ctx, qry, func(ctx context.Context, qry url.Values) ([]sch.Position, uint32, string, error) {
+		var resp sch.RespPositions
+		err := (*api).callAPI(ctx, http.MethodGet, endpoint, qry, nil, &resp, false)
+		return resp.Result, resp.TotalCount, resp.NextPageLink, err
+	})
+}
+
+// TransfersIter returns the iterator over the transfers of the account selected by the filter (nil selects all).
+// The transfers are sorted by transfer date unless the filter sets another sorting.
+func (api *EtnaREST) TransfersIter(ctx context.Context, accId uint32, filter *TransferFilter) *PageIter[sch.Transfer] {
+	qry := url.Values{"sortBy": {"TransferDate"}, "isDesc": {"false"}}
+	if filter != nil {
+		if err := filter.Err(); err != nil {
+			return &PageIter[sch.Transfer]{err: err, done: true}
+		}
+		filter.apply(qry, "sortBy", "isDesc")
+	}
+	endpoint := fmt.Sprintf("v1.0/accounts/%d/transfers", accId)
+	return newPageIter(ctx, qry, func(ctx context.Context, qry url.Values) ([]sch.Transfer, uint32, string, error) {
+		var resp sch.RespTransfers
+		err := (*api).callAPI(ctx, http.MethodGet, endpoint, qry, nil, &resp, false)
+		return resp.Result, resp.TotalCount, resp.NextPageLink, err
+	})
+}
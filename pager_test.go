@@ -0,0 +1,80 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// stubPage returns the page of `n` items.
+func stubPage(n int, total uint32, link string, err error) func() ([]int, uint32, string, error) {
+	return func() ([]int, uint32, string, error) {
+		return make([]int, n), total, link, err
+	}
+}
+
+func TestPageIter(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	tests := map[string]struct {
+		pages     []func() ([]int, uint32, string, error) // the last page repeats
+		maxPage   int
+		items     int
+		fetched   int
+		expectErr error
+	}{
+		"total": {
+			pages: []func() ([]int, uint32, string, error){stubPage(PageSize, 250, "", nil),
+				stubPage(PageSize, 250, "", nil), stubPage(50, 250, "", nil)},
+			items: 250, fetched: 3},
+		"next_link": {
+			pages: []func() ([]int, uint32, string, error){stubPage(10, 0, "p1", nil), stubPage(10, 0, "p2", nil),
+				stubPage(5, 0, "", nil)},
+			items: 25, fetched: 3},
+		"full_pages": {
+			pages: []func() ([]int, uint32, string, error){stubPage(PageSize, 0, "", nil),
+				stubPage(PageSize, 0, "", nil), stubPage(0, 0, "", nil)},
+			items: 200, fetched: 3},
+		"short_page": {
+			pages: []func() ([]int, uint32, string, error){stubPage(PageSize, 0, "", nil), stubPage(40, 0, "", nil)},
+			items: 140, fetched: 2},
+		"endless_pages": {
+			pages:   []func() ([]int, uint32, string, error){stubPage(PageSize, 0, "", nil)},
+			maxPage: 5, items: 500, fetched: 5, expectErr: ErrPaginationLoop},
+		"repeated_link": {
+			pages: []func() ([]int, uint32, string, error){stubPage(10, 0, "p1", nil)},
+			items: 10, fetched: 2, expectErr: ErrPaginationLoop},
+		"failed_page": {
+			pages: []func() ([]int, uint32, string, error){stubPage(PageSize, 300, "", nil),
+				stubPage(0, 0, "", errFetch)},
+			items: 100, fetched: 2, expectErr: errFetch},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			var requested []string
+			it := newPageIter(context.Background(), url.Values{},
+				func(_ context.Context, qry url.Values) ([]int, uint32, string, error) {
+					requested = append(requested, qry.Get("pageNumber"))
+					return tc.pages[min(len(requested), len(tc.pages))-1]()
+				})
+			if tc.maxPage > 0 {
+				(*it).maxPage = tc.maxPage
+			}
+			items, err := it.All()
+			if !errors.Is(err, tc.expectErr) {
+				(*t).Errorf("wrong error: %v", err)
+			} else if len(items) != tc.items {
+				(*t).Errorf("wrong number of items: %d", len(items))
+			} else if len(requested) != tc.fetched {
+				(*t).Errorf("wrong number of requests: %d", len(requested))
+			}
+			for i, page := range requested {
+				if page != strconv.Itoa(i) {
+					(*t).Errorf("wrong page numbers: %v", requested)
+					break
+				}
+			}
+		})
+	}
+}
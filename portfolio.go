@@ -0,0 +1,533 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// PortfolioEventKind is the kind of the portfolio change.
+type PortfolioEventKind uint8
+
+const (
+	PortfolioPositionChanged PortfolioEventKind = iota // the position quantity or average price changed
+	PortfolioPriceChanged                              // the position is marked to a new price
+	PortfolioBalanceChanged                            // the cash balance changed
+	PortfolioDrift                                     // the reconciliation found the local state differs from REST
+)
+
+func (k PortfolioEventKind) String() string {
+	switch k {
+	case PortfolioPositionChanged:
+		return "PositionChanged"
+	case PortfolioPriceChanged:
+		return "PriceChanged"
+	case PortfolioBalanceChanged:
+		return "BalanceChanged"
+	case PortfolioDrift:
+		return "Drift"
+	}
+	return fmt.Sprintf("PortfolioEventKind(%d)", k)
+}
+
+// driftTolerance is the absolute difference of the values considered equal during the reconciliation.
+const driftTolerance = 1e-6
+
+// PositionPnL is the state of the position marked to market.
+type PositionPnL struct {
+	Symbol        string
+	SecurityId    uint32
+	Quantity      float64 // negative for the short position
+	AveragePrice  float64
+	Multiplier    float64 // the contract size
+	MarkPrice     float64 // the last known price, 0 if no quote was received
+	MarketValue   float64 // signed: negative for the short position
+	Exposure      float64 // the absolute market value
+	RealizedPnL   float64
+	UnrealizedPnL float64
+	UpdatedAt     time.Time
+}
+
+// PortfolioSummary is the account level state of the portfolio.
+type PortfolioSummary struct {
+	Cash          float64
+	MarketValue   float64 // the net market value of the positions
+	Equity        float64 // Cash + MarketValue
+	LongExposure  float64
+	ShortExposure float64
+	GrossExposure float64
+	NetExposure   float64
+	RealizedPnL   float64
+	UnrealizedPnL float64
+}
+
+// Drift is the difference between the local state and the state reported by REST.
+type Drift struct {
+	Symbol string // empty for the account level values
+	Field  string
+	Local  float64
+	Remote float64
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s.%s: local %g, remote %g", d.Symbol, d.Field, d.Local, d.Remote)
+}
+
+// PortfolioEvent notifies about the portfolio change.
+type PortfolioEvent struct {
+	Kind     PortfolioEventKind
+	Symbol   string
+	Position PositionPnL // the state of the position, if the event relates to the symbol
+	Summary  PortfolioSummary
+	Drifts   []Drift // for PortfolioDrift only
+	Time     time.Time
+}
+
+// PortfolioFeeds are the sources of the portfolio updates, the nil channels are ignored.
+type PortfolioFeeds struct {
+	Fills      <-chan FillEvent          // e.g. OrderManager.Fills
+	Positions  <-chan sch.Position       // EtnaWS.PositionsChan
+	Balances   <-chan sch.TradingBalance // EtnaWS.BalanceChan
+	EtnaQuotes <-chan sch.EtnaQuote      // EtnaWS.QuotesChan, the quotes are matched by the security id
+	FmpQuotes  <-chan sch.FmpQuote       // FmpWS.QuotesChan, the quotes are matched by the symbol
+}
+
+// NewPortfolio creates the position and P&L tracker of the account. Call Seed to load the positions
+// and the balance, then Run (or the Apply methods) to process the updates.
+func NewPortfolio(rest *EtnaREST, accId uint32, logger Logger) *Portfolio {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	return &Portfolio{
+		rest:      rest,
+		accId:     accId,
+		log:       logger,
+		positions: make(map[string]*portfolioPosition),
+		bySecId:   make(map[string]string),
+		byPosId:   make(map[uint32]string),
+		fillState: make(map[uint64]int64),
+		events:    make(chan PortfolioEvent, 1000),
+	}
+}
+
+// Portfolio tracks the positions of the account and computes their realized and unrealized P&L.
+// The position updates from WebSocket and REST are authoritative, the fills are applied on top of them
+// until the next position update. It's safe for the concurrent use.
+type Portfolio struct {
+	rest      *EtnaREST
+	accId     uint32
+	log       Logger
+	mu        sync.RWMutex
+	positions map[string]*portfolioPosition
+	bySecId   map[string]string // security id -> symbol, to match the ETNA quotes
+	byPosId   map[uint32]string // position id -> symbol
+	fillState map[uint64]int64  // order id -> StateId of the last applied fill
+	cash      float64
+	events    chan PortfolioEvent
+}
+
+type portfolioPosition struct {
+	PositionPnL
+}
+
+// Seed loads the positions and the balance of the account, replacing the local state.
+func (p *Portfolio) Seed(ctx context.Context) error {
+	positions, balance, err := (*p).load(ctx)
+	if err != nil {
+		return fmt.Errorf("portfolio seed: %w", err)
+	}
+	(*p).mu.Lock()
+	(*p).positions = make(map[string]*portfolioPosition, len(positions))
+	clear((*p).bySecId)
+	clear((*p).byPosId)
+	clear((*p).fillState)
+	for _, pos := range positions {
+		(*p).setPosition(pos)
+	}
+	(*p).cash = balance.Cash
+	(*p).mu.Unlock()
+	return nil
+}
+
+// Run processes the feeds until the context is cancelled. If `reconcilePeriod` isn't zero,
+// the state is reconciled against REST periodically, see Reconcile.
+func (p *Portfolio) Run(ctx context.Context, feeds PortfolioFeeds, reconcilePeriod time.Duration) {
+	var reconcile <-chan time.Time
+	if reconcilePeriod > 0 {
+		ticker := time.NewTicker(reconcilePeriod)
+		defer ticker.Stop()
+		reconcile = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case fill, ok := <-feeds.Fills:
+			if !ok {
+				feeds.Fills = nil
+				continue
+			}
+			err = (*p).ApplyFill(fill)
+		case pos, ok := <-feeds.Positions:
+			if !ok {
+				feeds.Positions = nil
+				continue
+			}
+			err = (*p).ApplyPosition(pos)
+		case balance, ok := <-feeds.Balances:
+			if !ok {
+				feeds.Balances = nil
+				continue
+			}
+			(*p).ApplyBalance(balance)
+		case quote, ok := <-feeds.EtnaQuotes:
+			if !ok {
+				feeds.EtnaQuotes = nil
+				continue
+			}
+			(*p).ApplyEtnaQuote(quote)
+		case quote, ok := <-feeds.FmpQuotes:
+			if !ok {
+				feeds.FmpQuotes = nil
+				continue
+			}
+			(*p).ApplyFmpQuote(quote)
+		case <-reconcile:
+			_, err = (*p).Reconcile(ctx)
+		}
+		if err != nil {
+			(*p).log.Error("portfolio: %+v", err)
+		}
+	}
+}
+
+// ApplyFill applies the order execution to the position until the next authoritative position update.
+// The fills of the order are ordered by the server's StateId, the redelivered and the late ones
+// (with StateId not above the last applied one) are ignored. The fill, which arrives after the position update
+// already including it, is counted twice until the next position update or Reconcile, since the positions
+// have no common sequence with the orders.
+func (p *Portfolio) ApplyFill(fill FillEvent) error {
+	if fill.Symbol == "" || fill.Quantity <= 0 {
+		return fmt.Errorf("wrong fill: %+v", fill)
+	}
+	qty := fill.Quantity
+	switch fill.Side {
+	case sch.SideSell, sch.SideSellShort:
+		qty = -qty
+	case sch.SideBuy, sch.SideBuyToCover:
+	default:
+		return fmt.Errorf("wrong fill side: %+v", fill)
+	}
+
+	(*p).mu.Lock()
+	defer (*p).mu.Unlock()
+	if fill.StateId != 0 {
+		if last, exist := (*p).fillState[fill.OrderId]; exist && fill.StateId <= last {
+			return nil
+		}
+		(*p).fillState[fill.OrderId] = fill.StateId
+	}
+	pos, exist := (*p).positions[fill.Symbol]
+	if !exist {
+		pos = &portfolioPosition{PositionPnL: PositionPnL{Symbol: fill.Symbol, Multiplier: 1}}
+		(*p).positions[fill.Symbol] = pos
+	}
+
+	pos.RealizedPnL += applyExecution(&pos.PositionPnL, qty, fill.Price)
+	if pos.MarkPrice == 0 {
+		pos.MarkPrice = fill.Price
+	}
+	pos.mark()
+	pos.UpdatedAt = fill.Time
+	(*p).emit(PortfolioPositionChanged, pos.PositionPnL)
+	return nil
+}
+
+// ApplyPosition replaces the position with the state received from ETNA, the fields absent
+// in the partial update keep their values.
+func (p *Portfolio) ApplyPosition(update sch.Position) error {
+	(*p).mu.Lock()
+	defer (*p).mu.Unlock()
+	if update.Symbol == "" {
+		if update.Symbol = (*p).byPosId[update.Id]; update.Symbol == "" {
+			return fmt.Errorf("position update of the unknown position: %+v", update)
+		}
+	}
+	pos := (*p).setPosition(update)
+	(*p).emit(PortfolioPositionChanged, pos.PositionPnL)
+	return nil
+}
+
+// ApplyBalance updates the cash balance.
+func (p *Portfolio) ApplyBalance(balance sch.TradingBalance) {
+	(*p).mu.Lock()
+	changed := (*p).cash != balance.Cash
+	(*p).cash = balance.Cash
+	if changed {
+		(*p).emit(PortfolioBalanceChanged, PositionPnL{})
+	}
+	(*p).mu.Unlock()
+}
+
+// ApplyEtnaQuote marks the position of the quoted security to market.
+func (p *Portfolio) ApplyEtnaQuote(quote sch.EtnaQuote) {
+	(*p).mu.RLock()
+	symbol := (*p).bySecId[quote.SymbolId]
+	(*p).mu.RUnlock()
+	if symbol == "" {
+		symbol = quote.SymbolId
+	}
+	(*p).ApplyPrice(symbol, markPrice(quote.Last, quote.Bid, quote.Ask), time.Time(quote.Time))
+}
+
+// ApplyFmpQuote marks the position of the quoted symbol to market.
+func (p *Portfolio) ApplyFmpQuote(quote sch.FmpQuote) {
+	var ts time.Time
+	if quote.NTs != 0 {
+		ts = time.Unix(0, quote.NTs)
+	}
+	(*p).ApplyPrice(strings.ToUpper(quote.Symbol), markPrice(quote.Last, quote.Bid, quote.Ask), ts)
+}
+
+// ApplyPrice marks the position to market. The zero price and unknown symbols are ignored.
+func (p *Portfolio) ApplyPrice(symbol string, price float64, ts time.Time) {
+	if price <= 0 {
+		return
+	}
+	(*p).mu.Lock()
+	defer (*p).mu.Unlock()
+	pos, exist := (*p).positions[symbol]
+	if !exist || pos.MarkPrice == price {
+		return
+	}
+	pos.MarkPrice = price
+	pos.mark()
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	pos.UpdatedAt = ts
+	(*p).emit(PortfolioPriceChanged, pos.PositionPnL)
+}
+
+// Position returns the state of the symbol's position.
+func (p *Portfolio) Position(symbol string) (PositionPnL, bool) {
+	(*p).mu.RLock()
+	defer (*p).mu.RUnlock()
+	if pos, exist := (*p).positions[symbol]; exist {
+		return pos.PositionPnL, true
+	}
+	return PositionPnL{}, false
+}
+
+// Positions returns the states of the positions sorted by symbol, including the closed ones
+// with the realized P&L.
+func (p *Portfolio) Positions() []PositionPnL {
+	(*p).mu.RLock()
+	res := make([]PositionPnL, 0, len((*p).positions))
+	for _, pos := range (*p).positions {
+		res = append(res, pos.PositionPnL)
+	}
+	(*p).mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Symbol < res[j].Symbol })
+	return res
+}
+
+// Summary returns the account level state.
+func (p *Portfolio) Summary() PortfolioSummary {
+	(*p).mu.RLock()
+	defer (*p).mu.RUnlock()
+	return (*p).summary()
+}
+
+// Events returns the channel of the portfolio changes. The events are dropped if the channel is full.
+func (p *Portfolio) Events() <-chan PortfolioEvent {
+	return (*p).events
+}
+
+// Reconcile compares the local state with the positions and the balance requested via REST,
+// then replaces the local state with the remote one. The found differences are returned
+// and emitted as the PortfolioDrift event.
+func (p *Portfolio) Reconcile(ctx context.Context) ([]Drift, error) {
+	positions, balance, err := (*p).load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("portfolio reconcile: %w", err)
+	}
+
+	var drifts []Drift
+	(*p).mu.Lock()
+	defer (*p).mu.Unlock()
+	remote := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		remote[pos.Symbol] = true
+		local, exist := (*p).positions[pos.Symbol]
+		if !exist {
+			local = &portfolioPosition{}
+		}
+		drifts = appendDrift(drifts, pos.Symbol, "Quantity", local.Quantity, float64(pos.Quantity))
+		if pos.Quantity != 0 {
+			drifts = appendDrift(drifts, pos.Symbol, "AveragePrice", local.AveragePrice, pos.AverageOpenPrice)
+		}
+		(*p).setPosition(pos)
+	}
+	for symbol, local := range (*p).positions {
+		if !remote[symbol] && local.Quantity != 0 {
+			drifts = appendDrift(drifts, symbol, "Quantity", local.Quantity, 0)
+			local.Quantity, local.AveragePrice = 0, 0
+			local.mark()
+		}
+	}
+	drifts = appendDrift(drifts, "", "Cash", (*p).cash, balance.Cash)
+	(*p).cash = balance.Cash
+
+	if len(drifts) > 0 {
+		(*p).log.Info("portfolio drift: %v", drifts)
+		(*p).emitEvent(PortfolioEvent{Kind: PortfolioDrift, Summary: (*p).summary(), Drifts: drifts, Time: time.Now()})
+	}
+	return drifts, nil
+}
+
+func (p *Portfolio) load(ctx context.Context) ([]sch.Position, sch.TradingBalance, error) {
+	positions, err := (*p).rest.GetPositions(ctx, (*p).accId)
+	if err != nil {
+		return nil, sch.TradingBalance{}, err
+	}
+	balance, err := (*p).rest.GetBalance(ctx, (*p).accId)
+	return positions, balance, err
+}
+
+// setPosition replaces the local position with the fields set in the update, the partial WebSocket update
+// keeps the rest. The caller must hold the write lock.
+func (p *Portfolio) setPosition(update sch.Position) *portfolioPosition {
+	pos, exist := (*p).positions[update.Symbol]
+	if !exist {
+		pos = &portfolioPosition{PositionPnL: PositionPnL{Symbol: update.Symbol}}
+		(*p).positions[update.Symbol] = pos
+	}
+	if update.SecurityId != 0 {
+		pos.SecurityId = update.SecurityId
+		(*p).bySecId[strconv.FormatUint(uint64(update.SecurityId), 10)] = update.Symbol
+	}
+	if update.Id != 0 {
+		(*p).byPosId[update.Id] = update.Symbol
+	}
+	if update.IsSet("Quantity") {
+		pos.Quantity = float64(update.Quantity)
+	}
+	if update.IsSet("AverageOpenPrice") {
+		pos.AveragePrice = update.AverageOpenPrice
+	}
+	if update.IsSet("RealizedProfitLoss") {
+		pos.RealizedPnL = update.RealizedProfitLoss
+	}
+	if update.IsSet("ContractSize") || !exist {
+		pos.Multiplier = update.MinContractSize
+	}
+	if pos.Multiplier <= 0 {
+		pos.Multiplier = 1
+	}
+	if pos.UpdatedAt = update.ModifyDate; pos.UpdatedAt.IsZero() {
+		pos.UpdatedAt = time.Now()
+	}
+	pos.mark()
+	return pos
+}
+
+// summary computes the account level state, the caller must hold the lock.
+func (p *Portfolio) summary() PortfolioSummary {
+	sum := PortfolioSummary{Cash: (*p).cash}
+	for _, pos := range (*p).positions {
+		sum.MarketValue += pos.MarketValue
+		sum.RealizedPnL += pos.RealizedPnL
+		sum.UnrealizedPnL += pos.UnrealizedPnL
+		if pos.MarketValue > 0 {
+			sum.LongExposure += pos.Exposure
+		} else {
+			sum.ShortExposure += pos.Exposure
+		}
+	}
+	sum.Equity = sum.Cash + sum.MarketValue
+	sum.GrossExposure = sum.LongExposure + sum.ShortExposure
+	sum.NetExposure = sum.LongExposure - sum.ShortExposure
+	return sum
+}
+
+// emit sends the event of the position change, the caller must hold the lock.
+func (p *Portfolio) emit(kind PortfolioEventKind, pos PositionPnL) {
+	(*p).emitEvent(PortfolioEvent{Kind: kind, Symbol: pos.Symbol, Position: pos, Summary: (*p).summary(),
+		Time: time.Now()})
+}
+
+func (p *Portfolio) emitEvent(evt PortfolioEvent) {
+	select {
+	case (*p).events <- evt:
+	default:
+		(*p).log.Error("portfolio: event is dropped %s %s", evt.Kind, evt.Symbol)
+	}
+}
+
+// mark recomputes the market value and the unrealized P&L using the mark price, or the average price
+// if there is no quote yet.
+func (pos *PositionPnL) mark() {
+	price := pos.MarkPrice
+	if price == 0 {
+		price = pos.AveragePrice
+	}
+	pos.MarketValue = pos.Quantity * price * pos.Multiplier
+	pos.Exposure = math.Abs(pos.MarketValue)
+	pos.UnrealizedPnL = pos.Quantity * (price - pos.AveragePrice) * pos.Multiplier
+}
+
+// applyExecution changes the position by the signed quantity at the price using the average cost method.
+// It returns the realized P&L of the closed part of the position.
+func applyExecution(pos *PositionPnL, qty, price float64) float64 {
+	var realized float64
+
+	if pos.Quantity == 0 || (pos.Quantity > 0) == (qty > 0) {
+		total := pos.Quantity + qty
+		pos.AveragePrice = (math.Abs(pos.Quantity)*pos.AveragePrice + math.Abs(qty)*price) / math.Abs(total)
+		pos.Quantity = total
+		return 0
+	}
+
+	closed := math.Min(math.Abs(qty), math.Abs(pos.Quantity))
+	if pos.Quantity > 0 {
+		realized = closed * (price - pos.AveragePrice) * pos.Multiplier
+	} else {
+		realized = closed * (pos.AveragePrice - price) * pos.Multiplier
+	}
+	wasLong := pos.Quantity > 0
+	pos.Quantity += qty
+	switch {
+	case math.Abs(pos.Quantity) < driftTolerance:
+		pos.Quantity, pos.AveragePrice = 0, 0
+	case (pos.Quantity > 0) != wasLong:
+		pos.AveragePrice = price // the position is reversed, the rest is opened at the execution price
+	}
+	return realized
+}
+
+// markPrice returns the last price or the mid-price if the last is absent.
+func markPrice(last, bid, ask float64) float64 {
+	if last > 0 {
+		return last
+	} else if bid > 0 && ask > 0 {
+		return (bid + ask) / 2
+	}
+	return 0
+}
+
+func appendDrift(drifts []Drift, symbol, field string, local, remote float64) []Drift {
+	if math.Abs(local-remote) > driftTolerance {
+		drifts = append(drifts, Drift{Symbol: symbol, Field: field, Local: local, Remote: remote})
+	}
+	return drifts
+}
@@ -0,0 +1,182 @@
+package goetna
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestPortfolioApplyFill(t *testing.T) {
+	type fill struct {
+		side       sch.OrderSide
+		qty, price float64
+	}
+	tests := map[string]struct {
+		fills                       []fill
+		qty, avg, realized, unrealz float64 // the mark price is the price of the first fill
+	}{
+		"open_long": {
+			fills: []fill{{sch.SideBuy, 10, 100}, {sch.SideBuy, 10, 110}},
+			qty:   20, avg: 105, unrealz: -100},
+		"reduce_long": {
+			fills: []fill{{sch.SideBuy, 10, 100}, {sch.SideSell, 4, 110}},
+			qty:   6, avg: 100, realized: 40},
+		"close_long": {
+			fills: []fill{{sch.SideBuy, 10, 100}, {sch.SideSell, 10, 90}},
+			qty:   0, avg: 0, realized: -100},
+		"reverse_long": {
+			fills: []fill{{sch.SideBuy, 10, 100}, {sch.SideSell, 15, 120}},
+			qty:   -5, avg: 120, realized: 200, unrealz: 100},
+		"cover_short": {
+			fills: []fill{{sch.SideSellShort, 10, 50}, {sch.SideBuyToCover, 5, 40}},
+			qty:   -5, avg: 50, realized: 50},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			p := NewPortfolio(nil, 1, nil)
+			for _, f := range tc.fills {
+				if err := p.ApplyFill(FillEvent{Symbol: "AAPL", Side: f.side, Quantity: f.qty, Price: f.price}); err != nil {
+					(*t).Fatal(err)
+				}
+			}
+			pos, _ := p.Position("AAPL")
+			if !approxEqual(pos.Quantity, tc.qty) || !approxEqual(pos.AveragePrice, tc.avg) ||
+				!approxEqual(pos.RealizedPnL, tc.realized) || !approxEqual(pos.UnrealizedPnL, tc.unrealz) {
+				(*t).Errorf("wrong position: %+v", pos)
+			}
+		})
+	}
+}
+
+func TestPortfolioFillOrder(t *testing.T) {
+	tests := map[string]struct {
+		states []int64 // the StateId of the buy fills of the order
+		qty    float64
+	}{
+		"ordered":     {states: []int64{1, 2, 3}, qty: 30},
+		"late":        {states: []int64{1, 3, 2}, qty: 20},
+		"redelivered": {states: []int64{1, 2, 2}, qty: 20},
+		"no_state":    {states: []int64{0, 0}, qty: 20},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			p := NewPortfolio(nil, 1, nil)
+			for _, state := range tc.states {
+				fill := FillEvent{OrderId: 1, Symbol: "AAPL", Side: sch.SideBuy, Quantity: 10, Price: 100, StateId: state}
+				if err := p.ApplyFill(fill); err != nil {
+					(*t).Fatal(err)
+				}
+			}
+			if pos, _ := p.Position("AAPL"); pos.Quantity != tc.qty {
+				(*t).Errorf("wrong quantity: %g", pos.Quantity)
+			}
+		})
+	}
+}
+
+func TestPortfolioSeed(t *testing.T) {
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"GET /v1.0/accounts/1/positions": respond(`{"Result":[{"Id":8,"SecurityId":43,"Symbol":"AAPL","Quantity":10,` +
+			`"AverageOpenPrice":100}],"TotalCount":1}`),
+		"GET /v1.0/accounts/1/info": respond(`{"cash":500}`),
+	})
+	p := NewPortfolio(rest, 1, nil)
+	if err := p.ApplyPosition(sch.Position{Id: 7, SecurityId: 42, Symbol: "TSLA", Quantity: 1}); err != nil {
+		(*t).Fatal(err)
+	} else if err = p.ApplyFill(FillEvent{OrderId: 1, Symbol: "AAPL", Side: sch.SideBuy, Quantity: 1, StateId: 5}); err != nil {
+		(*t).Fatal(err)
+	}
+	if err := p.Seed(context.Background()); err != nil {
+		(*t).Fatal(err)
+	}
+
+	// the indexes of the replaced positions are cleared
+	var update sch.Position
+	if err := update.Parse(map[string]string{"Id": "7", "Quantity": "3"}); err != nil {
+		(*t).Fatal(err)
+	}
+	if err := p.ApplyPosition(update); err == nil {
+		(*t).Error("the update of the replaced position is applied")
+	}
+	p.ApplyEtnaQuote(sch.EtnaQuote{SymbolId: "42", Last: 200})
+	if _, exist := p.Position("TSLA"); exist {
+		(*t).Error("the replaced position is kept")
+	}
+	if err := p.ApplyFill(FillEvent{OrderId: 1, Symbol: "AAPL", Side: sch.SideBuy, Quantity: 1, Price: 100, StateId: 5}); err != nil {
+		(*t).Fatal(err)
+	} else if pos, _ := p.Position("AAPL"); pos.Quantity != 11 || p.Summary().Cash != 500 {
+		(*t).Errorf("wrong state: %+v %+v", pos, p.Summary())
+	}
+}
+
+func TestPortfolioApplyPosition(t *testing.T) {
+	full := sch.Position{Id: 7, SecurityId: 42, Symbol: "ES", Quantity: 2, AverageOpenPrice: 5000,
+		RealizedProfitLoss: 150, MinContractSize: 50}
+	tests := map[string]struct {
+		values                         map[string]string
+		qty, avg, realized, multiplier float64
+	}{
+		"quantity_only": {
+			values: map[string]string{"Id": "7", "Quantity": "3"},
+			qty:    3, avg: 5000, realized: 150, multiplier: 50},
+		"realized_only": {
+			values: map[string]string{"Id": "7", "RealizedProfitLoss": "275.5"},
+			qty:    2, avg: 5000, realized: 275.5, multiplier: 50},
+		"price_and_size": {
+			values: map[string]string{"Id": "7", "AverageOpenPrice": "5010", "ContractSize": "5"},
+			qty:    2, avg: 5010, realized: 150, multiplier: 5},
+		"closed": {
+			values: map[string]string{"Id": "7", "Quantity": "0", "AverageOpenPrice": "0"},
+			qty:    0, avg: 0, realized: 150, multiplier: 50},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			p := NewPortfolio(nil, 1, nil)
+			if err := p.ApplyPosition(full); err != nil {
+				(*t).Fatal(err)
+			}
+			var update sch.Position
+			if err := update.Parse(tc.values); err != nil {
+				(*t).Fatal(err)
+			}
+			if err := p.ApplyPosition(update); err != nil {
+				(*t).Fatal(err)
+			}
+			pos, _ := p.Position("ES")
+			if pos.Quantity != tc.qty || pos.AveragePrice != tc.avg || pos.RealizedPnL != tc.realized ||
+				pos.Multiplier != tc.multiplier || pos.SecurityId != 42 {
+				(*t).Errorf("wrong position: %+v", pos)
+			}
+		})
+	}
+}
+
+func TestPortfolioSummary(t *testing.T) {
+	p := NewPortfolio(nil, 1, nil)
+	p.ApplyBalance(sch.TradingBalance{Cash: 1000})
+	for _, pos := range []sch.Position{
+		{Symbol: "AAPL", Quantity: 10, AverageOpenPrice: 100},
+		{Symbol: "TSLA", Quantity: -5, AverageOpenPrice: 200},
+	} {
+		if err := p.ApplyPosition(pos); err != nil {
+			(*t).Fatal(err)
+		}
+	}
+	p.ApplyPrice("AAPL", 110, time.Time{})
+	p.ApplyPrice("TSLA", 190, time.Time{})
+
+	sum := p.Summary()
+	expect := PortfolioSummary{Cash: 1000, MarketValue: 150, Equity: 1150, LongExposure: 1100, ShortExposure: 950,
+		GrossExposure: 2050, NetExposure: 150, UnrealizedPnL: 150}
+	if sum != expect {
+		(*t).Errorf("wrong summary: %+v", sum)
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < driftTolerance
+}
@@ -0,0 +1,126 @@
+package goetna
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EndpointClass groups the REST endpoints, which share the same request budget.
+type EndpointClass uint8
+
+const (
+	ClassAccount    EndpointClass = iota // users, accounts, balances, positions, transfers
+	ClassOrders                          // placing, replacing, cancelling and querying orders
+	ClassMarketData                      // securities, bars, streamers
+)
+
+func (c EndpointClass) String() string {
+	switch c {
+	case ClassOrders:
+		return "orders"
+	case ClassMarketData:
+		return "market-data"
+	}
+	return "account"
+}
+
+// endpointClass returns the budget class of the API endpoint.
+func endpointClass(endpoint string) EndpointClass {
+	switch {
+	case isOrderEndpoint(endpoint):
+		return ClassOrders
+	case strings.Contains(endpoint, "market-data"), strings.HasPrefix(endpoint, "v1.0/equities"),
+		strings.HasPrefix(endpoint, "v1.0/streamers"):
+		return ClassMarketData
+	}
+	return ClassAccount
+}
+
+// RateLimit is the token bucket parameters: `Rate` requests per second with bursts up to `Burst` requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// NewRateLimiter creates the client-side rate limiter with separate budgets per endpoint class.
+// The classes absent in `limits` aren't limited. The limiter is safe to share between several EtnaREST instances.
+func NewRateLimiter(limits map[EndpointClass]RateLimit) *RateLimiter {
+	rl := RateLimiter{buckets: make(map[EndpointClass]*tokenBucket, len(limits))}
+	for class, lim := range limits {
+		rl.buckets[class] = newTokenBucket(lim)
+	}
+	return &rl
+}
+
+type RateLimiter struct {
+	buckets map[EndpointClass]*tokenBucket
+}
+
+// Wait blocks until a request of the class is allowed or the context is cancelled.
+func (rl *RateLimiter) Wait(ctx context.Context, class EndpointClass) error {
+	if bucket, exist := (*rl).buckets[class]; exist {
+		return bucket.wait(ctx)
+	}
+	return nil
+}
+
+// WaitTime returns the total time the callers have spent waiting for the tokens of the class.
+func (rl *RateLimiter) WaitTime(class EndpointClass) time.Duration {
+	if bucket, exist := (*rl).buckets[class]; exist {
+		return time.Duration(bucket.waited.Load())
+	}
+	return 0
+}
+
+func newTokenBucket(lim RateLimit) *tokenBucket {
+	if lim.Burst < 1 {
+		lim.Burst = 1
+	}
+	return &tokenBucket{limit: lim, tokens: float64(lim.Burst), lastTs: time.Now()}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	limit  RateLimit
+	tokens float64
+	lastTs time.Time
+	waited atomic.Int64
+}
+
+// wait takes a token from the bucket, sleeping until it's refilled if necessary.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if (*b).limit.Rate <= 0 {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		if waited := time.Since(start); waited > time.Millisecond {
+			(*b).waited.Add(int64(waited))
+		}
+	}()
+
+	for {
+		(*b).mu.Lock()
+		now := time.Now()
+		(*b).tokens = min(float64((*b).limit.Burst), (*b).tokens+now.Sub((*b).lastTs).Seconds()*(*b).limit.Rate)
+		(*b).lastTs = now
+		if (*b).tokens >= 1 {
+			(*b).tokens--
+			(*b).mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - (*b).tokens) / (*b).limit.Rate * float64(time.Second))
+		(*b).mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
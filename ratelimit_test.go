@@ -0,0 +1,75 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEndpointClass(t *testing.T) {
+	tests := map[string]struct {
+		endpoint string
+		expect   EndpointClass
+	}{
+		"orders":     {endpoint: "v1.0/accounts/1/orders", expect: ClassOrders},
+		"order":      {endpoint: "v1.0/accounts/1/orders/2", expect: ClassOrders},
+		"positions":  {endpoint: "v1.0/accounts/1/positions", expect: ClassAccount},
+		"securities": {endpoint: "v1.0/equities/AAPL", expect: ClassMarketData},
+		"streamers":  {endpoint: "v1.0/streamers", expect: ClassMarketData},
+		"bars":       {endpoint: "v1.0/history/market-data/bars", expect: ClassMarketData},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if class := endpointClass(tc.endpoint); class != tc.expect {
+				(*t).Errorf("wrong class: %s", class)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	tests := map[string]struct {
+		limit      RateLimit
+		requests   int
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		"burst":     {limit: RateLimit{Rate: 1, Burst: 5}, requests: 5, maxElapsed: 50 * time.Millisecond},
+		"refill":    {limit: RateLimit{Rate: 20, Burst: 2}, requests: 4, minElapsed: 90 * time.Millisecond, maxElapsed: 300 * time.Millisecond},
+		"no_burst":  {limit: RateLimit{Rate: 50}, requests: 3, minElapsed: 35 * time.Millisecond, maxElapsed: 250 * time.Millisecond},
+		"unlimited": {limit: RateLimit{}, requests: 100, maxElapsed: 50 * time.Millisecond},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			bucket := newTokenBucket(tc.limit)
+			start := time.Now()
+			for i := 0; i < tc.requests; i++ {
+				if err := bucket.wait(context.Background()); err != nil {
+					(*t).Fatal(err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tc.minElapsed || elapsed > tc.maxElapsed {
+				(*t).Errorf("wrong elapsed time: %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	rl := NewRateLimiter(map[EndpointClass]RateLimit{ClassOrders: {Rate: .1, Burst: 1}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := rl.Wait(ctx, ClassOrders); err != nil {
+		(*t).Fatal(err)
+	}
+	if err := rl.Wait(ctx, ClassOrders); !errors.Is(err, context.DeadlineExceeded) {
+		(*t).Errorf("wrong error: %v", err)
+	} else if waited := rl.WaitTime(ClassOrders); waited < 40*time.Millisecond {
+		(*t).Errorf("wrong wait time: %s", waited)
+	}
+	if err := rl.Wait(ctx, ClassAccount); err != nil {
+		(*t).Errorf("unlimited class is limited: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package goetna
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConnState is the state of the WebSocket client connection.
+type ConnState uint32
+
+const (
+	ConnIdle         ConnState = iota // the client isn't started
+	ConnConnecting                    // the first connection is being established
+	ConnConnected                     // the socket is connected, the login isn't confirmed yet
+	ConnLoggedIn                      // the session is created, the client is operational
+	ConnReconnecting                  // the connection is lost and is being restored
+	ConnStopped                       // the client is stopped
+	ConnFailed                        // the reconnection attempts are exhausted
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnIdle:
+		return "Idle"
+	case ConnConnecting:
+		return "Connecting"
+	case ConnConnected:
+		return "Connected"
+	case ConnLoggedIn:
+		return "LoggedIn"
+	case ConnReconnecting:
+		return "Reconnecting"
+	case ConnStopped:
+		return "Stopped"
+	case ConnFailed:
+		return "Failed"
+	default:
+		return fmt.Sprintf("ConnState(%d)", uint32(s))
+	}
+}
+
+// StateHandler is called on every change of the connection state.
+type StateHandler func(name string, prev, next ConnState)
+
+// ReconnectPolicy describes how the WebSocket client restores the lost connection.
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration // delay before the first attempt
+	MaxBackoff     time.Duration // upper limit of the delay
+	Multiplier     float64       // growth factor of the delay
+	Jitter         float64       // randomization factor of the delay, 0..1
+	MaxAttempts    int           // the attempts limit, 0 is unlimited
+	MaxElapsed     time.Duration // the limit of the total reconnection time, 0 is unlimited
+}
+
+// DefaultReconnectPolicy returns the policy with the unlimited attempts and exponential backoff from 1s to 2m.
+func DefaultReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     2 * time.Minute,
+		Multiplier:     2,
+		Jitter:         .2,
+	}
+}
+
+// Backoff returns the delay before the given attempt, starting from 1.
+func (p *ReconnectPolicy) Backoff(attempt int) time.Duration {
+	return expBackoff((*p).InitialBackoff, (*p).MaxBackoff, (*p).Multiplier, (*p).Jitter, attempt)
+}
+
+// exhausted reports whether the next attempt after the `delay` exceeds the limits.
+func (p *ReconnectPolicy) exhausted(attempt int, elapsed, delay time.Duration) bool {
+	return (*p).MaxAttempts > 0 && attempt > (*p).MaxAttempts ||
+		(*p).MaxElapsed > 0 && elapsed+delay > (*p).MaxElapsed
+}
+
+// State returns the current connection state.
+func (ws *WSClient) State() ConnState {
+	return ConnState((*ws).state.Load())
+}
+
+// OnStateChange sets the handler of the connection state changes. It must be called before Start.
+func (ws *WSClient) OnStateChange(h StateHandler) {
+	(*ws).hdlState = h
+}
+
+// SetReconnectPolicy replaces the reconnection policy, DefaultReconnectPolicy is used by default.
+// It must be called before Start.
+func (ws *WSClient) SetReconnectPolicy(p *ReconnectPolicy) {
+	if p == nil {
+		p = DefaultReconnectPolicy()
+	}
+	(*ws).reconnPolicy = p
+}
+
+func (ws *WSClient) setState(state ConnState) {
+	prev := ConnState((*ws).state.Swap(uint32(state)))
+	if prev == state {
+		return
+	}
+	(*ws).logger.Debug("connection state: %s %s -> %s", (*ws).name, prev, state)
+	if (*ws).hdlState != nil {
+		(*ws).hdlState((*ws).name, prev, state)
+	}
+}
+
+// setLoggedIn stores the login status, the loss of the login returns the state to Connected.
+func (ws *WSClient) setLoggedIn(loggedIn bool) {
+	(*ws).loggedIn.Store(loggedIn)
+	if loggedIn {
+		(*ws).setState(ConnLoggedIn)
+	} else if (*ws).connected.Load() && (*ws).State() == ConnLoggedIn {
+		(*ws).setState(ConnConnected)
+	}
+}
+
+// closeConn closes the current connection, so the new one can be established.
+func (ws *WSClient) closeConn() {
+	(*ws).mu.Lock()
+	defer (*ws).mu.Unlock()
+	if (*ws).conn != nil {
+		if err := (*(*ws).conn).Close(); err != nil {
+			(*ws).logger.Debug("closing connection fault: %s %v", (*ws).name, err)
+		}
+		(*ws).conn = nil
+	}
+	(*ws).connected.Store(false)
+	(*ws).loggedIn.Store(false)
+}
+
+// reconnect restores the connection according to the reconnect policy. The waiting is interrupted by Stop,
+// the concurrent calls are ignored while the reconnection is in progress.
+func (ws *WSClient) reconnect() {
+	if !(*ws).connecting.CompareAndSwap(false, true) {
+		return
+	}
+	defer (*ws).connecting.Store(false)
+
+	var err error
+	policy := (*ws).reconnPolicy
+	(*ws).setState(ConnReconnecting)
+	(*ws).closeConn()
+
+	started := time.Now()
+	timer := time.NewTimer(0)
+	<-timer.C
+	for attempt := 1; ; attempt++ {
+		delay := policy.Backoff(attempt)
+		if policy.exhausted(attempt, time.Since(started), delay) {
+			break
+		}
+		timer.Reset(delay)
+		select {
+		case <-(*ws).ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if (*ws).closing.Load() {
+			return
+		}
+
+		(*ws).instr.Reconnecting((*ws).name, attempt)
+		if err = (*ws).start(); err == nil {
+			return
+		} else if (*ws).ctx.Err() != nil {
+			return
+		}
+		(*ws).logger.Error("reconnection attempt #%d fault: %s %+v", attempt, (*ws).name, err)
+		(*ws).closeConn()
+		(*ws).setState(ConnReconnecting)
+	}
+	(*ws).logger.Error("giving up with reconnection: %s %+v", (*ws).name, err)
+	(*ws).setState(ConnFailed)
+}
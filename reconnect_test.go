@@ -0,0 +1,132 @@
+package goetna
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicy(t *testing.T) {
+	p := ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2,
+		MaxAttempts: 5, MaxElapsed: 10 * time.Second}
+	for attempt, expect := range []time.Duration{100, 100, 200, 400, 800, 1000, 1000} {
+		if delay := p.Backoff(attempt); delay != expect*time.Millisecond {
+			(*t).Errorf("wrong delay of attempt %d: %s", attempt, delay)
+		}
+	}
+	p.Jitter = .2
+	for i := 0; i < 10; i++ {
+		if delay := p.Backoff(3); delay < 320*time.Millisecond || delay > 480*time.Millisecond {
+			(*t).Fatalf("wrong jittered delay: %s", delay)
+		}
+	}
+
+	tests := map[string]struct {
+		attempt        int
+		elapsed, delay time.Duration
+		expect         bool
+	}{
+		"within_limits": {attempt: 5, elapsed: 9 * time.Second, delay: time.Second},
+		"attempts":      {attempt: 6, expect: true},
+		"elapsed":       {attempt: 2, elapsed: 9 * time.Second, delay: 1001 * time.Millisecond, expect: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := p.exhausted(tc.attempt, tc.elapsed, tc.delay); res != tc.expect {
+				(*t).Errorf("wrong result: %t", res)
+			}
+		})
+	}
+	if unlimited := DefaultReconnectPolicy(); unlimited.exhausted(1000, 24*time.Hour, time.Minute) {
+		(*t).Error("the default policy is exhausted")
+	}
+}
+
+// stateRecorder records the transitions of the connection state.
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []string
+}
+
+func (r *stateRecorder) handle(_ string, _, next ConnState) {
+	(*r).mu.Lock()
+	defer (*r).mu.Unlock()
+	(*r).states = append((*r).states, next.String())
+}
+
+func (r *stateRecorder) String() string {
+	(*r).mu.Lock()
+	defer (*r).mu.Unlock()
+	return strings.Join((*r).states, ",")
+}
+
+func TestWSClientReconnectExhausted(t *testing.T) {
+	var (
+		rec      stateRecorder
+		attempts int
+	)
+	ws := newOfflineEtnaWS(t, false)
+	ws.OnStateChange(rec.handle)
+	ws.SetReconnectPolicy(&ReconnectPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3})
+	ws.SetConnectFunc(func() error {
+		attempts++
+		return errors.New("refused")
+	})
+	ws.reconnect()
+	if attempts != 3 {
+		(*t).Errorf("wrong number of attempts: %d", attempts)
+	} else if states := rec.String(); states != "Reconnecting,Failed" {
+		(*t).Errorf("wrong states: %s", states)
+	} else if ws.State() != ConnFailed || (*ws).connecting.Load() {
+		(*t).Error("the reconnection isn't finished")
+	}
+}
+
+func TestWSClientReconnectStopped(t *testing.T) {
+	var rec stateRecorder
+	ws := newOfflineEtnaWS(t, false)
+	ws.OnStateChange(rec.handle)
+	ws.SetReconnectPolicy(&ReconnectPolicy{InitialBackoff: time.Hour})
+	ws.SetConnectFunc(func() error {
+		(*t).Error("the stopped client is connected")
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		ws.reconnect()
+		close(done)
+	}()
+	for ws.State() != ConnReconnecting {
+		time.Sleep(time.Millisecond)
+	}
+	// the concurrent reconnection is ignored
+	ws.reconnect()
+	ws.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		(*t).Fatal("the waiting isn't interrupted by Stop")
+	}
+	if states := rec.String(); states != "Reconnecting,Stopped" {
+		(*t).Errorf("wrong states: %s", states)
+	}
+}
+
+func TestWSClientLoggedIn(t *testing.T) {
+	var rec stateRecorder
+	ws := newOfflineEtnaWS(t, false)
+	ws.OnStateChange(rec.handle)
+	(*ws).connected.Store(true)
+	ws.setState(ConnConnected)
+	ws.setLoggedIn(true)
+	ws.setLoggedIn(false)
+	ws.closeConn()
+	ws.setLoggedIn(false)
+	if states := rec.String(); states != "Connected,LoggedIn,Connected" {
+		(*t).Errorf("wrong states: %s", states)
+	} else if ws.IsOperational() {
+		(*t).Error("the closed client is operational")
+	}
+}
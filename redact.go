@@ -0,0 +1,152 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secret values in the logs.
+const Redacted = "***"
+
+var (
+	// the secret fields in the URL queries, headers, JSON messages and printed structs
+	reSecretField = regexp.MustCompile(`(?i)\b(password|passwd|username|api_?key|et-app-key|token|session_?id)\b` +
+		`(\\?["']?\s*[:=]\s*\[?\s*\\?["']?)([^"'\\&\s,;}\]]+)`)
+	// the login or the user session of the streamer URL (CreateSession.txt?User=...), the bare "user" key
+	// isn't secret elsewhere, e.g. the user id
+	reQueryUser = regexp.MustCompile(`(?i)([?&]user=)([^&\s"']+)`)
+	reBearer    = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+// secretKeys are the normalized keys of the structured fields with the secret values.
+var secretKeys = map[string]struct{}{
+	"password": {}, "passwd": {}, "username": {}, "apikey": {}, "etappkey": {}, "token": {},
+	"sessionid": {}, "authorization": {},
+}
+
+// Redact replaces the passwords, user names, tokens, API keys and session ids in the text with Redacted.
+func Redact(s string) string {
+	s = reSecretField.ReplaceAllString(s, "${1}${2}"+Redacted)
+	s = reQueryUser.ReplaceAllString(s, "${1}"+Redacted)
+	return reBearer.ReplaceAllString(s, "Bearer "+Redacted)
+}
+
+// isSecretKey reports whether the field key names the secret, e.g. "Password", "api_key" or "Et-App-Key".
+func isSecretKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	_, exist := secretKeys[key]
+	return exist
+}
+
+// sessionAttr is the field, which identifies the session in the logs without revealing its id.
+func sessionAttr(id string) slog.Attr {
+	if id == "" {
+		return slog.String("session", "")
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return slog.String("session", fmt.Sprintf("%08x", h.Sum32()))
+}
+
+// redactAttr removes the secrets from the field.
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if isSecretKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i := range group {
+			attrs[i] = redactAttr(group[i])
+		}
+		a.Value = slog.GroupValue(attrs...)
+	case slog.KindAny:
+		a.Value = slog.StringValue(Redact(fmt.Sprintf("%+v", a.Value.Any())))
+	}
+	return a
+}
+
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	res := make([]slog.Attr, len(attrs))
+	for i := range attrs {
+		res[i] = redactAttr(attrs[i])
+	}
+	return res
+}
+
+// redactHandler is the slog.Handler, which removes the secrets before passing the records to the next handler.
+type redactHandler struct {
+	next slog.Handler
+}
+
+// NewRedactHandler wraps the handler, so the passwords, tokens, API keys and session ids are removed
+// from the messages and fields, see Redact. The fields with the secret keys (e.g. "Password") are replaced
+// entirely, the non-scalar values are logged as the redacted strings.
+func NewRedactHandler(next slog.Handler) slog.Handler {
+	if h, ok := next.(redactHandler); ok {
+		return h
+	}
+	return redactHandler{next: next}
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	res := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		res.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, res)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return redactHandler{next: h.next.WithAttrs(redactAttrs(attrs))}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactLogger removes the secrets from the messages of the printf-style logger.
+type redactLogger struct {
+	l Logger
+}
+
+// newRedactLogger wraps the logger of the client unless it already removes the secrets.
+func newRedactLogger(l Logger) Logger {
+	switch l.(type) {
+	case nil:
+		return NopLogger{}
+	case NopLogger, *SlogLogger, redactLogger:
+		return l
+	}
+	return redactLogger{l: l}
+}
+
+func (r redactLogger) Info(format string, v ...any) {
+	r.l.Info("%s", Redact(fmt.Sprintf(format, v...)))
+}
+func (r redactLogger) Debug(format string, v ...any) {
+	r.l.Debug("%s", Redact(fmt.Sprintf(format, v...)))
+}
+func (r redactLogger) Error(format string, v ...any) {
+	r.l.Error("%s", Redact(fmt.Sprintf(format, v...)))
+}
+func (r redactLogger) Fatal(format string, v ...any) {
+	r.l.Fatal("%s", Redact(fmt.Sprintf(format, v...)))
+}
+
+func (r redactLogger) LogAttrs(_ context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	logAttrs(r.l, level, Redact(msg), redactAttrs(attrs)...)
+}
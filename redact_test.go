@@ -0,0 +1,67 @@
+package goetna
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := map[string]struct {
+		text   string
+		expect string
+	}{
+		"header": {
+			text:   fmt.Sprintf("%+v", http.Header{"Et-App-Key": {"k1"}, "Username": {"bob"}, "Password": {"pw"}}),
+			expect: "map[Et-App-Key:[***] Password:[***] Username:[***]]"},
+		"bearer": {
+			text:   "Authorization: Bearer abc.DEF-1=",
+			expect: "Authorization: Bearer ***"},
+		"json": {
+			text:   `{"event":"login","data":{"apiKey":"abc123"}}`,
+			expect: `{"event":"login","data":{"apiKey":"***"}}`},
+		"escaped_json": {
+			text:   `send fault: "{\"event\":\"login\",\"data\":{\"apiKey\":\"abc123\"}}"`,
+			expect: `send fault: "{\"event\":\"login\",\"data\":{\"apiKey\":\"***\"}}"`},
+		"query": {
+			text:   "wss://host/CreateSession.txt?User=42:u1&Password=s1&HttpClientType=WebSocket",
+			expect: "wss://host/CreateSession.txt?User=***&Password=***&HttpClientType=WebSocket"},
+		"subscribe_frame": {
+			text:   `{"Cmd":"Subscribe.txt","SessionId":"s-1","Keys":"AAPL","HttpClientType":"WebSocket"}`,
+			expect: `{"Cmd":"Subscribe.txt","SessionId":"***","Keys":"AAPL","HttpClientType":"WebSocket"}`},
+		"printed_struct": {
+			text:   fmt.Sprintf("%+v", struct{ Token, Symbol string }{Token: "t1", Symbol: "AAPL"}),
+			expect: "{Token:*** Symbol:AAPL}"},
+		"ordinary_user": {
+			text:   `{"user":"alice","UserId":42} user: 7`,
+			expect: `{"user":"alice","UserId":42} user: 7`},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := Redact(tc.text); res != tc.expect {
+				(*t).Errorf("wrong result: %s", res)
+			}
+		})
+	}
+}
+
+func TestRedactAttr(t *testing.T) {
+	tests := map[string]struct {
+		attr   slog.Attr
+		expect string
+	}{
+		"secret_key":   {attr: slog.String("Et-App-Key", "k1"), expect: "Et-App-Key=***"},
+		"secret_value": {attr: slog.String("url", "/token?password=pw"), expect: "url=/token?password=***"},
+		"any":          {attr: slog.Any("header", http.Header{"Password": {"pw"}}), expect: "header=map[Password:[***]]"},
+		"group":        {attr: slog.Group("req", slog.Int("api_key", 1)), expect: "req=[api_key=***]"},
+		"user":         {attr: slog.Int("user", 42), expect: "user=42"},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := redactAttr(tc.attr).String(); res != tc.expect {
+				(*t).Errorf("wrong attribute: %s", res)
+			}
+		})
+	}
+}
@@ -3,76 +3,96 @@ package goetna
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	gjson "github.com/goccy/go-json"
 	gschema "github.com/gorilla/schema"
 	sch "github.com/long-js/goetna/schema"
 )
 
+// NewEtnaREST creates the authenticated EtnaREST client of the demo environment if the TEST_ENV environment
+// variable is true, and of the LIVE environment otherwise. The selected environment is logged.
+// The local CA certificate is used if the ETNA_USE_LOCALCERT environment variable is true.
+// It's a shortcut for New with the corresponding options, use New with WithEtnaConfig to select
+// the environment explicitly.
 func NewEtnaREST(apiKey, nonRTHToken string, login, passwd []byte, isPrivate bool, logger Logger) (*EtnaREST, error) {
-	rest := EtnaREST{httpClient: &http.Client{Timeout: 12000000000}, enc: gschema.NewEncoder()}
-	if useCert, err := strconv.ParseBool(os.Getenv("ETNA_USE_LOCALCERT")); err == nil && useCert {
-		if cfg, err := useLocalEtnaCert(); err != nil {
-			return nil, err
-		} else {
-			rest.httpClient.Transport = &http.Transport{TLSClientConfig: cfg}
-		}
-	}
-	if isPrivate {
-		rest.baseUrl = DefaultConfig.RestUrlPriv
-	} else {
-		rest.baseUrl = DefaultConfig.RestUrlPub
-	}
-	if logger != nil {
-		rest.log = logger
-	}
-
-	header := make(http.Header)
-	// header["User-Agent"] = []string{"qant-backend/2.0"}
-	header["Content-Type"] = []string{"application/json"}
-	header["Accept"] = []string{"application/json"}
-	header["Connection"] = []string{"keep-alive"}
-	header["Et-App-Key"] = []string{apiKey}
-	rest.header = header
-
-	header = header.Clone()
-	header.Del("Et-App-Key")
-	header["Authorization"] = []string{fmt.Sprintf("Bearer %s", nonRTHToken)}
-	rest.nonRTHHeader = header
-
-	c := context.Background()
-	if err := rest.authenticate(c, login, passwd); err != nil {
-		return nil, err
-	}
+	cfg := legacyConfig()
+	newRedactLogger(logger).Info("REST: ETNA %s environment is selected by TEST_ENV=%q", (*cfg).Profile,
+		os.Getenv("TEST_ENV"))
 
-	return &rest, nil
+	return New(WithEtnaConfig(cfg),
+		WithAPIKey(apiKey), WithNonRTHToken(nonRTHToken), WithCredentials(login, passwd), WithPrivateAPI(isPrivate),
+		WithLogger(logger))
 }
 
+const (
+	TokenLifetime     = 24 * time.Hour   // the default lifetime of the authorization token, see EtnaConfig
+	TokenRefreshAhead = 10 * time.Minute // the token is renewed this long before its expiration
+)
+
 type EtnaREST struct {
 	httpClient           *http.Client
+	cfg                  *EtnaConfig
 	header, nonRTHHeader http.Header
 	enc                  *gschema.Encoder
-	baseUrl              string
+	baseUrl, nonRTHUrl   string
 	log                  Logger
+	creds                credentials
+	muAuth               sync.RWMutex
+	token                string
+	tokenExpire          time.Time
+	retry                *RetryPolicy
+	limiter              *RateLimiter
+	validator            *OrderValidator
+	instr                Instrumentation
+	tracer               Tracer
+	orderTracer          *OrderTracer
 }
 
+// credentials keeps the base64 encoded login and password, which are needed for the re-authentication.
+// The values are never printed by the fmt package.
+type credentials struct {
+	login, passwd []byte
+}
+
+func (c credentials) String() string   { return "credentials{***}" }
+func (c credentials) GoString() string { return c.String() }
+
+// decode returns the plain login and password.
+func (c credentials) decode() (string, string, error) {
+	var (
+		err      error
+		decLogin = make([]byte, base64.StdEncoding.DecodedLen(len(c.login)))
+		decPwd   = make([]byte, base64.StdEncoding.DecodedLen(len(c.passwd)))
+	)
+	if _, err = base64.StdEncoding.Decode(decLogin, c.login); err != nil {
+		return "", "", fmt.Errorf("wrong username %+v", err)
+	} else if _, err = base64.StdEncoding.Decode(decPwd, c.passwd); err != nil {
+		return "", "", fmt.Errorf("wrong password %+v", err)
+	}
+	return string(bytes.Trim(decLogin, "\x00")), string(bytes.Trim(decPwd, "\x00")), nil
+}
+
+// callAPI performs the request and decodes the response into the result.
+// Idempotent requests failed with a transient error are repeated according to the retry policy.
 func (api *EtnaREST) callAPI(ctx context.Context, method, endpoint string, query url.Values,
-	data, result interface{}, isBars bool) error {
+	data, result interface{}, isBars bool) (err error) {
 	var (
-		err       error
 		bData     []byte
-		req       *http.Request
-		resp      *http.Response
-		buffer    *bytes.Buffer
 		uri, sQry string
 	)
 	// query
@@ -81,7 +101,7 @@ func (api *EtnaREST) callAPI(ctx context.Context, method, endpoint string, query
 		if !isBars {
 			uri = fmt.Sprintf("%s%s?%s", (*api).baseUrl, endpoint, sQry)
 		} else {
-			uri = fmt.Sprintf("%s%s?%s", DefaultConfig.RestUrlNonRTH, endpoint, sQry)
+			uri = fmt.Sprintf("%s%s?%s", (*api).nonRTHUrl, endpoint, sQry)
 		}
 	} else {
 		uri = fmt.Sprintf("%s%s", (*api).baseUrl, endpoint)
@@ -91,62 +111,159 @@ func (api *EtnaREST) callAPI(ctx context.Context, method, endpoint string, query
 		if bData, err = gjson.Marshal(data); err != nil {
 			return err
 		}
-		buffer = bytes.NewBuffer(bData)
-	} else {
-		buffer = &bytes.Buffer{}
 	}
-	if req, err = http.NewRequestWithContext(ctx, method, uri, buffer); err != nil {
-		return fmt.Errorf("request creation fault: %w", err)
-	} else if len(bData) > 0 {
-		(*api).header["Content-Length"] = []string{fmt.Sprintf("%d", len(bData))}
+	logAttrs((*api).log, slog.LevelDebug, "--> REST", slog.String("method", method),
+		slog.String("endpoint", endpoint), slog.String("query", sQry), slog.Int("body_size", len(bData)))
+	ctx, span := (*api).startSpan(ctx, method, endpoint)
+	defer func() { endSpan(span, err) }()
+
+	retry := (*api).retry
+	if retry == nil || !isIdempotent(method, endpoint) {
+		return (*api).authorizedRequest(ctx, method, endpoint, uri, bData, result, isBars)
 	}
-	if !isBars {
-		(*req).Header = (*api).header
-	} else {
-		(*req).Header = (*api).nonRTHHeader
+	for attempt := 1; ; attempt++ {
+		if err = (*api).authorizedRequest(ctx, method, endpoint, uri, bData, result, isBars); err == nil {
+			return nil
+		} else if ctx.Err() != nil {
+			return err
+		}
+		delay, ok := retry.delay(err, attempt)
+		if !ok {
+			return err
+		}
+		(*api).log.Info("REST: %s %s attempt #%d failed, retrying in %s: %v", method, endpoint, attempt, delay, err)
+		if !sleepCtx(ctx, delay) {
+			return err
+		}
+		(*api).instr.RequestRetried(method, endpointTemplate(endpoint), attempt+1)
+		span.AddEvent("retry", Attr{Key: "etna.attempt", Value: attempt + 1})
 	}
+}
 
-	(*api).log.Debug("--> %s %s Q:[%s] B:[[%s]]", method, endpoint, sQry, bData)
+// authorizedRequest makes the request with the authorization token. The token is renewed in advance
+// when it's about to expire, and the request is repeated once after the re-authentication
+// if the server responds with 401.
+func (api *EtnaREST) authorizedRequest(ctx context.Context, method, endpoint, uri string, bData []byte,
+	result interface{}, isBars bool) error {
+	var (
+		err    error
+		token  string
+		expire time.Time
+	)
+	if isBars {
+		return (*api).doRequest(ctx, method, endpoint, uri, (*api).nonRTHHeader, bData, result)
+	}
+	if token, expire = (*api).currentToken(); time.Now().After(expire.Add(-TokenRefreshAhead)) {
+		if err = (*api).refreshToken(ctx, token); err != nil {
+			return err
+		}
+		token, _ = (*api).currentToken()
+	}
+	err = (*api).doRequest(ctx, method, endpoint, uri, (*api).authHeader(token), bData, result)
+	if errors.Is(err, ErrUnauthorized) {
+		(*api).log.Info("REST: token is rejected, re-authenticating")
+		if err = (*api).refreshToken(ctx, token); err != nil {
+			return err
+		}
+		token, _ = (*api).currentToken()
+		err = (*api).doRequest(ctx, method, endpoint, uri, (*api).authHeader(token), bData, result)
+	}
+	return err
+}
+
+// Config returns the configuration the client was created with.
+func (api *EtnaREST) Config() *EtnaConfig {
+	return (*api).cfg
+}
+
+// SetRateLimiter sets the client-side rate limiter, which may be shared with other EtnaREST instances.
+func (api *EtnaREST) SetRateLimiter(l *RateLimiter) {
+	(*api).limiter = l
+}
+
+// SetOrderValidator enables the pre-trade validation of the placed and replaced orders. Nil disables it.
+func (api *EtnaREST) SetOrderValidator(v *OrderValidator) {
+	(*api).validator = v
+}
+
+// SetInstrumentation sets the receiver of the client metrics. Nil disables the instrumentation.
+func (api *EtnaREST) SetInstrumentation(i Instrumentation) {
+	if i == nil {
+		i = NopInstrumentation{}
+	}
+	(*api).instr = i
+}
 
+// SetRetryPolicy replaces the retry policy of the client. The nil policy disables the retries.
+func (api *EtnaREST) SetRetryPolicy(p *RetryPolicy) {
+	(*api).retry = p
+}
+
+// doRequest makes a single HTTP request with the given header and handles the response status.
+// Unsuccessful responses are returned as *APIError.
+func (api *EtnaREST) doRequest(ctx context.Context, method, endpoint, uri string, header http.Header,
+	bData []byte, result interface{}) error {
+	var (
+		err  error
+		req  *http.Request
+		resp *http.Response
+	)
+	if (*api).limiter != nil {
+		if err = (*api).limiter.Wait(ctx, endpointClass(endpoint)); err != nil {
+			return fmt.Errorf("rate limiter: %w", err)
+		}
+	}
+	if req, err = http.NewRequestWithContext(ctx, method, uri, bytes.NewReader(bData)); err != nil {
+		return fmt.Errorf("request creation fault: %w", err)
+	}
+	(*req).Header = header
+	if len(bData) > 0 {
+		(*req).Header = header.Clone()
+		(*req).Header["Content-Length"] = []string{fmt.Sprintf("%d", len(bData))}
+	}
+	if _, isNop := (*api).tracer.(NopTracer); !isNop {
+		if len(bData) == 0 {
+			(*req).Header = header.Clone()
+		}
+		(*api).tracer.Inject(ctx, (*req).Header)
+	}
+
+	start := time.Now()
 	resp, err = (*api).httpClient.Do(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	latency := time.Since(start)
+	(*api).instr.RequestDone(method, endpointTemplate(endpoint), status, latency)
+	logAttrs((*api).log, slog.LevelDebug, "<-- REST", slog.String("method", method),
+		slog.String("endpoint", endpoint), slog.Int("status", status), slog.Duration("latency", latency))
 	defer func() {
 		if resp != nil {
-			if err = resp.Body.Close(); err != nil {
+			if err := resp.Body.Close(); err != nil {
 				(*api).log.Error("can't close response body %+v\n", err)
 			}
 		}
 	}()
 
 	if err != nil {
-		return fmt.Errorf("request fault: %v", err)
+		return fmt.Errorf("request fault: %w", err)
 	}
 
 	switch resp.StatusCode {
 	case http.StatusOK:
-		if err = (*api).readBody(resp, result); err != nil {
-			return err
-		}
+		return (*api).readBody(resp, endpoint, result)
 	case http.StatusNoContent:
 		return nil
-	case http.StatusUnauthorized:
-		return fmt.Errorf("%s", resp.Status)
-	case http.StatusBadRequest:
-		if err = (*api).readBody(resp, result); err != nil {
-			return err
-		}
-		return fmt.Errorf("400, BAD_REQUEST: %s", result)
 	default:
-		if resp.StatusCode >= 500 {
-			return fmt.Errorf("%d, server error: %s", resp.StatusCode, resp.Status)
-		}
-		return fmt.Errorf("invalid request: %s", resp.Status)
+		return (*api).newAPIError(resp, endpoint)
 	}
-	return nil
 }
 
 // readBody reads the response body from the provided http.Response, attempts to unmarshal it
 // into the given result interface and returns an error if reading or unmarshaling fails.
-func (api *EtnaREST) readBody(resp *http.Response, result interface{}) error {
+// The body of the token response isn't logged.
+func (api *EtnaREST) readBody(resp *http.Response, endpoint string, result interface{}) error {
 	var (
 		err error
 		buf []byte
@@ -154,41 +271,89 @@ func (api *EtnaREST) readBody(resp *http.Response, result interface{}) error {
 	if buf, err = io.ReadAll(resp.Body); err != nil || len(buf) == 1 {
 		return fmt.Errorf("error reading v2 response body: %w", err)
 	}
-	(*api).log.Debug("REST: %s %s", (*(*resp).Request).Method, buf)
+	if endpoint != "token" {
+		(*api).log.Debug("REST: %s %s", (*(*resp).Request).Method, buf)
+	}
 	if err = gjson.Unmarshal(buf, result); err != nil {
-		if res, ok := result.(*sch.EtnaResponse); ok {
-			return fmt.Errorf("API error: %v", res)
-		}
 		return fmt.Errorf("can't unmarshal: %w", err)
 	}
 	return nil
 }
 
+// newAPIError reads the body of the unsuccessful response and builds the APIError from it.
+func (api *EtnaREST) newAPIError(resp *http.Response, endpoint string) *APIError {
+	apiErr := APIError{
+		StatusCode: resp.StatusCode, Status: resp.Status, Method: (*(*resp).Request).Method, Endpoint: endpoint,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	if buf, err := io.ReadAll(resp.Body); err != nil {
+		(*api).log.Error("can't read error response body %+v", err)
+	} else if len(buf) > 0 {
+		(*api).log.Debug("REST: %s %s %s", apiErr.Method, resp.Status, buf)
+		apiErr.Body = buf
+		_ = gjson.Unmarshal(buf, &apiErr.Response)
+		if endpoint == "token" {
+			_ = gjson.Unmarshal(buf, &apiErr.SFA)
+		}
+	}
+	return &apiErr
+}
+
+// currentToken returns the authorization token and its expiration time.
+func (api *EtnaREST) currentToken() (string, time.Time) {
+	(*api).muAuth.RLock()
+	defer (*api).muAuth.RUnlock()
+	return (*api).token, (*api).tokenExpire
+}
+
+// authHeader returns a copy of the common header with the given authorization token.
+func (api *EtnaREST) authHeader(token string) http.Header {
+	header := (*api).header.Clone()
+	header["Authorization"] = []string{"Bearer " + token}
+	return header
+}
+
+// refreshToken re-authenticates unless the `stale` token has already been renewed by a concurrent caller.
+func (api *EtnaREST) refreshToken(ctx context.Context, stale string) error {
+	(*api).muAuth.Lock()
+	defer (*api).muAuth.Unlock()
+	if (*api).token != stale && time.Now().Before((*api).tokenExpire.Add(-TokenRefreshAhead)) {
+		return nil
+	}
+	return (*api).authenticate(ctx)
+}
+
 // authenticate performs the authentication process against the API.
-// It sets the Username and Password headers and calls the "token" API endpoint.
+// It sends the Username and Password headers to the "token" API endpoint and stores the received token.
 // If the authentication fails (either due to an API error or the SFA state not being "Succeeded"),
-// it returns an error.
-func (api *EtnaREST) authenticate(ctx context.Context, login, passwd []byte) error {
+// it returns an error. The caller must hold the muAuth lock.
+func (api *EtnaREST) authenticate(ctx context.Context) (err error) {
 	var (
-		err      error
-		sfa      sch.SFA
-		decLogin = make([]byte, base64.StdEncoding.DecodedLen(len(login)))
-		decPwd   = make([]byte, base64.StdEncoding.DecodedLen(len(passwd)))
+		sfa          sch.SFA
+		login, passw string
 	)
-	if _, err = base64.StdEncoding.Decode(decLogin, login); err != nil {
-		return fmt.Errorf("wrong username %+v", err)
-	} else if _, err = base64.StdEncoding.Decode(decPwd, passwd); err != nil {
-		return fmt.Errorf("wrong password %+v", err)
-	}
-	(*api).header["Username"] = []string{string(bytes.Trim(decLogin, "\x00"))}
-	(*api).header["Password"] = []string{string(bytes.Trim(decPwd, "\x00"))}
-	err = (*api).callAPI(ctx, http.MethodPost, "token", nil, nil, &sfa, false)
-	if err != nil || sfa.State != "Succeeded" {
-		return fmt.Errorf("authentication failed: %s %s, %s, %+v", sfa.State, sfa.Step, sfa.Reason, err)
-	}
-	(*api).header.Del("Username")
-	(*api).header.Del("Password")
-	(*api).header["Authorization"] = []string{"Bearer " + sfa.Token}
+	defer func() { (*api).instr.AuthRefreshed(err) }()
+	if login, passw, err = (*api).creds.decode(); err != nil {
+		return err
+	}
+	header := (*api).header.Clone()
+	header["Username"] = []string{login}
+	header["Password"] = []string{passw}
+	(*api).log.Debug("--> %s token", http.MethodPost)
+
+	issued := time.Now()
+	err = (*api).doRequest(ctx, http.MethodPost, "token", (*api).baseUrl+"token", header, nil, &sfa)
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	} else if sfa.State != "Succeeded" {
+		return fmt.Errorf("authentication failed: %w", &APIError{
+			StatusCode: http.StatusOK, Status: "200 OK", Method: http.MethodPost, Endpoint: "token", SFA: sfa})
+	}
+	(*api).token = sfa.Token
+	lifetime := (*(*api).cfg).TokenLifetime
+	if lifetime <= 0 {
+		lifetime = TokenLifetime
+	}
+	(*api).tokenExpire = issued.Add(lifetime)
 	return nil
 }
 
@@ -200,7 +365,7 @@ func (api *EtnaREST) GetStreamers(ctx context.Context, isFMP bool) (sch.Streamer
 		vals := url.Values{"quote_source_id": {"3"}}
 		err := (*api).callAPI(ctx, http.MethodGet, endpoint, vals, nil, &resp, isFMP)
 		if err != nil {
-			return sch.Streamers{}, fmt.Errorf("getStreamers failed: %+v", err)
+			return sch.Streamers{}, fmt.Errorf("getStreamers failed: %w", err)
 		}
 		if fmpStream, exist := resp.Data["3"]; exist {
 			fmpStream.Streamers.FMPKey = resp.Data["3"].Creds["api_key"]
@@ -212,7 +377,7 @@ func (api *EtnaREST) GetStreamers(ctx context.Context, isFMP bool) (sch.Streamer
 		endpoint := "v1.0/streamers"
 		err := (*api).callAPI(ctx, http.MethodGet, endpoint, nil, nil, &resp, isFMP)
 		if err != nil {
-			return resp, fmt.Errorf("getStreamers failed: %+v", err)
+			return resp, fmt.Errorf("getStreamers failed: %w", err)
 		}
 		return resp, nil
 	}
@@ -225,7 +390,7 @@ func (api *EtnaREST) RecoverStreamerSession(ctx context.Context, sessType sch.WS
 	qry := url.Values{"sessionType": []string{fmt.Sprintf("%d", sessType)}}
 	err := (*api).callAPI(ctx, http.MethodPut, "v1.0/streamers/session/recover", qry, nil, &resp, false)
 	if err != nil {
-		return resp.Id, fmt.Errorf("recoverStreamerSession failed: %+v", err)
+		return resp.Id, fmt.Errorf("recoverStreamerSession failed: %w", err)
 	}
 	return resp.Id, nil
 }
@@ -243,7 +408,7 @@ func (api *EtnaREST) RegisterUser(ctx context.Context, params *sch.ReqUserRegist
 func (api *EtnaREST) GetUser(ctx context.Context) (sch.UserInfo, error) {
 	var resp sch.UserInfo
 	if err := (*api).callAPI(ctx, http.MethodGet, "v1.0/users/@me/info", nil, nil, &resp, false); err != nil {
-		return resp, fmt.Errorf("getUser failed: %+v", err)
+		return resp, fmt.Errorf("getUser failed: %w", err)
 	}
 	return resp, nil
 }
@@ -252,7 +417,7 @@ func (api *EtnaREST) GetUser(ctx context.Context) (sch.UserInfo, error) {
 func (api *EtnaREST) GetUserSettings(ctx context.Context) (sch.UserTradingSettings, error) {
 	var resp sch.UserTradingSettings
 	if err := (*api).callAPI(ctx, http.MethodGet, "v1.0/users/@me/settings/trading", nil, nil, &resp, false); err != nil {
-		return resp, fmt.Errorf("getUserSettings failed: %+v", err)
+		return resp, fmt.Errorf("getUserSettings failed: %w", err)
 	}
 	return resp, nil
 }
@@ -274,7 +439,7 @@ func (api *EtnaREST) GetAvailableExchanges(ctx context.Context) ([]string, error
 	var resp = make([]string, 0, 5)
 	err := (*api).callAPI(ctx, http.MethodGet, "v1.0/users/@me/exchanges", nil, nil, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("getAvailableExchanges failed: %+v", err)
+		return resp, fmt.Errorf("getAvailableExchanges failed: %w", err)
 	}
 	return resp, nil
 }
@@ -283,7 +448,7 @@ func (api *EtnaREST) GetAvailableExchanges(ctx context.Context) ([]string, error
 func (api *EtnaREST) GetUserAccounts(ctx context.Context) ([]sch.Account, error) {
 	var resp []sch.Account
 	if err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/users/@me/accounts"), nil, nil, &resp, false); err != nil {
-		return nil, fmt.Errorf("getAllAccounts failed: %+v", err)
+		return nil, fmt.Errorf("getAllAccounts failed: %w", err)
 	}
 	return resp, nil
 }
@@ -294,7 +459,7 @@ func (api *EtnaREST) GetBalance(ctx context.Context, accId uint32) (sch.TradingB
 
 	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/info", accId), nil, nil, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("getBalance failed: %+v", err)
+		return resp, fmt.Errorf("getBalance failed: %w", err)
 	}
 	return resp, nil
 }
@@ -307,56 +472,78 @@ func (api *EtnaREST) GetBalanceHistory(ctx context.Context, accId uint32,
 	qry := url.Values{"startDate": {fromTs}, "endDate": {tillTs}, "step": {"1"}}
 	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/history", accId), qry, nil, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("getBalanceHistory failed: %+v", err)
+		return resp, fmt.Errorf("getBalanceHistory failed: %w", err)
 	}
 	return resp, nil
 }
 
-// GetPositions retrieves a slice of positions for the authenticated user.
+// GetPositions retrieves all positions of the account for the authenticated user.
 func (api *EtnaREST) GetPositions(ctx context.Context, accId uint32) ([]sch.Position, error) {
-	var resp sch.RespPositions
+	resp, err := (*api).PositionsIter(ctx, accId, nil).All()
+	if err != nil {
+		return resp, fmt.Errorf("getPositions failed: %w", err)
+	}
+	return resp, nil
+}
 
-	qry := url.Values{"pageNumber": {"0"}, "pageSize": {"99"}, "sortField": {"Symbol"}, "desc": {"false"}}
-	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/positions", accId), qry, nil, &resp, false)
+// FindPositions retrieves the positions of the account selected by the filter.
+func (api *EtnaREST) FindPositions(ctx context.Context, accId uint32, filter *PositionFilter) ([]sch.Position, error) {
+	resp, err := (*api).PositionsIter(ctx, accId, filter).All()
 	if err != nil {
-		return resp.Result, fmt.Errorf("getPositions failed: %+v", err)
+		return resp, fmt.Errorf("findPositions failed: %w", err)
 	}
-	return resp.Result, nil
+	return resp, nil
 }
 
 /*
  * Transfers
  */
 
+// GetTransfers retrieves all transfers of the account.
 func (api *EtnaREST) GetTransfers(ctx context.Context, accId uint32) ([]sch.Transfer, error) {
-	var resp sch.RespTransfers
+	resp, err := (*api).TransfersIter(ctx, accId, nil).All()
+	if err != nil {
+		return resp, fmt.Errorf("getTransfers failed: %w", err)
+	}
+	return resp, nil
+}
 
-	qry := url.Values{"pageNumber": {"0"}, "pageSize": {"99"}, "sortBy": {"TransferDate"}, "isDesc": {"false"}}
-	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/transfers", accId), qry, nil, &resp, false)
+// FindTransfers retrieves the transfers of the account selected by the filter.
+func (api *EtnaREST) FindTransfers(ctx context.Context, accId uint32, filter *TransferFilter) ([]sch.Transfer, error) {
+	resp, err := (*api).TransfersIter(ctx, accId, filter).All()
 	if err != nil {
-		return resp.Result, fmt.Errorf("getTransfers failed: %+v", err)
+		return resp, fmt.Errorf("findTransfers failed: %w", err)
 	}
-	return resp.Result, nil
+	return resp, nil
 }
 
 /*
  * Orders, trades
  */
 
-// GetOrders retrieves a list of orders for a specific account.
+// GetOrders retrieves all orders for a specific account.
 // Supports filtering for active orders and returns them sorted by creation date.
 func (api *EtnaREST) GetOrders(ctx context.Context, accId uint32, active bool) ([]sch.Order, error) {
-	var resp sch.RespOrders
-
-	qry := url.Values{"pageNumber": {"0"}, "pageSize": {"99"}, "sortField": {"CreateDate"}, "desc": {"false"}}
+	var filter *OrderFilter
 	if active {
-		qry.Set("filter", "Status in (0,1,10)")
+		filter = ActiveOrdersFilter()
+	}
+	resp, err := (*api).OrdersIter(ctx, accId, filter).All()
+	if err != nil {
+		return resp, fmt.Errorf("getOrders failed: %w", err)
 	}
-	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/orders", accId), qry, nil, &resp, false)
+	return resp, nil
+}
+
+// FindOrders retrieves the orders of the account selected by the filter, e.g.
+//
+//	NewOrderFilter().Symbol("AAPL").Sides(sch.SideBuy).CreatedFrom(ts).Sort("CreateDate", true)
+func (api *EtnaREST) FindOrders(ctx context.Context, accId uint32, filter *OrderFilter) ([]sch.Order, error) {
+	resp, err := (*api).OrdersIter(ctx, accId, filter).All()
 	if err != nil {
-		return resp.Result, fmt.Errorf("getOrders failed: %+v", err)
+		return resp, fmt.Errorf("findOrders failed: %w", err)
 	}
-	return resp.Result, nil
+	return resp, nil
 }
 
 // GetOrder retrieves details for a specific order within an account.
@@ -365,28 +552,102 @@ func (api *EtnaREST) GetOrder(ctx context.Context, accId uint32, orderId uint64)
 
 	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/accounts/%d/orders/%d", accId, orderId), nil, nil, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("getOrder failed: %+v", err)
+		return resp, fmt.Errorf("getOrder failed: %w", err)
 	}
 	return resp, nil
 }
 
 // PlaceOrder submits a new order for a specific account.
-// It automatically sets default values for TimeInforce and ExtendedHours if not provided.
-func (api *EtnaREST) PlaceOrder(ctx context.Context, accId uint32, params *sch.OrderParams) (sch.Order, error) {
-	var resp sch.Order
+// It automatically sets default values for TimeInforce and ExtendedHours if not provided,
+// and generates the ClientId if it's absent. The params aren't modified, the generated ClientId is returned
+// in the order. If the OrderValidator is set, the order is validated before the submission.
+// On an ambiguous failure (timeout, connection reset, 5xx) the order is looked up by the ClientId
+// and the existing one with the same symbol, side, type, quantity and prices is returned, otherwise the submission is retried
+// per the retry policy.
+func (api *EtnaREST) PlaceOrder(ctx context.Context, accId uint32, orderParams *sch.OrderParams) (sch.Order, error) {
+	var (
+		err  error
+		resp sch.Order
+	)
 
+	p := *orderParams
+	params := &p
 	if params.TimeInforce == "" {
 		params.TimeInforce = sch.TimeInForceGTC
 	}
 	if params.ExtendedHours == "" {
 		params.ExtendedHours = sch.SessAll
 	}
+	if params.ClientId == "" {
+		if params.ClientId, err = newClientId(); err != nil {
+			return resp, fmt.Errorf("placeOrder failed: %w", err)
+		}
+	}
+	if (*api).validator != nil {
+		if err = (*api).validator.Validate(ctx, params); err != nil {
+			return resp, fmt.Errorf("placeOrder failed: %w", err)
+		}
+	}
+	if ot := (*api).orderTracer; ot != nil {
+		ctx = ot.begin(ctx, accId, params)
+		resp, err = (*api).submitOrder(ctx, accId, params)
+		ot.placed(params.ClientId, resp, err)
+		return resp, err
+	}
+	return (*api).submitOrder(ctx, accId, params)
+}
 
-	err := (*api).callAPI(ctx, http.MethodPost, fmt.Sprintf("v1.0/accounts/%d/orders", accId), nil, params, &resp, false)
-	if err != nil {
-		return resp, fmt.Errorf("placeOrder failed: %+v", err)
+// submitOrder posts the order, repeating it according to the retry policy unless it's found by the ClientId.
+func (api *EtnaREST) submitOrder(ctx context.Context, accId uint32, params *sch.OrderParams) (sch.Order, error) {
+	var (
+		err      error
+		resp     sch.Order
+		endpoint = fmt.Sprintf("v1.0/accounts/%d/orders", accId)
+	)
+
+	for attempt := 1; ; attempt++ {
+		if err = (*api).callAPI(ctx, http.MethodPost, endpoint, nil, params, &resp, false); err == nil {
+			if resp.ClientId == "" {
+				resp.ClientId = params.ClientId
+			}
+			return resp, nil
+		} else if ctx.Err() != nil {
+			break
+		}
+		if isAmbiguousErr(err) {
+			existing, lookupErr := (*api).FindOrders(ctx, accId, NewOrderFilter().ClientId(params.ClientId))
+			if lookupErr != nil {
+				return resp, fmt.Errorf("placeOrder failed: %w", errors.Join(err, lookupErr))
+			} else if len(existing) == 1 && isSameOrder(existing[0], params) {
+				(*api).log.Info("REST: order %s is found after the failure: %d", params.ClientId, existing[0].Id)
+				return existing[0], nil
+			} else if len(existing) > 0 {
+				// the ClientId is reused, the submission can't be confirmed or safely repeated
+				return resp, fmt.Errorf("placeOrder failed: %w", errors.Join(err,
+					fmt.Errorf("%d other orders have ClientId %s", len(existing), params.ClientId)))
+			}
+		}
+		if (*api).retry == nil {
+			break
+		}
+		delay, ok := (*api).retry.delay(err, attempt)
+		if !ok {
+			break
+		}
+		(*api).log.Info("REST: order %s attempt #%d failed, retrying in %s: %v", params.ClientId, attempt, delay, err)
+		if !sleepCtx(ctx, delay) {
+			break
+		}
+		(*api).instr.RequestRetried(http.MethodPost, endpointTemplate(endpoint), attempt+1)
 	}
-	return resp, nil
+	return resp, fmt.Errorf("placeOrder failed: %w", err)
+}
+
+// isSameOrder reports whether the order found by the ClientId matches the submitted one.
+func isSameOrder(order sch.Order, params *sch.OrderParams) bool {
+	return strings.EqualFold(order.Symbol, params.Symbol) && strings.EqualFold(string(order.Side), string(params.Side)) &&
+		strings.EqualFold(string(order.Type), string(params.Type)) && order.Quantity == params.Quantity &&
+		order.Price == params.Price && order.StopPrice == params.StopPrice
 }
 
 // ReplaceOrder modifies an existing order for a specific account.
@@ -394,10 +655,18 @@ func (api *EtnaREST) ReplaceOrder(ctx context.Context, accId uint32, orderId uin
 	params *sch.OrderParams) (sch.Order, error) {
 	var resp sch.Order
 
+	if (*api).validator != nil {
+		if err := (*api).validator.Validate(ctx, params); err != nil {
+			return resp, fmt.Errorf("replaceOrder failed: %w", err)
+		}
+	}
 	err := (*api).callAPI(ctx, http.MethodPut, fmt.Sprintf("v1.0/accounts/%d/orders/%d", accId, orderId),
 		nil, params, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("replaceOrder failed: %+v", err)
+		return resp, fmt.Errorf("replaceOrder failed: %w", err)
+	}
+	if ot := (*api).orderTracer; ot != nil {
+		ot.replaced(orderId, resp)
 	}
 	return resp, nil
 }
@@ -406,7 +675,7 @@ func (api *EtnaREST) ReplaceOrder(ctx context.Context, accId uint32, orderId uin
 func (api *EtnaREST) CancelOrder(ctx context.Context, accId uint32, orderId uint64) error {
 	err := (*api).callAPI(ctx, http.MethodDelete, fmt.Sprintf("v1.0/accounts/%d/orders/%d", accId, orderId), nil, nil, nil, false)
 	if err != nil {
-		return fmt.Errorf("cancelOrder failed: %+v", err)
+		return fmt.Errorf("cancelOrder failed: %w", err)
 	}
 	return nil
 }
@@ -420,7 +689,7 @@ func (api *EtnaREST) GetSecurity(ctx context.Context, symbol string) (sch.Securi
 	var resp sch.Security
 	err := (*api).callAPI(ctx, http.MethodGet, fmt.Sprintf("v1.0/equities/%s", symbol), nil, nil, &resp, false)
 	if err != nil {
-		return resp, fmt.Errorf("getSecurity failed: %+v", err)
+		return resp, fmt.Errorf("getSecurity failed: %w", err)
 	}
 	// if _, exist := NasdaqMICs[resp.Exchange]; exist {
 	// 	resp.Exchange = NASDAQ
@@ -445,7 +714,7 @@ func (api *EtnaREST) GetBars(ctx context.Context, params *sch.ReqBars) ([]sch.Ba
 		return nil, err
 	}
 	if err := (*api).callAPI(ctx, http.MethodGet, "v1/market-data/ohlc", vals, nil, &resp, true); err != nil {
-		return nil, fmt.Errorf("getBars failed: %+v", err)
+		return nil, fmt.Errorf("getBars failed: %w", err)
 	} else if !resp.Success || len(resp.Data) == 0 {
 		return nil, fmt.Errorf("bars are absent: %s", resp.Message)
 	}
@@ -469,3 +738,12 @@ func useLocalEtnaCert() (*tls.Config, error) {
 	}
 	return &cfg, nil
 }
+
+// newClientId generates the random order id on the client's side.
+func newClientId() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("can't generate client id: %w", err)
+	}
+	return "ge" + hex.EncodeToString(buf), nil
+}
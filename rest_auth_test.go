@@ -0,0 +1,122 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubAuth issues the tokens "t1", "t2"... and accepts the last one only.
+type stubAuth struct {
+	mu      sync.Mutex
+	issued  int
+	valid   string
+	reqs    int  // the authorized requests
+	reject  bool // all tokens are rejected
+	delayed time.Duration
+}
+
+func (a *stubAuth) routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"POST /token": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep((*a).delayed)
+			(*a).mu.Lock()
+			defer (*a).mu.Unlock()
+			(*a).issued++
+			(*a).valid = fmt.Sprintf("t%d", (*a).issued)
+			_, _ = fmt.Fprintf(w, `{"State":"Succeeded","Token":%q}`, (*a).valid)
+		},
+		"GET /v1.0/users/@me/info": func(w http.ResponseWriter, r *http.Request) {
+			(*a).mu.Lock()
+			defer (*a).mu.Unlock()
+			(*a).reqs++
+			if (*a).reject || r.Header.Get("Authorization") != "Bearer "+(*a).valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"UserId":42}`))
+		},
+	}
+}
+
+// counts returns the numbers of the authentications and the authorized requests.
+func (a *stubAuth) counts() (int, int) {
+	(*a).mu.Lock()
+	defer (*a).mu.Unlock()
+	return (*a).issued, (*a).reqs
+}
+
+// revoke invalidates the issued tokens.
+func (a *stubAuth) revoke() {
+	(*a).mu.Lock()
+	(*a).valid = ""
+	(*a).mu.Unlock()
+}
+
+func TestAuthorizedRequestConcurrent(t *testing.T) {
+	const callers = 10
+	a := stubAuth{delayed: 20 * time.Millisecond}
+	rest := newStubREST(t, a.routes())
+	a.revoke()
+
+	// the callers get 401 with the revoked token, the first of them re-authenticates, the others wait for it
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rest.GetUser(context.Background()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		(*t).Errorf("the request failed: %v", err)
+	}
+	if issued, reqs := a.counts(); issued != 2 {
+		(*t).Errorf("wrong number of the authentications: %d", issued)
+	} else if reqs != 2*callers {
+		(*t).Errorf("wrong number of the requests: %d", reqs)
+	}
+}
+
+func TestAuthorizedRequestRetry(t *testing.T) {
+	a := stubAuth{reject: true}
+	rest := newStubREST(t, a.routes())
+	_, err := rest.GetUser(context.Background())
+	if issued, reqs := a.counts(); !errors.Is(err, ErrUnauthorized) {
+		(*t).Errorf("wrong error: %v", err)
+	} else if issued != 2 || reqs != 2 {
+		(*t).Errorf("the request isn't repeated once: %d authentications, %d requests", issued, reqs)
+	}
+}
+
+func TestAuthorizedRequestRefreshAhead(t *testing.T) {
+	var a stubAuth
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	(*cfg).TokenLifetime = TokenRefreshAhead + 50*time.Millisecond
+	rest := newStubREST(t, a.routes(), WithEtnaConfig(cfg))
+	if _, err = rest.GetUser(context.Background()); err != nil {
+		(*t).Fatal(err)
+	} else if issued, _ := a.counts(); issued != 1 {
+		(*t).Fatalf("the fresh token is renewed: %d authentications", issued)
+	}
+
+	// the token is renewed before the request, so it isn't rejected
+	time.Sleep(60 * time.Millisecond)
+	if _, err = rest.GetUser(context.Background()); err != nil {
+		(*t).Fatal(err)
+	} else if issued, reqs := a.counts(); issued != 2 || reqs != 2 {
+		(*t).Errorf("the token isn't renewed ahead: %d authentications, %d requests", issued, reqs)
+	}
+}
@@ -0,0 +1,100 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestIsSameOrder(t *testing.T) {
+	params := sch.OrderParams{Symbol: "AAPL", Side: sch.SideBuy, Type: sch.OrderStopLimit, Quantity: 10, Price: 101,
+		StopPrice: 100}
+	same := sch.Order{Symbol: "aapl", Side: sch.SideBuy, Type: sch.OrderStopLimit, Quantity: 10, Price: 101, StopPrice: 100}
+	tests := map[string]struct {
+		change func(o *sch.Order)
+		expect bool
+	}{
+		"same":       {change: func(o *sch.Order) {}, expect: true},
+		"symbol":     {change: func(o *sch.Order) { o.Symbol = "TSLA" }},
+		"side":       {change: func(o *sch.Order) { o.Side = sch.SideSell }},
+		"type":       {change: func(o *sch.Order) { o.Type = sch.OrderLimit }},
+		"quantity":   {change: func(o *sch.Order) { o.Quantity = 5 }},
+		"price":      {change: func(o *sch.Order) { o.Price = 102 }},
+		"stop_price": {change: func(o *sch.Order) { o.StopPrice = 99 }},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			order := same
+			tc.change(&order)
+			if res := isSameOrder(order, &params); res != tc.expect {
+				(*t).Errorf("wrong result: %t", res)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderAmbiguousFailure(t *testing.T) {
+	params := sch.OrderParams{Symbol: "AAPL", Side: sch.SideBuy, Type: sch.OrderLimit, Quantity: 10, Price: 100}
+	tests := map[string]struct {
+		found     []sch.Order // the orders with the ClientId after the failure
+		expectId  uint64
+		posts     int
+		expectErr bool
+	}{
+		"found": {
+			found:    []sch.Order{{Id: 7, Symbol: "AAPL", Side: sch.SideBuy, Type: sch.OrderLimit, Quantity: 10, Price: 100}},
+			expectId: 7, posts: 1},
+		"resubmitted": {expectId: 9, posts: 2},
+		"other_order": {
+			found:     []sch.Order{{Id: 7, Symbol: "AAPL", Side: sch.SideSell, Type: sch.OrderLimit, Quantity: 10, Price: 100}},
+			posts:     1,
+			expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				posts int
+			)
+			rest := newStubREST(t, map[string]http.HandlerFunc{
+				// the first submission times out on the server side
+				"POST /v1.0/accounts/1/orders": func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					posts++
+					n := posts
+					mu.Unlock()
+					if n == 1 {
+						w.WriteHeader(http.StatusGatewayTimeout)
+						return
+					}
+					var p sch.OrderParams
+					_ = gjson.NewDecoder(r.Body).Decode(&p)
+					_, _ = fmt.Fprintf(w, `{"Id":9,"ClientId":%q}`, p.ClientId)
+				},
+				"GET /v1.0/accounts/1/orders": func(w http.ResponseWriter, r *http.Request) {
+					body, _ := gjson.Marshal(sch.RespOrders{Result: tc.found, TotalCount: uint32(len(tc.found))})
+					_, _ = w.Write(body)
+				},
+			}, WithRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond,
+				MaxBackoff: time.Millisecond, Multiplier: 1,
+				RetryableStatuses: map[int]struct{}{http.StatusGatewayTimeout: {}}}))
+
+			order, err := rest.PlaceOrder(context.Background(), 1, &params)
+			mu.Lock()
+			defer mu.Unlock()
+			if (err != nil) != tc.expectErr {
+				(*t).Errorf("wrong error: %v", err)
+			} else if order.Id != tc.expectId {
+				(*t).Errorf("wrong order: %d", order.Id)
+			} else if posts != tc.posts {
+				(*t).Errorf("wrong number of the submissions: %d", posts)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package goetna
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newStubREST creates the client of the test server serving the routes by "METHOD /path",
+// the token is issued by the default route unless it's overridden. The options are applied after the defaults.
+func newStubREST(t *testing.T, routes map[string]http.HandlerFunc, opts ...Option) *EtnaREST {
+	mux := http.NewServeMux()
+	if _, exist := routes["POST /token"]; !exist {
+		mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"State":"Succeeded","Token":"token"}`))
+		})
+	}
+	for pattern, h := range routes {
+		mux.HandleFunc(pattern, h)
+	}
+	srv := httptest.NewServer(mux)
+	(*t).Cleanup(srv.Close)
+
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	login := []byte(base64.StdEncoding.EncodeToString([]byte("login")))
+	passwd := []byte(base64.StdEncoding.EncodeToString([]byte("passwd")))
+	opts = append([]Option{WithEtnaConfig(cfg), WithBaseURL((*srv).URL + "/"), WithCredentials(login, passwd),
+		WithRetryPolicy(nil)}, opts...)
+	rest, err := New(opts...)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	return rest
+}
+
+// respond returns the handler writing the body.
+func respond(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"context"
 	"encoding/base64"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/joho/godotenv"
 	sch "github.com/long-js/goetna/schema"
 )
 
@@ -19,7 +21,26 @@ type TestTable map[string]struct {
 	expect map[string]interface{}
 }
 
-var rest, ctx = createREST(false)
+var (
+	testCfg   = loadTestConfig()
+	rest, ctx = createREST(false)
+)
+
+// loadTestConfig loads the .env file and selects the demo profile if TEST_ENV is true.
+func loadTestConfig() *EtnaConfig {
+	_ = godotenv.Load()
+	profile := ProfileLive
+	if isTest, err := strconv.ParseBool(os.Getenv("TEST_ENV")); err == nil && isTest {
+		profile = ProfileDemo
+	}
+	cfg, err := NewConfig(profile)
+	if err != nil {
+		panic(err)
+	} else if err = cfg.ApplyEnv("ETNA_CFG_"); err != nil {
+		panic(err)
+	}
+	return cfg
+}
 
 func loadCreds() ([]byte, []byte) {
 	login := []byte(os.Getenv("ETNA_LOGIN"))
@@ -35,7 +56,9 @@ func createREST(isPrivate bool) (*EtnaREST, context.Context) {
 	l, p := loadCreds()
 
 	c := context.Background()
-	r, err := NewEtnaREST(os.Getenv("ETNA_KEY"), os.Getenv("ETNA_NRTH_TOKEN"), l, p, isPrivate, ColouredLogger("REST"))
+	r, err := New(WithEtnaConfig(testCfg), WithAPIKey(os.Getenv("ETNA_KEY")),
+		WithNonRTHToken(os.Getenv("ETNA_NRTH_TOKEN")), WithCredentials(l, p), WithPrivateAPI(isPrivate),
+		WithLogger(ColouredLogger("REST")), WithContext(c))
 	if err != nil {
 		panic(err)
 	}
@@ -139,14 +162,14 @@ func TestGetStreamers(t *testing.T) {
 
 	if resp, err = rest.GetStreamers(ctx, true); err != nil {
 		(*t).Error(err)
-	} else if len(resp.QuoteAddresses) == 0 || resp.QuoteAddresses[0].Url != DefaultConfig.WSUrlPubFMP {
+	} else if len(resp.QuoteAddresses) == 0 || resp.QuoteAddresses[0].Url != testCfg.WSUrlPubFMP {
 		(*t).Errorf("wrong streamers: %+v", resp.QuoteAddresses)
 	} else {
 		(*t).Logf("STREAMERS: %+v\n", resp)
 	}
 	if resp, err = rest.GetStreamers(ctx, false); err != nil {
 		(*t).Error(err)
-	} else if len(resp.QuoteAddresses) == 0 || strings.TrimSuffix(resp.QuoteAddresses[0].Url, ":443") != DefaultConfig.WSUrlPub {
+	} else if len(resp.QuoteAddresses) == 0 || strings.TrimSuffix(resp.QuoteAddresses[0].Url, ":443") != testCfg.WSUrlPub {
 		(*t).Errorf("wrong streamers: %+v", resp.QuoteAddresses)
 	} else {
 		(*t).Logf("STREAMERS: %+v\n", resp)
@@ -345,3 +368,22 @@ func TestCancelOrder(t *testing.T) {
 		(*t).Error(err)
 	}
 }
+
+func TestOrdersIter(t *testing.T) {
+	(*t).Skip()
+	var (
+		cnt   int
+		accId = uint32(421) // 292
+	)
+	it := rest.OrdersIter(ctx, accId, nil)
+	for it.Next() {
+		cnt++
+	}
+	if err := it.Err(); err != nil {
+		(*t).Error(err)
+	} else if ords, err := rest.GetOrders(ctx, accId, false); err != nil {
+		(*t).Error(err)
+	} else if len(ords) != cnt {
+		(*t).Errorf("wrong number of orders: %d != %d", cnt, len(ords))
+	}
+}
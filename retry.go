@@ -0,0 +1,152 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxRetryAfter limits the server's Retry-After value.
+const maxRetryAfter = time.Hour
+
+// RetryPolicy describes how the failed REST requests are repeated.
+// Only the idempotent requests (GET, HEAD, PUT, DELETE) are retried, except the order replacement
+// and cancellation, which may change the order twice if the first attempt has reached the server.
+type RetryPolicy struct {
+	MaxAttempts       int              // total number of attempts including the first one
+	InitialBackoff    time.Duration    // delay before the second attempt
+	MaxBackoff        time.Duration    // upper limit of the delay
+	Multiplier        float64          // growth factor of the delay
+	Jitter            float64          // randomization factor of the delay, 0..1
+	RetryableStatuses map[int]struct{} // HTTP statuses worth repeating the request for
+}
+
+// DefaultRetryPolicy returns the policy with 3 attempts and exponential backoff from 200ms to 5s.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         .2,
+		RetryableStatuses: map[int]struct{}{
+			http.StatusTooManyRequests:     {},
+			http.StatusInternalServerError: {},
+			http.StatusBadGateway:          {},
+			http.StatusServiceUnavailable:  {},
+			http.StatusGatewayTimeout:      {},
+		},
+	}
+}
+
+// Backoff returns the delay before the given attempt (the first retry is the attempt 2).
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	return expBackoff((*p).InitialBackoff, (*p).MaxBackoff, (*p).Multiplier, (*p).Jitter, attempt-1)
+}
+
+// delay returns the period to wait before the next attempt, or false if the error isn't retryable.
+func (p *RetryPolicy) delay(err error, attempt int) (time.Duration, bool) {
+	if attempt >= (*p).MaxAttempts {
+		return 0, false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if _, ok := (*p).RetryableStatuses[apiErr.StatusCode]; !ok {
+			return 0, false
+		} else if apiErr.RetryAfter > 0 && (*p).MaxBackoff > 0 {
+			return min(apiErr.RetryAfter, (*p).MaxBackoff), true
+		} else if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+		return (*p).Backoff(attempt + 1), true
+	} else if isTransientErr(err) {
+		return (*p).Backoff(attempt + 1), true
+	}
+	return 0, false
+}
+
+// expBackoff calculates the exponentially growing delay for the n-th retry (starting from 1),
+// limited by `maxDelay` and randomized by the `jitter` factor.
+func expBackoff(initial, maxDelay time.Duration, multiplier, jitter float64, n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(initial) * math.Pow(multiplier, float64(n-1))
+	if maxDelay > 0 && d > float64(maxDelay) {
+		d = float64(maxDelay)
+	}
+	if jitter > 0 {
+		d *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// isTransientErr reports whether the transport error is probably temporary: timeouts, connection resets etc.
+// The callers must check their context, since its expiration looks like a timeout as well.
+func isTransientErr(err error) bool {
+	var netErr net.Error
+
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// isIdempotent reports whether the request can be safely repeated.
+// The order replacement (PUT) and cancellation (DELETE) aren't repeated automatically.
+func isIdempotent(method, endpoint string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPut, http.MethodDelete:
+		return !isOrderEndpoint(endpoint)
+	}
+	return false
+}
+
+// isAmbiguousErr reports whether the request might have been processed by the server despite the error.
+func isAmbiguousErr(err error) bool {
+	return isTransientErr(err) || errors.Is(err, ErrServer)
+}
+
+// sleepCtx waits for the period, it returns false if the context is cancelled earlier.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either seconds or HTTP date.
+// The value is limited by maxRetryAfter, the retry policy limits it by MaxBackoff further.
+func parseRetryAfter(value string) time.Duration {
+	var d time.Duration
+	if value == "" {
+		return 0
+	} else if sec, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(min(sec, int(maxRetryAfter/time.Second))) * time.Second
+	} else if ts, err := http.ParseTime(value); err == nil {
+		d = time.Until(ts)
+	}
+	return max(0, min(d, maxRetryAfter))
+}
@@ -0,0 +1,105 @@
+package goetna
+
+import (
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExpBackoff(t *testing.T) {
+	tests := map[string]struct {
+		initial, maxDelay    time.Duration
+		multiplier, jitter   float64
+		n                    int
+		expectMin, expectMax time.Duration
+	}{
+		"first":       {initial: time.Second, multiplier: 2, n: 1, expectMin: time.Second, expectMax: time.Second},
+		"third":       {initial: time.Second, multiplier: 2, n: 3, expectMin: 4 * time.Second, expectMax: 4 * time.Second},
+		"zero_n":      {initial: time.Second, multiplier: 2, n: 0, expectMin: time.Second, expectMax: time.Second},
+		"limited":     {initial: time.Second, maxDelay: 3 * time.Second, multiplier: 2, n: 10, expectMin: 3 * time.Second, expectMax: 3 * time.Second},
+		"flat":        {initial: time.Second, multiplier: .5, n: 5, expectMin: time.Second, expectMax: time.Second},
+		"jitter":      {initial: time.Second, multiplier: 2, jitter: .2, n: 2, expectMin: 1600 * time.Millisecond, expectMax: 2400 * time.Millisecond},
+		"jitter_over": {initial: time.Second, maxDelay: 2 * time.Second, multiplier: 2, jitter: .5, n: 5, expectMin: time.Second, expectMax: 3 * time.Second},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := expBackoff(tc.initial, tc.maxDelay, tc.multiplier, tc.jitter, tc.n); d < tc.expectMin || d > tc.expectMax {
+					(*t).Fatalf("wrong delay: %s", d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	(*policy).Jitter = 0
+	tests := map[string]struct {
+		err     error
+		attempt int
+		delay   time.Duration
+		retry   bool
+	}{
+		"server_error":   {err: &APIError{StatusCode: http.StatusBadGateway}, attempt: 1, delay: 200 * time.Millisecond, retry: true},
+		"second_retry":   {err: &APIError{StatusCode: http.StatusBadGateway}, attempt: 2, delay: 400 * time.Millisecond, retry: true},
+		"exhausted":      {err: &APIError{StatusCode: http.StatusBadGateway}, attempt: 3},
+		"bad_request":    {err: &APIError{StatusCode: http.StatusBadRequest}, attempt: 1},
+		"retry_after":    {err: &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second}, attempt: 1, delay: 2 * time.Second, retry: true},
+		"retry_after_ex": {err: &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}, attempt: 1, delay: 5 * time.Second, retry: true},
+		"conn_reset":     {err: fmt.Errorf("read: %w", syscall.ECONNRESET), attempt: 1, delay: 200 * time.Millisecond, retry: true},
+		"other":          {err: fmt.Errorf("decoding fault"), attempt: 1},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if delay, retry := policy.delay(tc.err, tc.attempt); delay != tc.delay || retry != tc.retry {
+				(*t).Errorf("wrong delay: %s %t", delay, retry)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]struct {
+		value  string
+		expect time.Duration
+	}{
+		"empty":    {value: "", expect: 0},
+		"seconds":  {value: "120", expect: 2 * time.Minute},
+		"negative": {value: "-5", expect: 0},
+		"huge":     {value: "99999999999", expect: maxRetryAfter},
+		"past":     {value: "Wed, 21 Oct 2015 07:28:00 GMT", expect: 0},
+		"far_date": {value: time.Now().Add(48 * time.Hour).UTC().Format(http.TimeFormat), expect: maxRetryAfter},
+		"garbage":  {value: "soon", expect: 0},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if d := parseRetryAfter(tc.value); d != tc.expect {
+				(*t).Errorf("wrong duration: %s", d)
+			}
+		})
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := map[string]struct {
+		method, endpoint string
+		expect           bool
+	}{
+		"get_order":     {method: http.MethodGet, endpoint: "v1.0/accounts/1/orders/2", expect: true},
+		"place_order":   {method: http.MethodPost, endpoint: "v1.0/accounts/1/orders"},
+		"replace_order": {method: http.MethodPut, endpoint: "v1.0/accounts/1/orders/2"},
+		"cancel_order":  {method: http.MethodDelete, endpoint: "v1.0/accounts/1/orders/2"},
+		"put_other":     {method: http.MethodPut, endpoint: "v1.0/watchlists/1", expect: true},
+		"delete_other":  {method: http.MethodDelete, endpoint: "v1.0/watchlists/1", expect: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := isIdempotent(tc.method, tc.endpoint); res != tc.expect {
+				(*t).Errorf("wrong result: %t", res)
+			}
+		})
+	}
+}
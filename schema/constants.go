@@ -81,3 +81,50 @@ const (
 )
 
 var WSPongMsg = []byte("{\"Cmd\":\"Pong\",\"StatusCode\":\"Ok\"}")
+
+// OrderStatus is the numeric order status used in the ETNA filter expressions.
+type OrderStatus uint8
+
+const (
+	StatusNew                OrderStatus = 0
+	StatusPartiallyFilled    OrderStatus = 1
+	StatusFilled             OrderStatus = 2
+	StatusDoneForDay         OrderStatus = 3
+	StatusCanceled           OrderStatus = 4
+	StatusReplaced           OrderStatus = 5
+	StatusPendingCancel      OrderStatus = 6
+	StatusStopped            OrderStatus = 7
+	StatusRejected           OrderStatus = 8
+	StatusSuspended          OrderStatus = 9
+	StatusPendingNew         OrderStatus = 10
+	StatusCalculated         OrderStatus = 11
+	StatusExpired            OrderStatus = 12
+	StatusAcceptedForBidding OrderStatus = 13
+	StatusPendingReplace     OrderStatus = 14
+)
+
+var orderStatusNames = [...]string{
+	"New", "PartiallyFilled", "Filled", "DoneForDay", "Canceled", "Replaced", "PendingCancel", "Stopped",
+	"Rejected", "Suspended", "PendingNew", "Calculated", "Expired", "AcceptedForBidding", "PendingReplace",
+}
+
+// String returns the status name as it appears in Order.Status.
+func (s OrderStatus) String() string {
+	if int(s) < len(orderStatusNames) {
+		return orderStatusNames[s]
+	}
+	return "Unknown"
+}
+
+// ParseOrderStatus returns the numeric status of the Order.Status name.
+func ParseOrderStatus(name string) (OrderStatus, bool) {
+	for i, n := range orderStatusNames {
+		if n == name {
+			return OrderStatus(i), true
+		}
+	}
+	if name == "Cancelled" {
+		return StatusCanceled, true
+	}
+	return 0, false
+}
@@ -62,6 +62,16 @@ func (o *Order) Parse(values map[string]string) error {
 		switch k {
 		case "Id":
 			(*o).Id, err = strconv.ParseUint(v, 10, 64)
+		case "StateId":
+			(*o).StateId, err = strconv.ParseInt(v, 10, 64)
+		case "RequestId":
+			(*o).RequestId, err = strconv.ParseInt(v, 10, 64)
+		case "ParentId":
+			(*o).ParentId, err = strconv.ParseInt(v, 10, 64)
+		case "ParentRequestId":
+			(*o).ParentRequestId, err = strconv.ParseInt(v, 10, 64)
+		case "ClientId":
+			(*o).ClientId = v
 		case "Quantity":
 			(*o).Quantity, err = strconv.ParseFloat(v, 64)
 		case "Price":
@@ -128,7 +138,7 @@ type RespOrders struct {
 	Result           []Order `json:"Result"`
 	NextPageLink     string  `json:"NextPageLink"`
 	PreviousPageLink string  `json:"PreviousPageLink"`
-	TotalCount       uint8   `json:"TotalCount"`
+	TotalCount       uint32  `json:"TotalCount"`
 }
 
 type OrderParams struct {
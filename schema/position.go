@@ -23,12 +23,29 @@ type Position struct {
 	SecurityType       string    `json:"SecurityType"`
 	CreateDate         time.Time `json:"CreateDate"`
 	ModifyDate         time.Time `json:"ModifyDate"`
+	parsed             uint16    // the bits of the keys set by Parse
+	partial            bool      // the position is parsed from the WebSocket message, which may omit keys
+}
+
+// positionKeys are the bits of the WebSocket message keys.
+var positionKeys = map[string]uint16{
+	"Id": 1, "AccountId": 1 << 1, "SecurityId": 1 << 2, "ContractSize": 1 << 3, "Quantity": 1 << 4,
+	"RealizedProfitLoss": 1 << 5, "CostBasis": 1 << 6, "AverageOpenPrice": 1 << 7, "Symbol": 1 << 8,
+	"Exchange": 1 << 9, "Currency": 1 << 10, "SecurityType": 1 << 11, "CreateDate": 1 << 12, "ModifyDate": 1 << 13,
+}
+
+// IsSet reports whether the key of the WebSocket message (e.g. "Quantity", "ContractSize") is set.
+// All keys of the position decoded from the REST response are set.
+func (p *Position) IsSet(key string) bool {
+	return !(*p).partial || (*p).parsed&positionKeys[key] != 0
 }
 
 func (p *Position) Parse(values map[string]string) error {
 	var err error
 
+	(*p).partial = true
 	for k, v := range values {
+		(*p).parsed |= positionKeys[k]
 		switch k {
 		case "Id":
 			var pid64 uint64
@@ -85,5 +102,5 @@ type RespPositions struct {
 	Result           []Position `json:"Result"`
 	NextPageLink     string     `json:"NextPageLink"`
 	PreviousPageLink string     `json:"PreviousPageLink"`
-	TotalCount       uint8      `json:"TotalCount"`
+	TotalCount       uint32     `json:"TotalCount"`
 }
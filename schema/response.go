@@ -1,9 +1,10 @@
 package schema
 
 type EtnaResponse struct {
-	State  string `json:"State,omitempty"`
-	Step   string `json:"Step,omitempty"`
-	Reason string `json:"Reason,omitempty"`
+	State   string `json:"State,omitempty"`
+	Step    string `json:"Step,omitempty"`
+	Reason  string `json:"Reason,omitempty"`
+	Message string `json:"Message,omitempty"`
 }
 
 type FmpResponse struct {
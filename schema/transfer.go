@@ -22,5 +22,5 @@ type RespTransfers struct {
 	Result           []Transfer `json:"Result"`
 	NextPageLink     string     `json:"NextPageLink"`
 	PreviousPageLink string     `json:"PreviousPageLink"`
-	TotalCount       uint16     `json:"TotalCount"`
+	TotalCount       uint32     `json:"TotalCount"`
 }
@@ -0,0 +1,187 @@
+package goetna
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the receive-to-dispatch latency histogram buckets.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// StreamStats are the counters of the WebSocket messages of the topic.
+type StreamStats struct {
+	Topic        string
+	Received     uint64 // the messages read from the socket
+	Decoded      uint64 // the messages processed without an error
+	DecodeErrors uint64
+	Dispatched   uint64 // the messages passed to the handlers or the channel
+	Dropped      uint64 // the messages dropped by the overflow policy
+	QueueLen     int    // the current number of the queued messages
+	QueueCap     int    // the queue capacity, 0 for the unbounded (coalescing) queue
+	HighWater    int    // the maximum number of the queued messages
+	Latency      LatencyHistogram
+}
+
+// LatencyHistogram is the distribution of the time from receiving the message to the end of its dispatching.
+// Counts[i] is the number of the messages with the latency <= Bounds[i], the last item counts the rest.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Mean returns the average latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// SlowConsumer describes the stream, which consumer is blocked longer than the threshold.
+type SlowConsumer struct {
+	Topic    string
+	Blocked  time.Duration // how long the current dispatching or queueing lasts
+	Receiver bool          // the socket reading is blocked since the queue is full
+	QueueLen int
+	QueueCap int
+}
+
+type SlowConsumerHandler func(name string, evt SlowConsumer)
+
+// streamMetrics are the counters of the topic, they're updated lock-free by the receiver and the stream.
+type streamMetrics struct {
+	received, decoded, decodeErrors, dispatchedCnt, dropped atomic.Uint64
+	highWater                                               atomic.Int64
+	lastRecv                                                atomic.Int64 // the receive time of the last message, ns
+	dispatchSince                                           atomic.Int64 // the start of the current dispatching, ns
+	pushBlockedSince                                        atomic.Int64 // the start of the blocked queueing, ns
+	reported                                                atomic.Int64 // the start of the last reported blocking, ns
+	latCounts                                               []atomic.Uint64
+	latCount, latSum                                        atomic.Int64
+}
+
+func newStreamMetrics() *streamMetrics {
+	return &streamMetrics{latCounts: make([]atomic.Uint64, len(LatencyBuckets)+1)}
+}
+
+func (m *streamMetrics) observeQueue(length, _ int) {
+	for hw := (*m).highWater.Load(); int64(length) > hw; hw = (*m).highWater.Load() {
+		if (*m).highWater.CompareAndSwap(hw, int64(length)) {
+			break
+		}
+	}
+}
+
+// dispatched records the end of the message dispatching, `recvTs` is the receive time of the message, ns.
+func (m *streamMetrics) dispatched(recvTs int64) {
+	now := time.Now().UnixNano()
+	(*m).dispatchSince.Store(0)
+	(*m).dispatchedCnt.Add(1)
+
+	lat := time.Duration(now - recvTs)
+	idx := sort.Search(len(LatencyBuckets), func(i int) bool { return lat <= LatencyBuckets[i] })
+	(*m).latCounts[idx].Add(1)
+	(*m).latCount.Add(1)
+	(*m).latSum.Add(int64(lat))
+}
+
+func (m *streamMetrics) snapshot(topic string) StreamStats {
+	st := StreamStats{
+		Topic:        topic,
+		Received:     (*m).received.Load(),
+		Decoded:      (*m).decoded.Load(),
+		DecodeErrors: (*m).decodeErrors.Load(),
+		Dispatched:   (*m).dispatchedCnt.Load(),
+		Dropped:      (*m).dropped.Load(),
+		HighWater:    int((*m).highWater.Load()),
+		Latency: LatencyHistogram{
+			Bounds: LatencyBuckets,
+			Counts: make([]uint64, len((*m).latCounts)),
+			Count:  uint64((*m).latCount.Load()),
+			Sum:    time.Duration((*m).latSum.Load()),
+		},
+	}
+	for i := range (*m).latCounts {
+		st.Latency.Counts[i] = (*m).latCounts[i].Load()
+	}
+	return st
+}
+
+// blocked returns the duration of the current blocking and whether it's the receiver, which is blocked.
+func (m *streamMetrics) blocked(now int64) (int64, time.Duration, bool) {
+	if since := (*m).pushBlockedSince.Load(); since != 0 {
+		return since, time.Duration(now - since), true
+	} else if since = (*m).dispatchSince.Load(); since != 0 {
+		return since, time.Duration(now - since), false
+	}
+	return 0, 0, false
+}
+
+// Stats returns the counters of the received topics sorted by topic.
+func (ws *WSClient) Stats() []StreamStats {
+	(*ws).muStats.Lock()
+	res := make([]StreamStats, 0, len((*ws).metrics))
+	for topic, m := range (*ws).metrics {
+		st := m.snapshot(topic)
+		if s, exist := (*ws).streams[topic]; exist {
+			st.QueueLen, st.QueueCap = s.occupancy()
+		}
+		res = append(res, st)
+	}
+	(*ws).muStats.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Topic < res[j].Topic })
+	return res
+}
+
+// SetSlowConsumerHandler sets the handler called once per blocking when the stream's handlers (or channel reader)
+// are blocked longer than `threshold`. It must be called before Start.
+func (ws *WSClient) SetSlowConsumerHandler(threshold time.Duration, h SlowConsumerHandler) {
+	(*ws).slowThreshold, (*ws).hdlSlow = threshold, h
+}
+
+// topicMetrics returns the counters of the topic, creating them for the topic without the stream.
+func (ws *WSClient) topicMetrics(topic string) *streamMetrics {
+	(*ws).muStats.Lock()
+	defer (*ws).muStats.Unlock()
+	m, exist := (*ws).metrics[topic]
+	if !exist {
+		m = newStreamMetrics()
+		(*ws).metrics[topic] = m
+	}
+	return m
+}
+
+// goSlowConsumers is a goroutine that checks the streams for the blocked consumers.
+func (ws *WSClient) goSlowConsumers() {
+	period := (*ws).slowThreshold / 4
+	if period < 10*time.Millisecond {
+		period = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-(*ws).ctx.Done():
+			return
+		case now := <-ticker.C:
+			for topic, s := range (*ws).streams {
+				m := s.metrics()
+				since, blocked, isReceiver := m.blocked(now.UnixNano())
+				if blocked < (*ws).slowThreshold || m.reported.Swap(since) == since {
+					continue
+				}
+				evt := SlowConsumer{Topic: topic, Blocked: blocked, Receiver: isReceiver}
+				evt.QueueLen, evt.QueueCap = s.occupancy()
+				(*ws).logger.Error("slow consumer: %s %+v", (*ws).name, evt)
+				(*ws).hdlSlow((*ws).name, evt)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package goetna
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestStreamMetricsLatency(t *testing.T) {
+	m := newStreamMetrics()
+	now := time.Now()
+	m.dispatched(now.Add(-20 * time.Millisecond).UnixNano())
+	m.dispatched(now.Add(-time.Minute).UnixNano())
+
+	hist := m.snapshot("Quote").Latency
+	expect := make([]uint64, len(LatencyBuckets)+1)
+	expect[5], expect[len(LatencyBuckets)] = 1, 1 // <= 50ms and the rest
+	for i := range expect {
+		if hist.Counts[i] != expect[i] {
+			(*t).Fatalf("wrong counts: %v", hist.Counts)
+		}
+	}
+	if hist.Count != 2 || hist.Mean() < 30*time.Second || hist.Mean() > 31*time.Second {
+		(*t).Errorf("wrong histogram: %d messages, mean %s", hist.Count, hist.Mean())
+	} else if (LatencyHistogram{}).Mean() != 0 {
+		(*t).Error("wrong mean of the empty histogram")
+	}
+}
+
+func TestEtnaWSStatsHighWater(t *testing.T) {
+	ws := newOfflineEtnaWS(t, true)
+	for i := 0; i < 3; i++ {
+		(*ws).balances.push(context.Background(), sch.TradingBalance{})
+	}
+	<-(*ws).balances.queue
+	<-(*ws).balances.queue
+	(*ws).balances.push(context.Background(), sch.TradingBalance{})
+
+	for _, st := range ws.Stats() {
+		if st.Topic != sch.WSTopicBalance {
+			continue
+		} else if st.HighWater != 3 || st.QueueLen != 2 || st.QueueCap != 20 {
+			(*t).Errorf("wrong stats: %+v", st)
+		}
+		return
+	}
+	(*t).Error("the stats of the balances are absent")
+}
+
+func TestEtnaWSSlowConsumer(t *testing.T) {
+	tests := map[string]struct {
+		pushes   int // the queue of the balances holds 20 messages
+		receiver bool
+	}{
+		"handler":  {pushes: 1},
+		"receiver": {pushes: 22, receiver: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			const threshold = 40 * time.Millisecond
+			ws := newOfflineEtnaWS(t, true)
+			defer ws.Stop()
+			release, events := make(chan struct{}), make(chan SlowConsumer, 10)
+			defer close(release)
+			ws.OnBalance(func(sch.TradingBalance) { <-release })
+			ws.SetSlowConsumerHandler(threshold, func(_ string, evt SlowConsumer) { events <- evt })
+			ws.startStreams()
+			go func() {
+				for i := 0; i < tc.pushes; i++ {
+					(*ws).balances.push((*ws).ctx, sch.TradingBalance{})
+				}
+			}()
+
+			select {
+			case evt := <-events:
+				if evt.Topic != sch.WSTopicBalance || evt.Receiver != tc.receiver || evt.Blocked < threshold {
+					(*t).Errorf("wrong event: %+v", evt)
+				}
+			case <-time.After(time.Second):
+				(*t).Fatal("the slow consumer isn't reported")
+			}
+			// the blocking is reported once
+			select {
+			case evt := <-events:
+				(*t).Errorf("the blocking is reported again: %+v", evt)
+			case <-time.After(3 * threshold):
+			}
+		})
+	}
+}
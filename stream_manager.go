@@ -0,0 +1,161 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// StreamManager creates the EtnaWS clients of the quote and data sessions. It resolves the streamer addresses
+// with GetStreamers and recovers the streamer session with RecoverStreamerSession before connecting,
+// and fails over to the next address with the recovered session when the connection can't be established
+// or the session isn't created (e.g. it has expired) during the reconnection.
+type StreamManager struct {
+	rest    *EtnaREST
+	logger  Logger
+	mu      sync.Mutex
+	clients []*EtnaWS
+}
+
+// NewStreamManager creates the manager using the REST client's credentials, configuration and instrumentation.
+func NewStreamManager(rest *EtnaREST, logger Logger) *StreamManager {
+	return &StreamManager{rest: rest, logger: newRedactLogger(logger)}
+}
+
+// NewQuoteWS creates the client of the quote (market data) session with the configuration of the REST client.
+// The client isn't started, the streamer is resolved by Start.
+func (m *StreamManager) NewQuoteWS(name string, hdlConn ConnHandler, hdlDisconn DisconnHandler) (*EtnaWS, error) {
+	return (*m).newWS(name, sch.WSSessQuote, hdlConn, hdlDisconn)
+}
+
+// NewDataWS creates the client of the data session (orders, positions and balances) with the configuration
+// of the REST client. The client isn't started, the streamer is resolved by Start.
+func (m *StreamManager) NewDataWS(name string, hdlConn ConnHandler, hdlDisconn DisconnHandler) (*EtnaWS, error) {
+	return (*m).newWS(name, sch.WSSessData, hdlConn, hdlDisconn)
+}
+
+// Close closes all clients created by the manager, see WSClient.Close.
+func (m *StreamManager) Close(ctx context.Context, unsubscribe bool) error {
+	(*m).mu.Lock()
+	clients := (*m).clients
+	(*m).clients = nil
+	(*m).mu.Unlock()
+
+	var errs []error
+	for _, ws := range clients {
+		if err := ws.Close(ctx, unsubscribe); err != nil && !errors.Is(err, ErrClosed) {
+			errs = append(errs, fmt.Errorf("%s: %w", (*ws).name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *StreamManager) newWS(name string, sessType sch.WSSessionType, hdlConn ConnHandler,
+	hdlDisconn DisconnHandler) (*EtnaWS, error) {
+	s := managedSession{m: m, sessType: sessType}
+	creds := (*(*m).rest).creds
+	ws, err := NewEtnaWS((*(*m).rest).cfg, name, "", creds.login, creds.passwd, "", "", (*m).logger,
+		func(name string) {
+			s.created.Store(true)
+			if hdlConn != nil {
+				hdlConn(name)
+			}
+		}, hdlDisconn)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetInstrumentation((*(*m).rest).instr)
+	ws.SetConnectFunc(s.connect)
+	s.ws = ws
+
+	(*m).mu.Lock()
+	(*m).clients = append((*m).clients, ws)
+	(*m).mu.Unlock()
+	return ws, nil
+}
+
+// managedSession is the streamer session of the client created by StreamManager.
+type managedSession struct {
+	m        *StreamManager
+	ws       *EtnaWS
+	sessType sch.WSSessionType
+	addrs    []sch.Streamer
+	idx      int
+	userId   int32       // the user of the recovered session, it's requested once
+	dialed   bool        // the connection has been attempted
+	created  atomic.Bool // the session is created on the last connection
+}
+
+// connect is the connect function of the client. The streamer is resolved on the first call, the next address
+// is taken if the previous connection didn't create the session. The addresses are tried in turn until
+// the connection is established.
+func (s *managedSession) connect() error {
+	failed := (*s).dialed && !(*s).created.Load()
+	(*s).dialed = true
+	(*s).created.Store(false)
+
+	if len((*s).addrs) == 0 || failed {
+		if err := s.failover(); err != nil {
+			return err
+		}
+	}
+	err := (*s).ws.connect()
+	for i := 1; err != nil && i < len((*s).addrs) && (*(*s).ws).ctx.Err() == nil; i++ {
+		(*(*s).m).logger.Error("streamer connection fault: %s %v", (*(*s).ws).name, err)
+		if ferr := s.failover(); ferr != nil {
+			return errors.Join(err, ferr)
+		}
+		err = (*s).ws.connect()
+	}
+	return err
+}
+
+// failover refreshes the streamer addresses, moves to the next one and recovers the session.
+// The current streamer is kept if the session or the user isn't received.
+func (s *managedSession) failover() error {
+	ctx, rest := (*(*s).ws).ctx, (*(*s).m).rest
+
+	addrs, idx := (*s).addrs, (*s).idx
+	streamers, err := rest.GetStreamers(ctx, false)
+	if err != nil && len(addrs) == 0 {
+		return err
+	} else if err != nil {
+		(*(*s).m).logger.Error("streamers refreshing fault, the known addresses are used: %v", err)
+	} else {
+		fresh := streamers.QuoteAddresses
+		if (*s).sessType == sch.WSSessData {
+			fresh = streamers.DataAddresses
+		}
+		if len(fresh) == 0 {
+			return fmt.Errorf("no streamers of session type %d", (*s).sessType)
+		}
+		if len(addrs) == 0 {
+			idx = -1
+		}
+		addrs = fresh
+	}
+	idx = (idx + 1) % len(addrs)
+	addr := addrs[idx]
+
+	sessId, err := rest.RecoverStreamerSession(ctx, (*s).sessType)
+	if err != nil {
+		return err
+	}
+	// the recovered session is used with the user id only, see EtnaWS.createUrl
+	if (*s).userId == 0 {
+		user, err := rest.GetUser(ctx)
+		if err != nil {
+			return err
+		}
+		(*s).userId = user.UserId
+	}
+	(*s).addrs, (*s).idx = addrs, idx
+	(*s).ws.setStreamer(strings.TrimSuffix(addr.Url, ":443"), (*s).userId, addr.SessionId, sessId)
+	(*(*s).m).logger.Info("streamer selected: %s %s", (*(*s).ws).name, (*s).ws.streamerUrl())
+	return nil
+}
@@ -0,0 +1,67 @@
+package goetna
+
+import (
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestStreamManagerFailover(t *testing.T) {
+	var userReqs atomic.Int32
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"GET /v1.0/streamers": respond(`{"QuoteAddresses":[{"Url":"wss://q1:443","SessionId":"u1"},` +
+			`{"Url":"wss://q2:443","SessionId":"u2"}],"DataAddresses":[]}`),
+		"PUT /v1.0/streamers/session/recover": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("sessionType") != "1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"Id":"recovered"}`))
+		},
+		"GET /v1.0/users/@me/info": func(w http.ResponseWriter, r *http.Request) {
+			userReqs.Add(1)
+			_, _ = w.Write([]byte(`{"UserId":42,"Login":"login"}`))
+		},
+	})
+	m := NewStreamManager(rest, nil)
+	ws, err := m.NewQuoteWS("test", nil, nil)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	defer (*ws).ctxCancel()
+
+	tests := []struct {
+		addr, user string
+	}{
+		{addr: "wss://q1", user: "42:u1"},
+		{addr: "wss://q2", user: "42:u2"},
+		{addr: "wss://q1", user: "42:u1"},
+	}
+	// the addresses are taken in turn, the user is requested once
+	s := managedSession{m: m, ws: ws, sessType: sch.WSSessQuote}
+	for i, tc := range tests {
+		if err = s.failover(); err != nil {
+			(*t).Fatalf("failover %d: %v", i, err)
+		}
+		uri, err := ws.createUrl()
+		if err != nil {
+			(*t).Fatal(err)
+		}
+		u, err := url.Parse(uri)
+		if err != nil {
+			(*t).Fatal(err)
+		}
+		qry := u.Query()
+		if addr := u.Scheme + "://" + u.Host; addr != tc.addr {
+			(*t).Errorf("failover %d: wrong address: %s", i, addr)
+		} else if qry.Get("User") != tc.user || qry.Get("Password") != "recovered" {
+			(*t).Errorf("failover %d: wrong session: %s", i, u.RawQuery)
+		}
+	}
+	if n := userReqs.Load(); n != 1 {
+		(*t).Errorf("wrong number of the user requests: %d", n)
+	}
+}
@@ -0,0 +1,239 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// Attr is the attribute of the span.
+type Attr struct {
+	Key   string
+	Value any // string, bool, int, int64, uint64, float64 or fmt.Stringer
+}
+
+// Tracer creates the spans of the client operations, see etnaotel.NewTracer for the OpenTelemetry adapter.
+type Tracer interface {
+	// Start starts the client span as the child of the span in ctx and returns the context with the new span.
+	Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span)
+	// Inject puts the trace context of ctx into the header of the outgoing request.
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span is the traced operation.
+type Span interface {
+	SetAttributes(attrs ...Attr)
+	AddEvent(name string, attrs ...Attr)
+	// End finishes the span, the error marks the span as failed.
+	End(err error)
+}
+
+// NopTracer doesn't trace, it's used by default.
+type NopTracer struct{}
+
+func (NopTracer) Start(ctx context.Context, _ string, _ ...Attr) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+func (NopTracer) Inject(context.Context, http.Header) {}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...Attr)    {}
+func (nopSpan) AddEvent(string, ...Attr) {}
+func (nopSpan) End(error)                {}
+
+// SetTracer sets the tracer of the REST requests. Nil disables the tracing.
+func (api *EtnaREST) SetTracer(t Tracer) {
+	if t == nil {
+		t = NopTracer{}
+	}
+	(*api).tracer = t
+}
+
+// SetOrderTracer enables the tracing of the order lifecycle started by PlaceOrder. Nil disables it.
+func (api *EtnaREST) SetOrderTracer(t *OrderTracer) {
+	(*api).orderTracer = t
+}
+
+// startSpan starts the span of the API call.
+func (api *EtnaREST) startSpan(ctx context.Context, method, endpoint string) (context.Context, Span) {
+	template := endpointTemplate(endpoint)
+	return (*api).tracer.Start(ctx, fmt.Sprintf("etna %s %s", method, template),
+		Attr{Key: "http.request.method", Value: method}, Attr{Key: "etna.endpoint", Value: template})
+}
+
+// endSpan finishes the span of the API call with the response status.
+func endSpan(span Span, err error) {
+	var apiErr *APIError
+
+	status := http.StatusOK
+	if errors.As(err, &apiErr) {
+		status = apiErr.StatusCode
+	} else if err != nil {
+		status = 0
+	}
+	if status != 0 {
+		span.SetAttributes(Attr{Key: "http.response.status_code", Value: status})
+	}
+	span.End(err)
+}
+
+// OrderTracer traces the lifecycle of the orders: the span started by PlaceOrder is the parent of the REST request,
+// the later WebSocket updates of the order (matched by Id or ClientId) are recorded as its events,
+// and the span ends when the order reaches the terminal status. The span of the order replaced by ReplaceOrder
+// continues with the replacement order. Feed it with the orders from EtnaWS.OnOrder or OrdersChan via Observe.
+type OrderTracer struct {
+	tracer   Tracer
+	maxAge   time.Duration
+	mu       sync.Mutex
+	byClient map[string]*orderSpan
+	byId     map[uint64]*orderSpan
+}
+
+type orderSpan struct {
+	span     Span
+	clientId string
+	id       uint64
+	started  time.Time
+	executed float64
+}
+
+// DefaultOrderSpanMaxAge is the lifetime of the order span, which is used if the positive one isn't specified.
+const DefaultOrderSpanMaxAge = 24 * time.Hour
+
+// NewOrderTracer creates the order lifecycle tracer. The spans of the orders, which aren't done within `maxAge`
+// (e.g. GTC orders), are ended with the "abandoned" event, the non-positive `maxAge` is DefaultOrderSpanMaxAge.
+// The expired spans are swept when the next order is placed or observed.
+func NewOrderTracer(t Tracer, maxAge time.Duration) *OrderTracer {
+	if t == nil {
+		t = NopTracer{}
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultOrderSpanMaxAge
+	}
+	return &OrderTracer{tracer: t, maxAge: maxAge, byClient: make(map[string]*orderSpan),
+		byId: make(map[uint64]*orderSpan)}
+}
+
+// begin starts the lifecycle span of the order, which is about to be placed. The span of the previous order
+// with the same ClientId is ended.
+func (ot *OrderTracer) begin(ctx context.Context, accId uint32, params *sch.OrderParams) context.Context {
+	ctx, span := (*ot).tracer.Start(ctx, "etna order", Attr{Key: "etna.account_id", Value: int64(accId)},
+		Attr{Key: "etna.order.client_id", Value: params.ClientId}, Attr{Key: "etna.order.symbol", Value: params.Symbol},
+		Attr{Key: "etna.order.side", Value: string(params.Side)}, Attr{Key: "etna.order.type", Value: string(params.Type)},
+		Attr{Key: "etna.order.quantity", Value: params.Quantity})
+	(*ot).mu.Lock()
+	if prev, exist := (*ot).byClient[params.ClientId]; exist {
+		prev.span.AddEvent("superseded")
+		prev.span.End(nil)
+		(*ot).forget(prev)
+	}
+	(*ot).byClient[params.ClientId] = &orderSpan{span: span, clientId: params.ClientId, started: time.Now()}
+	(*ot).expire()
+	(*ot).mu.Unlock()
+	return ctx
+}
+
+// placed records the result of PlaceOrder, the span of the failed order is ended.
+func (ot *OrderTracer) placed(clientId string, order sch.Order, err error) {
+	(*ot).mu.Lock()
+	defer (*ot).mu.Unlock()
+	tr, exist := (*ot).byClient[clientId]
+	if !exist {
+		return
+	} else if err != nil {
+		delete((*ot).byClient, clientId)
+		tr.span.End(err)
+		return
+	}
+	tr.id = order.Id
+	(*ot).byId[order.Id] = tr
+	tr.span.SetAttributes(Attr{Key: "etna.order.id", Value: order.Id})
+	tr.span.AddEvent("submitted", Attr{Key: "etna.order.status", Value: order.Status})
+	(*ot).apply(tr, order)
+}
+
+// Observe records the order update received via WebSocket.
+func (ot *OrderTracer) Observe(order sch.Order) {
+	(*ot).mu.Lock()
+	defer (*ot).mu.Unlock()
+	tr, exist := (*ot).byId[order.Id]
+	if !exist && order.ClientId != "" {
+		if tr, exist = (*ot).byClient[order.ClientId]; exist && tr.id == 0 && order.Id != 0 {
+			tr.id = order.Id
+			(*ot).byId[order.Id] = tr
+		}
+	}
+	if exist {
+		(*ot).apply(tr, order)
+	}
+	(*ot).expire()
+}
+
+// replaced carries the span of the replaced order over to the replacement returned by ReplaceOrder.
+func (ot *OrderTracer) replaced(orderId uint64, order sch.Order) {
+	(*ot).mu.Lock()
+	defer (*ot).mu.Unlock()
+	tr, exist := (*ot).byId[orderId]
+	if !exist {
+		return
+	} else if order.Id != 0 && order.Id != orderId {
+		delete((*ot).byId, orderId)
+		tr.id = order.Id
+		(*ot).byId[order.Id] = tr
+	}
+	tr.span.AddEvent("replaced", Attr{Key: "etna.order.id", Value: order.Id})
+	(*ot).apply(tr, order)
+}
+
+// apply adds the event of the order update and ends the span on the terminal status, the caller must hold the lock.
+func (ot *OrderTracer) apply(tr *orderSpan, order sch.Order) {
+	status, known := sch.ParseOrderStatus(order.Status)
+	switch {
+	case order.ExecutedQuantity > tr.executed:
+		tr.executed = order.ExecutedQuantity
+		tr.span.AddEvent("fill", Attr{Key: "etna.order.status", Value: order.Status},
+			Attr{Key: "etna.order.last_price", Value: order.LastPrice},
+			Attr{Key: "etna.order.last_quantity", Value: order.LastQuantity},
+			Attr{Key: "etna.order.executed_quantity", Value: order.ExecutedQuantity})
+	case known && status == sch.StatusNew:
+		tr.span.AddEvent("ack", Attr{Key: "etna.order.status", Value: order.Status})
+	case order.Status != "":
+		tr.span.AddEvent("status", Attr{Key: "etna.order.status", Value: order.Status})
+	}
+
+	// the span of the replaced order is carried over to the replacement, see replaced
+	if isOrderFinal(order) && !(known && status == sch.StatusReplaced) {
+		var err error
+		if known && status == sch.StatusRejected {
+			err = fmt.Errorf("%w: %s", ErrOrderRejected, order.Description)
+		}
+		tr.span.SetAttributes(Attr{Key: "etna.order.final_status", Value: order.Status},
+			Attr{Key: "etna.order.average_price", Value: order.AveragePrice})
+		tr.span.End(err)
+		(*ot).forget(tr)
+	}
+}
+
+// expire ends the spans older than maxAge, the caller must hold the lock.
+func (ot *OrderTracer) expire() {
+	for _, tr := range (*ot).byClient {
+		if time.Since(tr.started) > (*ot).maxAge {
+			tr.span.AddEvent("abandoned")
+			tr.span.End(nil)
+			(*ot).forget(tr)
+		}
+	}
+}
+
+func (ot *OrderTracer) forget(tr *orderSpan) {
+	delete((*ot).byClient, tr.clientId)
+	if tr.id != 0 {
+		delete((*ot).byId, tr.id)
+	}
+}
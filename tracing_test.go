@@ -0,0 +1,82 @@
+package goetna
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// recordTracer records the events of the spans.
+type recordTracer struct {
+	mu    sync.Mutex
+	spans []*recordSpan
+}
+
+type recordSpan struct {
+	tracer *recordTracer
+	events []string
+	ended  bool
+}
+
+func (rt *recordTracer) Start(ctx context.Context, _ string, _ ...Attr) (context.Context, Span) {
+	(*rt).mu.Lock()
+	defer (*rt).mu.Unlock()
+	span := &recordSpan{tracer: rt}
+	(*rt).spans = append((*rt).spans, span)
+	return ctx, span
+}
+func (rt *recordTracer) Inject(context.Context, http.Header) {}
+
+func (s *recordSpan) SetAttributes(...Attr) {}
+func (s *recordSpan) AddEvent(name string, _ ...Attr) {
+	(*s).tracer.mu.Lock()
+	defer (*s).tracer.mu.Unlock()
+	(*s).events = append((*s).events, name)
+}
+func (s *recordSpan) End(error) {
+	(*s).tracer.mu.Lock()
+	defer (*s).tracer.mu.Unlock()
+	(*s).ended = true
+}
+
+func TestOrderTracerReplace(t *testing.T) {
+	rec := &recordTracer{}
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"POST /v1.0/accounts/1/orders":   respond(`{"Id":10,"ClientId":"c1","Status":"New"}`),
+		"PUT /v1.0/accounts/1/orders/10": respond(`{"Id":11,"ClientId":"c1","Status":"New"}`),
+	})
+	rest.SetOrderTracer(NewOrderTracer(rec, 0))
+	ctx := context.Background()
+	params := &sch.OrderParams{ClientId: "c1", Symbol: "AAPL", Side: sch.SideBuy, Type: sch.OrderLimit,
+		Quantity: 10, Price: 100}
+	if _, err := rest.PlaceOrder(ctx, 1, params); err != nil {
+		(*t).Fatal(err)
+	}
+	(*params).Price = 101
+	if _, err := rest.ReplaceOrder(ctx, 1, 10, params); err != nil {
+		(*t).Fatal(err)
+	}
+
+	// the update of the replaced order doesn't end the span, the replacement continues it
+	tracer := (*rest).orderTracer
+	tracer.Observe(sch.Order{Id: 10, ClientId: "c1", Status: "Replaced"})
+	if span := (*rec).spans[0]; (*span).ended {
+		(*t).Fatal("the span is ended by the replaced order")
+	}
+	tracer.Observe(sch.Order{Id: 11, ClientId: "c1", Status: "Filled", ExecutedQuantity: 10})
+	if len((*rec).spans) != 1 {
+		(*t).Fatalf("wrong number of spans: %d", len((*rec).spans))
+	}
+	span := (*rec).spans[0]
+	if !(*span).ended {
+		(*t).Error("the span isn't ended by the replacement")
+	} else if events := strings.Join((*span).events, ","); events != "submitted,ack,replaced,ack,status,fill" {
+		(*t).Errorf("wrong events: %s", events)
+	} else if len((*tracer).byId) != 0 || len((*tracer).byClient) != 0 {
+		(*t).Error("the span isn't forgotten")
+	}
+}
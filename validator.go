@@ -0,0 +1,273 @@
+package goetna
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// ValidationMode defines what the OrderValidator does with the fixable violations.
+type ValidationMode uint8
+
+const (
+	ValidateReject ValidationMode = iota // reject the order with any violation
+	ValidateRound                        // round the price to the tick size and the quantity to the volume precision
+)
+
+type ViolationCode string
+
+const (
+	ViolationTradeNotAllowed ViolationCode = "TradeNotAllowed" // the security isn't enabled for trading
+	ViolationQuantity        ViolationCode = "Quantity"        // the quantity isn't positive
+	ViolationVolumePrecision ViolationCode = "VolumePrecision" // the quantity has more decimals than allowed
+	ViolationMaxQuantity     ViolationCode = "MaxQuantity"     // the quantity exceeds the user's limit
+	ViolationTickSize        ViolationCode = "TickSize"        // the price isn't a multiple of the tick size
+	ViolationShortNotAllowed ViolationCode = "ShortNotAllowed" // the security can't be sold short
+)
+
+// Violation describes the single reason of the order rejection.
+type Violation struct {
+	Code    ViolationCode
+	Field   string  // the OrderParams field name
+	Value   float64 // the value of the field
+	Allowed float64 // the nearest allowed value or limit, if applicable
+}
+
+func (v Violation) String() string {
+	if v.Value == 0 && v.Allowed == 0 {
+		return fmt.Sprintf("%s: %s", v.Code, v.Field)
+	}
+	return fmt.Sprintf("%s: %s=%g (allowed %g)", v.Code, v.Field, v.Value, v.Allowed)
+}
+
+// ValidationError is returned when the order is rejected locally by the OrderValidator.
+// It matches ErrOrderRejected with errors.Is.
+type ValidationError struct {
+	Symbol     string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	items := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		items[i] = v.String()
+	}
+	return fmt.Sprintf("order validation failed %s: %s", e.Symbol, strings.Join(items, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrOrderRejected
+}
+
+// NewOrderValidator creates the pre-trade validator, which checks the orders against the security
+// and the user's trading settings. The data is requested via `rest` and cached for `ttl`.
+func NewOrderValidator(rest *EtnaREST, mode ValidationMode, ttl time.Duration) *OrderValidator {
+	return &OrderValidator{rest: rest, mode: mode, ttl: ttl, securities: make(map[string]cachedSecurity)}
+}
+
+type OrderValidator struct {
+	rest       *EtnaREST
+	mode       ValidationMode
+	ttl        time.Duration
+	mu         sync.Mutex
+	securities map[string]cachedSecurity
+	settings   *sch.UserTradingSettings
+	settingsTs time.Time
+	secCalls   flightGroup[sch.Security]
+	userCalls  flightGroup[sch.UserTradingSettings]
+}
+
+type cachedSecurity struct {
+	sec sch.Security
+	ts  time.Time
+}
+
+// Validate checks the order parameters. In the ValidateRound mode the price and quantity are adjusted in place.
+// It returns *ValidationError if the order has violations, which can't be fixed.
+func (v *OrderValidator) Validate(ctx context.Context, params *sch.OrderParams) error {
+	var (
+		err        error
+		sec        sch.Security
+		settings   sch.UserTradingSettings
+		violations []Violation
+	)
+	if sec, err = (*v).security(ctx, params.Symbol); err != nil {
+		return fmt.Errorf("validation: %w", err)
+	} else if settings, err = (*v).userSettings(ctx); err != nil {
+		return fmt.Errorf("validation: %w", err)
+	}
+
+	if !sec.Enabled || !sec.AllowTrade {
+		violations = append(violations, Violation{Code: ViolationTradeNotAllowed, Field: "Symbol"})
+	}
+	if params.Side == sch.SideSellShort && !sec.AllowShort {
+		violations = append(violations, Violation{Code: ViolationShortNotAllowed, Field: "Side"})
+	}
+
+	// quantity
+	if allowed := floorTo(params.Quantity, sec.VolumePrecision); allowed != params.Quantity {
+		if (*v).mode == ValidateRound && allowed > 0 {
+			params.Quantity = allowed
+		} else {
+			violations = append(violations, Violation{
+				Code: ViolationVolumePrecision, Field: "Quantity", Value: params.Quantity, Allowed: allowed})
+		}
+	}
+	if params.Quantity <= 0 {
+		violations = append(violations, Violation{Code: ViolationQuantity, Field: "Quantity", Value: params.Quantity})
+	} else if settings.MaxStocksQuantity > 0 && params.Quantity > float64(settings.MaxStocksQuantity) {
+		violations = append(violations, Violation{Code: ViolationMaxQuantity, Field: "Quantity",
+			Value: params.Quantity, Allowed: float64(settings.MaxStocksQuantity)})
+	}
+
+	// prices: the buy limit is rounded down and the sell limit is rounded up, so the order isn't more aggressive
+	if sec.TickSize > 0 {
+		isBuy := params.Side == sch.SideBuy || params.Side == sch.SideBuyToCover
+		if params.Price != 0 {
+			if vl := (*v).checkTick("Price", &params.Price, sec, isBuy); vl != nil {
+				violations = append(violations, *vl)
+			}
+		}
+		// the buy stop is triggered by the rising price, so it's rounded up, and the sell stop is rounded down,
+		// so the stop isn't triggered earlier than requested
+		if params.StopPrice != 0 {
+			if vl := (*v).checkTick("StopPrice", &params.StopPrice, sec, !isBuy); vl != nil {
+				violations = append(violations, *vl)
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Symbol: params.Symbol, Violations: violations}
+	}
+	return nil
+}
+
+// checkTick verifies the price is a multiple of the tick size, rounding it in the ValidateRound mode.
+func (v *OrderValidator) checkTick(field string, price *float64, sec sch.Security, roundDown bool) *Violation {
+	ticks := *price / sec.TickSize
+	if math.Abs(ticks-math.Round(ticks)) < 1e-6 {
+		return nil
+	}
+	if roundDown {
+		ticks = math.Floor(ticks)
+	} else {
+		ticks = math.Ceil(ticks)
+	}
+	allowed := ticks * sec.TickSize
+	if sec.Precision > 0 {
+		allowed = roundTo(allowed, sec.Precision)
+	}
+	if (*v).mode == ValidateRound {
+		*price = allowed
+		return nil
+	}
+	return &Violation{Code: ViolationTickSize, Field: field, Value: *price, Allowed: allowed}
+}
+
+// security returns the cached security, requesting it if absent or expired.
+func (v *OrderValidator) security(ctx context.Context, symbol string) (sch.Security, error) {
+	(*v).mu.Lock()
+	cached, exist := (*v).securities[symbol]
+	(*v).mu.Unlock()
+	if exist && time.Since(cached.ts) < (*v).ttl {
+		return cached.sec, nil
+	}
+
+	return (*v).secCalls.do(ctx, symbol, func() (sch.Security, error) {
+		sec, err := (*v).rest.GetSecurity(ctx, symbol)
+		if err != nil {
+			return sec, err
+		}
+		(*v).mu.Lock()
+		(*v).securities[symbol] = cachedSecurity{sec: sec, ts: time.Now()}
+		(*v).mu.Unlock()
+		return sec, nil
+	})
+}
+
+// userSettings returns the cached user's trading settings, requesting them if absent or expired.
+func (v *OrderValidator) userSettings(ctx context.Context) (sch.UserTradingSettings, error) {
+	(*v).mu.Lock()
+	if (*v).settings != nil && time.Since((*v).settingsTs) < (*v).ttl {
+		defer (*v).mu.Unlock()
+		return *(*v).settings, nil
+	}
+	(*v).mu.Unlock()
+
+	return (*v).userCalls.do(ctx, "", func() (sch.UserTradingSettings, error) {
+		settings, err := (*v).rest.GetUserSettings(ctx)
+		if err != nil {
+			return settings, err
+		}
+		(*v).mu.Lock()
+		(*v).settings, (*v).settingsTs = &settings, time.Now()
+		(*v).mu.Unlock()
+		return settings, nil
+	})
+}
+
+// flightGroup deduplicates the concurrent requests of the same key: the callers wait for the request
+// in flight and share its result, including the error.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// do calls fn unless the call of the key is in flight. The waiting caller returns when its context is done.
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	(*g).mu.Lock()
+	if c, exist := (*g).calls[key]; exist {
+		(*g).mu.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+	if (*g).calls == nil {
+		(*g).calls = make(map[string]*flightCall[T])
+	}
+	c := &flightCall[T]{done: make(chan struct{})}
+	(*g).calls[key] = c
+	(*g).mu.Unlock()
+
+	c.val, c.err = fn()
+	(*g).mu.Lock()
+	delete((*g).calls, key)
+	(*g).mu.Unlock()
+	close(c.done)
+	return c.val, c.err
+}
+
+// Invalidate drops the cached data.
+func (v *OrderValidator) Invalidate() {
+	(*v).mu.Lock()
+	(*v).securities = make(map[string]cachedSecurity)
+	(*v).settings = nil
+	(*v).mu.Unlock()
+}
+
+// floorTo rounds the value down to the number of decimals, tolerating the float representation errors.
+func floorTo(value float64, decimals uint8) float64 {
+	pow := math.Pow10(int(decimals))
+	return math.Floor(value*pow+1e-9) / pow
+}
+
+func roundTo(value float64, decimals uint8) float64 {
+	pow := math.Pow10(int(decimals))
+	return math.Round(value*pow) / pow
+}
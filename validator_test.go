@@ -0,0 +1,147 @@
+package goetna
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// newCachedValidator creates the validator of the cached security, which doesn't request the API.
+func newCachedValidator(mode ValidationMode, sec sch.Security) *OrderValidator {
+	v := NewOrderValidator(nil, mode, time.Hour)
+	(*v).securities[sec.Symbol] = cachedSecurity{sec: sec, ts: time.Now()}
+	(*v).settings, (*v).settingsTs = &sch.UserTradingSettings{MaxStocksQuantity: 1000}, time.Now()
+	return v
+}
+
+func TestOrderValidatorRounding(t *testing.T) {
+	sec := sch.Security{Symbol: "AAPL", TickSize: 0.05, Precision: 2, VolumePrecision: 2, Enabled: true,
+		AllowTrade: true}
+	tests := map[string]struct {
+		mode   ValidationMode
+		params sch.OrderParams
+		expect sch.OrderParams // the params after the validation
+		code   ViolationCode   // the expected violation, if any
+	}{
+		"buy_limit_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.03},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100}},
+		"sell_limit_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideSell, Quantity: 1, Price: 100.03},
+			expect: sch.OrderParams{Side: sch.SideSell, Quantity: 1, Price: 100.05}},
+		"buy_stop_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuyToCover, Quantity: 1, StopPrice: 100.03},
+			expect: sch.OrderParams{Side: sch.SideBuyToCover, Quantity: 1, StopPrice: 100.05}},
+		"sell_stop_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideSell, Quantity: 1, StopPrice: 100.03},
+			expect: sch.OrderParams{Side: sch.SideSell, Quantity: 1, StopPrice: 100}},
+		"on_tick_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.15},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.15}},
+		"quantity_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1.239},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1.23}},
+		"quantity_epsilon_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.29},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.29}},
+		"quantity_below_precision_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.004},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.004}, code: ViolationVolumePrecision},
+		"buy_limit_reject": {mode: ValidateReject,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.03},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.03}, code: ViolationTickSize},
+		"sell_stop_reject": {mode: ValidateReject,
+			params: sch.OrderParams{Side: sch.SideSell, Quantity: 1, StopPrice: 100.03},
+			expect: sch.OrderParams{Side: sch.SideSell, Quantity: 1, StopPrice: 100.03}, code: ViolationTickSize},
+		"on_tick_reject": {mode: ValidateReject,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.15},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1, Price: 100.15}},
+		"quantity_reject": {mode: ValidateReject,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1.239},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1.239}, code: ViolationVolumePrecision},
+		"quantity_epsilon_reject": {mode: ValidateReject,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.29},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 0.29}},
+		"max_quantity_round": {mode: ValidateRound,
+			params: sch.OrderParams{Side: sch.SideBuy, Quantity: 1001},
+			expect: sch.OrderParams{Side: sch.SideBuy, Quantity: 1001}, code: ViolationMaxQuantity},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			params, expect := tc.params, tc.expect
+			params.Symbol, expect.Symbol = sec.Symbol, sec.Symbol
+			err := newCachedValidator(tc.mode, sec).Validate(context.Background(), &params)
+
+			var code ViolationCode
+			if vErr, ok := err.(*ValidationError); ok && len(vErr.Violations) == 1 {
+				code = vErr.Violations[0].Code
+			} else if err != nil {
+				(*t).Fatalf("wrong error: %v", err)
+			}
+			if code != tc.code {
+				(*t).Errorf("wrong violation: %v", err)
+			} else if params != expect {
+				(*t).Errorf("wrong params: %+v", params)
+			}
+		})
+	}
+}
+
+func TestFloorTo(t *testing.T) {
+	tests := map[string]struct {
+		value    float64
+		decimals uint8
+		expect   float64
+	}{
+		"exact":          {value: 1.25, decimals: 2, expect: 1.25},
+		"representation": {value: 0.29, decimals: 2, expect: 0.29},
+		"sum":            {value: 0.1 + 0.2, decimals: 1, expect: 0.3},
+		"below_epsilon":  {value: 0.2999999, decimals: 2, expect: 0.29},
+		"integer":        {value: 2.99, decimals: 0, expect: 2},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if res := floorTo(tc.value, tc.decimals); res != tc.expect {
+				(*t).Errorf("wrong value: %g", res)
+			}
+		})
+	}
+}
+
+func TestOrderValidatorConcurrent(t *testing.T) {
+	var secCalls, userCalls atomic.Int32
+	rest := newStubREST(t, map[string]http.HandlerFunc{
+		"GET /v1.0/equities/AAPL": func(w http.ResponseWriter, r *http.Request) {
+			secCalls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"Symbol":"AAPL","TickSize":0.01,"Precision":2,"Enabled":true,"AllowTrade":true}`))
+		},
+		"GET /v1.0/users/@me/settings/trading": func(w http.ResponseWriter, r *http.Request) {
+			userCalls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		},
+	})
+	v := NewOrderValidator(rest, ValidateReject, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			params := sch.OrderParams{Symbol: "AAPL", Side: sch.SideBuy, Quantity: 1, Price: 100}
+			if err := v.Validate(context.Background(), &params); err != nil {
+				(*t).Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if secCalls.Load() != 1 || userCalls.Load() != 1 {
+		(*t).Errorf("the requests aren't deduplicated: %d securities, %d settings", secCalls.Load(), userCalls.Load())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"math"
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
@@ -15,38 +14,56 @@ import (
 	sch "github.com/long-js/goetna/schema"
 )
 
+// TopicRaw is the name of the stream of the raw messages, see WSClient.OnRaw.
+const TopicRaw = "Raw"
+
 const (
-	ConnectTimeout    = 20 // the amount of seconds to wait for connection and authentication
-	WSReconnInterval  = 12 // base period in seconds for the reconnect period calculation
-	WSMaxSilentPeriod = 30 // maximum period of silence, seconds
+	ConnectTimeout = 20 // the amount of seconds to wait for connection and authentication
 )
 
 type ConnHandler func(name string)
 type DisconnHandler func(code int, text string) error
 type MessageHandler func(topic string, dec *gjson.Decoder) error
 
-func NewWSClient(name string, logger Logger, hdlConn ConnHandler, hdlDisconn DisconnHandler) WSClient {
+// NewWSClient creates the base of the WebSocket clients with the configuration validated by the caller.
+func NewWSClient(cfg *EtnaConfig, name string, logger Logger, hdlConn ConnHandler, hdlDisconn DisconnHandler) WSClient {
 	ctx, ctxCancel := context.WithCancel(context.Background())
+	streamsCtx, streamsCancel := context.WithCancel(context.Background())
+	raw := newStream[RawMessage](TopicRaw, 1000, nil)
 	return WSClient{
 		name:          name,
-		logger:        logger,
+		cfg:           cfg,
+		logger:        newRedactLogger(logger),
 		ctx:           ctx,
 		ctxCancel:     ctxCancel,
+		streamsCtx:    streamsCtx,
+		streamsCancel: streamsCancel,
 		mu:            sync.Mutex{},
 		wg:            sync.WaitGroup{},
 		hdlConnect:    hdlConn,
 		hdlDisconnect: hdlDisconn,
 		reqChan:       make(chan []byte, 100),
+		flushChan:     make(chan chan struct{}),
+		raw:           raw,
+		streams:       map[string]streamRunner{TopicRaw: raw},
+		metrics:       map[string]*streamMetrics{TopicRaw: raw.metrics()},
+		instr:         NopInstrumentation{},
+		reconnPolicy:  DefaultReconnectPolicy(),
+		acks:          newSubAcks(),
 	}
 }
 
 type WSClient struct {
 	name                string
+	cfg                 *EtnaConfig
 	logger              Logger
 	ctx                 context.Context
 	ctxCancel           func()
+	streamsCtx          context.Context // the streams outlive ctx to be drained by Close
+	streamsCancel       func()
 	mu                  sync.Mutex
-	wg                  sync.WaitGroup
+	wg                  sync.WaitGroup // the receiver and the sender, see Close
+	muStart             sync.Mutex     // orders the start of the goroutines and Close
 	conn                *gws.Conn
 	connected, loggedIn atomic.Bool
 	connectFn           func() error
@@ -54,8 +71,37 @@ type WSClient struct {
 	hdlConnect          ConnHandler
 	hdlDisconnect       DisconnHandler
 	hdlMessage          MessageHandler
-	lastMsgTs           atomic.Int64
+	lastMsgTs           atomic.Int64 // the time of the last message or pong, ns
+	lastPongTs          atomic.Int64 // ns
+	lastHeartbeatTs     atomic.Int64 // the time of the last application-level ping or heartbeat, ns
 	reqChan             chan []byte
+	flushChan           chan chan struct{} // the requests to write the queued messages, see Close
+	raw                 *stream[RawMessage]
+	streams             map[string]streamRunner
+	streamsOnce         sync.Once
+	streamsStarted      atomic.Bool
+	muStats             sync.Mutex
+	metrics             map[string]*streamMetrics
+	slowThreshold       time.Duration
+	hdlSlow             SlowConsumerHandler
+	instr               Instrumentation
+	state               atomic.Uint32
+	hdlState            StateHandler
+	reconnPolicy        *ReconnectPolicy
+	connecting          atomic.Bool // Start or reconnect is in progress
+	closing             atomic.Bool // Close is called, the connection isn't restored
+	unsubscribeFn       func() error
+	closeChansFn        func()
+	acks                *subAcks
+}
+
+// streamRunner is the stream of any message type.
+type streamRunner interface {
+	run(ctx context.Context, logger Logger)
+	drain() <-chan struct{}
+	setPolicy(policy OverflowPolicy, size int) error
+	metrics() *streamMetrics
+	occupancy() (int, int)
 }
 
 // IsOperational returns the current connection status of the WebSocket client.
@@ -67,6 +113,48 @@ func (ws *WSClient) IsOperational() bool {
 	return (*ws).connected.Load() && (*ws).loggedIn.Load()
 }
 
+// SetInstrumentation sets the receiver of the client metrics. Nil disables the instrumentation.
+// It must be called before Start.
+func (ws *WSClient) SetInstrumentation(i Instrumentation) {
+	if i == nil {
+		i = NopInstrumentation{}
+	}
+	(*ws).instr = i
+}
+
+// SetOverflowPolicy sets the policy and the queue size of the topic's stream, e.g. sch.WSTopicQuote or TopicRaw.
+// It must be called before Start.
+func (ws *WSClient) SetOverflowPolicy(topic string, policy OverflowPolicy, size int) error {
+	if s, exist := (*ws).streams[topic]; !exist {
+		return fmt.Errorf("unknown stream: %s", topic)
+	} else {
+		return s.setPolicy(policy, size)
+	}
+}
+
+// OnRaw registers the handler of the raw messages, it's called before decoding for every message with the topic.
+func (ws *WSClient) OnRaw(h func(msg RawMessage)) {
+	(*ws).raw.subscribe(h)
+}
+
+func (ws *WSClient) addStream(topic string, s streamRunner) {
+	(*ws).streams[topic] = s
+	(*ws).metrics[topic] = s.metrics()
+}
+
+// startStreams starts the dispatching goroutines of the streams once.
+func (ws *WSClient) startStreams() {
+	(*ws).streamsOnce.Do(func() {
+		(*ws).streamsStarted.Store(true)
+		for _, s := range (*ws).streams {
+			go s.run((*ws).streamsCtx, (*ws).logger)
+		}
+		if (*ws).hdlSlow != nil && (*ws).slowThreshold > 0 {
+			go (*ws).goSlowConsumers()
+		}
+	})
+}
+
 func (ws *WSClient) SetConnectFunc(f func() error) {
 	(*ws).connectFn = f
 }
@@ -83,71 +171,102 @@ func (ws *WSClient) SetMessageHandler(h MessageHandler) {
 func (ws *WSClient) Start() error {
 	if (*ws).connectFn == nil {
 		return fmt.Errorf("connect function is absent")
-	} else if err := (*ws).connectFn(); err != nil {
+	}
+	if !(*ws).connecting.CompareAndSwap(false, true) {
+		return fmt.Errorf("connection is in progress")
+	}
+	defer (*ws).connecting.Store(false)
+
+	(*ws).setState(ConnConnecting)
+	if err := (*ws).start(); err != nil {
+		(*ws).closeConn()
+		(*ws).setState(ConnFailed)
 		return err
 	}
+	return nil
+}
 
+// start connects, starts the receiving and sending goroutines and waits for the login.
+func (ws *WSClient) start() error {
+	if err := (*ws).connectFn(); err != nil {
+		return err
+	}
+	(*ws).lastMsgTs.Store(time.Now().UnixNano())
+	(*ws).setState(ConnConnected)
+	(*ws).instr.ConnStateChanged((*ws).name, true)
+
+	// Close waits for the started goroutines, so none is started once it's called
+	(*ws).muStart.Lock()
+	if (*ws).closing.Load() {
+		(*ws).muStart.Unlock()
+		return ErrClosed
+	}
+	(*ws).startStreams()
+	(*ws).wg.Add(2)
 	go (*ws).goReceiver()
 	go (*ws).goSender()
-	// go (*ws).goPing()
+	(*ws).mu.Lock()
+	go (*ws).goWatchdog((*ws).conn)
+	(*ws).mu.Unlock()
+	(*ws).muStart.Unlock()
 
-	var i int
-	for ; !(*ws).IsOperational() && i < ConnectTimeout; i++ {
-		time.Sleep(1 * time.Second)
-	}
-	if i == ConnectTimeout && !(*ws).IsOperational() {
-		return fmt.Errorf("connection timeout")
+	timeout := time.NewTimer(ConnectTimeout * time.Second)
+	defer timeout.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !(*ws).IsOperational() {
+		if !(*ws).connected.Load() {
+			return fmt.Errorf("connection is lost")
+		}
+		select {
+		case <-(*ws).ctx.Done():
+			return (*ws).ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("connection timeout")
+		case <-ticker.C:
+		}
 	}
 	return nil
 }
 
+// Stop stops the client and interrupts the reconnection. The socket is closed without the close frame,
+// the queued messages are discarded, use Close for the graceful shutdown.
 func (ws *WSClient) Stop() {
 	(*ws).ctxCancel()
-	(*ws).connected.Store(false)
-}
-
-func (ws *WSClient) reconnect() {
-	var err error
-
-	for i := float64(0); i < 3<<8; i++ {
-		period := math.Abs(WSReconnInterval*10*math.Sin(i/(2*WSReconnInterval))+i) + WSReconnInterval
-		time.Sleep(time.Duration(period) * time.Second)
-		(*ws).conn = nil
-		if err = (*ws).Start(); err == nil {
-			break
-		}
-		(*ws).logger.Error("reconnection fault: %+v", err)
-	}
-	if err != nil {
-		(*ws).logger.Error("giving up with reconnection: %+v", err)
-	}
+	(*ws).streamsCancel()
+	(*ws).closeConn()
+	(*ws).acks.close()
+	(*ws).setState(ConnStopped)
 }
 
 func (ws *WSClient) disconnect() error {
 	(*ws).connected.Store(false)
-	return nil
-}
-
-// onPong handles incoming WebSocket pong messages.
-func (ws *WSClient) onPong(data string) error {
-	(*ws).logger.Debug("<-- PONG %s", data)
-	(*ws).lastMsgTs.Store(time.Now().Unix())
+	(*ws).instr.ConnStateChanged((*ws).name, false)
 	return nil
 }
 
 // goReceiver is a goroutine that continuously reads WebSocket messages, extracts the topic,
 // and dispatches them to the onMessage handler. It also handles disconnections, potential panics,
-// and initiates reconnection attempts.
+// and initiates reconnection attempts. The receiver is done after the reconnection, so Close waits for
+// the goroutines started by it.
 func (ws *WSClient) goReceiver() {
+	defer (*ws).wg.Done()
 	defer (*ws).logger.Info("receiver finished: %s", (*ws).name)
 	defer func() {
 		if errMsg := recover(); errMsg != nil {
 			(*ws).logger.Error("receiver got panic: %+v\n%s", errMsg, debug.Stack())
+			(*ws).wg.Add(1)
 			go (*ws).goReceiver()
 		} else {
 			select {
 			case <-(*ws).ctx.Done():
 			default:
+				if (*ws).closing.Load() {
+					return
+				} else if (*ws).connecting.Load() {
+					// the connection is lost during (re)connection, it's handled by Start or reconnect
+					return
+				}
 				if err := (*ws).disconnect(); err != nil {
 					(*ws).logger.Error("receiver disconnect fault: %s %+v", (*ws).name, errMsg)
 				}
@@ -155,8 +274,6 @@ func (ws *WSClient) goReceiver() {
 			}
 		}
 	}()
-	(*ws).wg.Add(1)
-	defer (*ws).wg.Done()
 
 	var (
 		err     error
@@ -165,16 +282,22 @@ func (ws *WSClient) goReceiver() {
 		dec     *gjson.Decoder
 		buf     = make([]byte, 0, 1024)
 	)
+	(*ws).mu.Lock()
+	conn := (*ws).conn
+	(*ws).mu.Unlock()
+	if conn == nil {
+		return
+	}
 	buffer := bytes.NewBuffer(buf)
 	dec = gjson.NewDecoder(buffer)
 	for connected := (*ws).connected.Load(); connected; connected = (*ws).connected.Load() {
-		if _, sockBuf, err = (*(*ws).conn).ReadMessage(); err != nil {
-			(*ws).lastMsgTs.Store(time.Now().Unix())
+		if _, sockBuf, err = conn.ReadMessage(); err != nil {
+			(*ws).lastMsgTs.Store(time.Now().UnixNano())
 			(*ws).connected.Store(false)
 			(*ws).logger.Error("reading message fault: %v", err)
 			continue
 		}
-		(*ws).lastMsgTs.Store(time.Now().Unix())
+		(*ws).lastMsgTs.Store(time.Now().UnixNano())
 
 		if (*ws).topicGetterFn != nil {
 			if topic, err = (*ws).topicGetterFn(sockBuf); err != nil {
@@ -182,6 +305,15 @@ func (ws *WSClient) goReceiver() {
 				continue
 			}
 		}
+		stats := (*ws).topicMetrics(topic)
+		stats.received.Add(1)
+		stats.lastRecv.Store(time.Now().UnixNano())
+		(*ws).instr.MessageReceived((*ws).name, topic)
+		if (*ws).raw.active() {
+			(*ws).raw.metrics().received.Add(1)
+			(*ws).raw.metrics().lastRecv.Store(stats.lastRecv.Load())
+			(*ws).raw.push((*ws).ctx, RawMessage{Topic: topic, Data: sockBuf})
+		}
 		// TODO remove *******************
 		if topic != sch.WSCmdPing && topic != sch.WSTopicQuote {
 			(*ws).logger.Debug("<-- %s", sockBuf)
@@ -191,24 +323,63 @@ func (ws *WSClient) goReceiver() {
 			(*ws).logger.Error("can't write to buffer %+v", err)
 			continue
 		} else if err = (*ws).hdlMessage(topic, dec); err != nil {
+			stats.decodeErrors.Add(1)
 			(*ws).logger.Error("message processing fault: %s, %+v", topic, err)
+		} else {
+			stats.decoded.Add(1)
 		}
 		buffer.Reset()
 	}
 }
 
-// goSender is a goroutine that continuously reads WebSocket messages, extracts the topic,
+// queue passes the request to the sender. It waits while the queue is full, e.g. during the reconnection,
+// until the client is stopped or closed, so the callers holding their locks aren't blocked forever.
+func (ws *WSClient) queue(req []byte) error {
+	select {
+	case (*ws).reqChan <- req:
+		return nil
+	case <-(*ws).ctx.Done():
+		return ErrClosed
+	}
+}
+
+// goSender is a goroutine that writes the queued requests to the connection. It finishes
+// when the connection is replaced, the unsent request is left for the next sender.
 func (ws *WSClient) goSender() {
 	defer (*ws).logger.Info("sender finished")
-	(*ws).wg.Add(1)
 	defer (*ws).wg.Done()
 
 	var (
 		err     error
 		req     []byte
 		done    = (*ws).ctx.Done()
-		cmdPong = "{\"Cmd\":\"Pong"
+		cmdPong = []byte("{\"Cmd\":\"Pong")
 	)
+	(*ws).mu.Lock()
+	conn := (*ws).conn
+	(*ws).mu.Unlock()
+
+	// send writes the request, it returns false if the connection is replaced
+	send := func(req []byte) bool {
+		(*ws).mu.Lock()
+		if conn == nil || (*ws).conn != conn {
+			(*ws).mu.Unlock()
+			select {
+			case (*ws).reqChan <- req:
+			default:
+				(*ws).logger.Error("can't requeue message %s", req)
+			}
+			return false
+		}
+		err = conn.WriteMessage(gws.TextMessage, req)
+		(*ws).mu.Unlock()
+		if err != nil {
+			(*ws).logger.Error("can't send message %s, %+v", req, err)
+		} else if !bytes.HasPrefix(req, cmdPong) {
+			(*ws).logger.Debug("--> %s", req)
+		}
+		return true
+	}
 
 loop:
 	for connected := (*ws).connected.Load(); connected; connected = (*ws).connected.Load() {
@@ -216,16 +387,16 @@ loop:
 		case <-done:
 			break loop
 		case req = <-(*ws).reqChan:
-			(*ws).mu.Lock()
-			err = (*(*ws).conn).WriteMessage(gws.TextMessage, req)
-			(*ws).mu.Unlock()
-			if err != nil {
-				(*ws).logger.Error("can't send message %+v, %+v", req, err)
-				continue
+			if !send(req) {
+				break loop
 			}
-			if string(req[:12]) != cmdPong {
-				(*ws).logger.Debug("--> %s", req)
+		case ack := <-(*ws).flushChan:
+			for len((*ws).reqChan) > 0 {
+				if !send(<-(*ws).reqChan) {
+					break loop
+				}
 			}
+			close(ack)
 		}
 	}
 }
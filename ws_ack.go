@@ -0,0 +1,330 @@
+package goetna
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultAckTimeout is the default time to wait for the acknowledgement of the sent (un)subscription request.
+const DefaultAckTimeout = 10 * time.Second
+
+// ackOp is the kind of the acknowledged request.
+type ackOp uint8
+
+const (
+	ackSub ackOp = iota
+	ackUnsub
+)
+
+func (op ackOp) String() string {
+	if op == ackUnsub {
+		return "unsubscribe"
+	}
+	return "subscribe"
+}
+
+// SubAck is the future of the (un)subscription request. It's resolved when the server acknowledges all keys
+// of the request or when any of them fails with the SubscriptionError.
+type SubAck struct {
+	Topic     string
+	Keys      []string
+	done      chan struct{}
+	remaining int // the number of the unacknowledged keys
+	err       error
+}
+
+// Done returns the channel, which is closed when the request is resolved.
+func (a *SubAck) Done() <-chan struct{} {
+	return (*a).done
+}
+
+// Err returns the error of the resolved request, it's nil while the request is pending.
+func (a *SubAck) Err() error {
+	select {
+	case <-(*a).done:
+		return (*a).err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the request is resolved or ctx is done.
+func (a *SubAck) Wait(ctx context.Context) error {
+	select {
+	case <-(*a).done:
+		return (*a).err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ack resolves one key of the request, the first error resolves the whole request.
+// The caller must hold the subAcks lock.
+func (a *SubAck) ack(err error) {
+	select {
+	case <-(*a).done:
+		return
+	default:
+	}
+	if err != nil {
+		(*a).err = err
+		close((*a).done)
+	} else if (*a).remaining--; (*a).remaining == 0 {
+		close((*a).done)
+	}
+}
+
+type ackKey struct {
+	op         ackOp
+	topic, key string
+}
+
+// ackEntry is the pending request of a key. The timer is armed when the request is sent.
+type ackEntry struct {
+	waiters []*SubAck
+	timer   *time.Timer
+}
+
+// subAcks tracks the pending and the confirmed subscriptions of the client.
+type subAcks struct {
+	mu        sync.Mutex
+	timeout   time.Duration
+	pending   map[ackKey]*ackEntry
+	confirmed map[string]map[string]struct{} // the acknowledged keys by topic
+}
+
+func newSubAcks() *subAcks {
+	return &subAcks{
+		timeout:   DefaultAckTimeout,
+		pending:   map[ackKey]*ackEntry{},
+		confirmed: map[string]map[string]struct{}{},
+	}
+}
+
+// SetAckTimeout sets the time to wait for the acknowledgement of the sent (un)subscription request,
+// the SubAck fails with ErrAckTimeout when it expires.
+func (ws *WSClient) SetAckTimeout(timeout time.Duration) {
+	(*(*ws).acks).mu.Lock()
+	(*(*ws).acks).timeout = timeout
+	(*(*ws).acks).mu.Unlock()
+}
+
+// PendingSubscriptions returns the keys by topic, which subscription isn't acknowledged yet.
+func (ws *WSClient) PendingSubscriptions() map[string][]string {
+	return (*ws).acks.pendingKeys(ackSub)
+}
+
+// ConfirmedSubscriptions returns the keys by topic, which subscription is acknowledged by the server.
+func (ws *WSClient) ConfirmedSubscriptions() map[string][]string {
+	return (*ws).acks.confirmedKeys()
+}
+
+// track returns the future of the keys' request. The subscription of the confirmed key is resolved immediately,
+// the keys with the request in flight share it.
+func (t *subAcks) track(op ackOp, topic string, keys []string) *SubAck {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	a := SubAck{Topic: topic, Keys: keys, done: make(chan struct{})}
+	for _, key := range keys {
+		if op == ackSub && (*t).isConfirmed(topic, key) {
+			continue
+		}
+		e := (*t).entry(ackKey{op: op, topic: topic, key: key})
+		(*e).waiters = append((*e).waiters, &a)
+		a.remaining++
+	}
+	if a.remaining == 0 {
+		close(a.done)
+	}
+	return &a
+}
+
+// sent arms the timeouts of the sent keys, the keys without the futures (e.g. resubscribed) are pending too.
+func (t *subAcks) sent(op ackOp, topic string, keys []string) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	for _, key := range keys {
+		k := ackKey{op: op, topic: topic, key: key}
+		e := (*t).entry(k)
+		if (*e).timer != nil {
+			(*e).timer.Stop()
+		}
+		(*e).timer = time.AfterFunc((*t).timeout, func() { t.expire(k, e) })
+	}
+}
+
+// confirm resolves the key's request acknowledged by the server.
+func (t *subAcks) confirm(op ackOp, topic, key string) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	if op == ackSub {
+		if _, exist := (*t).confirmed[topic]; !exist {
+			(*t).confirmed[topic] = map[string]struct{}{}
+		}
+		(*t).confirmed[topic][key] = struct{}{}
+	} else if keys, exist := (*t).confirmed[topic]; exist {
+		if delete(keys, key); len(keys) == 0 {
+			delete((*t).confirmed, topic)
+		}
+	}
+	(*t).resolve(ackKey{op: op, topic: topic, key: key}, nil)
+}
+
+// reject fails the key's request with ErrSubscriptionRejected. The empty key is the rejection, which doesn't
+// name the key, it fails all sent requests of the topic, since the rejected one can't be told apart.
+func (t *subAcks) reject(op ackOp, topic, key string, status int, message string) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	keys := []string{key}
+	if key == "" {
+		keys = keys[:0]
+		for k, e := range (*t).pending {
+			if k.op == op && k.topic == topic && (*e).timer != nil {
+				keys = append(keys, k.key)
+			}
+		}
+	}
+	for _, key := range keys {
+		(*t).resolve(ackKey{op: op, topic: topic, key: key}, &SubscriptionError{Op: op.String(), Topic: topic,
+			Key: key, Status: status, Message: message, Err: ErrSubscriptionRejected})
+	}
+}
+
+// abort fails the requests of the keys, which can't be sent or aren't needed anymore.
+func (t *subAcks) abort(op ackOp, topic string, keys []string, err error) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	for _, key := range keys {
+		(*t).resolve(ackKey{op: op, topic: topic, key: key},
+			&SubscriptionError{Op: op.String(), Topic: topic, Key: key, Err: err})
+	}
+}
+
+// reset starts the tracking of the new session: the confirmed keys are pending until they're resubscribed,
+// the pending unsubscriptions are complete, since the old session is gone, and the pending subscriptions
+// of the released keys (`live` returns false) are canceled.
+func (t *subAcks) reset(live func(topic, key string) bool) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	clear((*t).confirmed)
+	for k, e := range (*t).pending {
+		if (*e).timer != nil {
+			(*e).timer.Stop()
+			(*e).timer = nil
+		}
+		if k.op == ackUnsub {
+			(*t).resolve(k, nil)
+		} else if !live(k.topic, k.key) {
+			(*t).resolve(k, &SubscriptionError{Op: k.op.String(), Topic: k.topic, Key: k.key,
+				Err: ErrSubscriptionCanceled})
+		}
+	}
+}
+
+// close fails all pending requests with ErrClosed.
+func (t *subAcks) close() {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	for k := range (*t).pending {
+		(*t).resolve(k, &SubscriptionError{Op: k.op.String(), Topic: k.topic, Key: k.key, Err: ErrClosed})
+	}
+	clear((*t).confirmed)
+}
+
+// subscribed reports whether the key is subscribed or the subscription is requested.
+func (t *subAcks) subscribed(topic, key string) bool {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+	_, pending := (*t).pending[ackKey{op: ackSub, topic: topic, key: key}]
+	return pending || (*t).isConfirmed(topic, key)
+}
+
+// unacked reports whether the key isn't confirmed and its subscription request isn't in flight.
+func (t *subAcks) unacked(topic, key string) bool {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+	e, pending := (*t).pending[ackKey{op: ackSub, topic: topic, key: key}]
+	return !(*t).isConfirmed(topic, key) && (!pending || (*e).timer == nil)
+}
+
+func (t *subAcks) pendingKeys(op ackOp) map[string][]string {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	res := map[string][]string{}
+	for k := range (*t).pending {
+		if k.op == op {
+			res[k.topic] = append(res[k.topic], k.key)
+		}
+	}
+	for _, keys := range res {
+		sort.Strings(keys)
+	}
+	return res
+}
+
+func (t *subAcks) confirmedKeys() map[string][]string {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	res := make(map[string][]string, len((*t).confirmed))
+	for topic, keys := range (*t).confirmed {
+		res[topic] = make([]string, 0, len(keys))
+		for key := range keys {
+			res[topic] = append(res[topic], key)
+		}
+		sort.Strings(res[topic])
+	}
+	return res
+}
+
+// isConfirmed reports whether the key is confirmed and isn't being unsubscribed.
+// The caller must hold the lock.
+func (t *subAcks) isConfirmed(topic, key string) bool {
+	_, confirmed := (*t).confirmed[topic][key]
+	_, releasing := (*t).pending[ackKey{op: ackUnsub, topic: topic, key: key}]
+	return confirmed && !releasing
+}
+
+// entry returns the pending request of the key, it's created if absent. The caller must hold the lock.
+func (t *subAcks) entry(k ackKey) *ackEntry {
+	e, exist := (*t).pending[k]
+	if !exist {
+		e = &ackEntry{}
+		(*t).pending[k] = e
+	}
+	return e
+}
+
+// expire fails the request, which isn't acknowledged in time.
+func (t *subAcks) expire(k ackKey, e *ackEntry) {
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+	if (*t).pending[k] == e {
+		(*t).resolve(k, &SubscriptionError{Op: k.op.String(), Topic: k.topic, Key: k.key, Err: ErrAckTimeout})
+	}
+}
+
+// resolve removes the pending request and resolves its futures. The caller must hold the lock.
+func (t *subAcks) resolve(k ackKey, err error) {
+	e, exist := (*t).pending[k]
+	if !exist {
+		return
+	}
+	delete((*t).pending, k)
+	if (*e).timer != nil {
+		(*e).timer.Stop()
+	}
+	for _, a := range (*e).waiters {
+		a.ack(err)
+	}
+}
@@ -0,0 +1,261 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+// ackResult returns the error of the resolved future or "pending".
+func ackResult(a *SubAck) string {
+	select {
+	case <-a.Done():
+		if err := a.Err(); err != nil {
+			return err.Error()
+		}
+		return "ok"
+	default:
+		return "pending"
+	}
+}
+
+func TestSubAcks(t *testing.T) {
+	const topic = sch.WSTopicQuote
+	tests := map[string]struct {
+		run    func(acks *subAcks) *SubAck
+		expect string // the prefix of the future's result
+	}{
+		"confirmed": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1", "2"})
+				acks.sent(ackSub, topic, []string{"1", "2"})
+				acks.confirm(ackSub, topic, "1")
+				acks.confirm(ackSub, topic, "2")
+				return a
+			},
+			expect: "ok"},
+		"partially_confirmed": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1", "2"})
+				acks.sent(ackSub, topic, []string{"1", "2"})
+				acks.confirm(ackSub, topic, "1")
+				return a
+			},
+			expect: "pending"},
+		"already_confirmed": {
+			run: func(acks *subAcks) *SubAck {
+				acks.confirm(ackSub, topic, "1")
+				return acks.track(ackSub, topic, []string{"1"})
+			},
+			expect: "ok"},
+		"not_sent": {
+			run: func(acks *subAcks) *SubAck {
+				return acks.track(ackSub, topic, []string{"1"})
+			},
+			expect: "pending"},
+		"rejected": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1", "2"})
+				acks.sent(ackSub, topic, []string{"1", "2"})
+				acks.reject(ackSub, topic, "2", 403, "Forbidden")
+				return a
+			},
+			expect: "subscribe Quote 2: " + ErrSubscriptionRejected.Error() + ", 403 Forbidden"},
+		"aborted": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackUnsub, topic, []string{"1"})
+				acks.abort(ackUnsub, topic, []string{"1"}, errors.New("queue is full"))
+				return a
+			},
+			expect: "unsubscribe Quote 1: queue is full"},
+		"reset_unsubscribed": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackUnsub, topic, []string{"1"})
+				acks.sent(ackUnsub, topic, []string{"1"})
+				acks.reset(func(string, string) bool { return false })
+				return a
+			},
+			expect: "ok"},
+		"reset_live": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1"})
+				acks.sent(ackSub, topic, []string{"1"})
+				acks.reset(func(string, string) bool { return true })
+				return a
+			},
+			expect: "pending"},
+		"reset_released": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1"})
+				acks.reset(func(string, string) bool { return false })
+				return a
+			},
+			expect: "subscribe Quote 1: " + ErrSubscriptionCanceled.Error()},
+		"closed": {
+			run: func(acks *subAcks) *SubAck {
+				a := acks.track(ackSub, topic, []string{"1"})
+				acks.close()
+				return a
+			},
+			expect: "subscribe Quote 1: " + ErrClosed.Error()},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			acks := newSubAcks()
+			if res := ackResult(tc.run(acks)); !strings.HasPrefix(res, tc.expect) {
+				(*t).Errorf("wrong result: %s", res)
+			}
+		})
+	}
+}
+
+func TestSubAcksTimeout(t *testing.T) {
+	acks := newSubAcks()
+	(*acks).timeout = 20 * time.Millisecond
+	postponed := acks.track(ackSub, "Quote", []string{"1"})
+	sent := acks.track(ackSub, "Quote", []string{"2"})
+	acks.sent(ackSub, "Quote", []string{"2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var subErr *SubscriptionError
+	if err := sent.Wait(ctx); !errors.Is(err, ErrAckTimeout) || !errors.As(err, &subErr) || (*subErr).Key != "2" {
+		(*t).Errorf("wrong error: %v", err)
+	} else if res := ackResult(postponed); res != "pending" {
+		(*t).Errorf("the request, which isn't sent, is resolved: %s", res)
+	} else if !acks.unacked("Quote", "2") {
+		(*t).Error("the expired key isn't requested again")
+	}
+}
+
+func TestSubAcksUnnamedReject(t *testing.T) {
+	acks := newSubAcks()
+	first := acks.track(ackSub, "Quote", []string{"aapl"})
+	second := acks.track(ackSub, "Quote", []string{"tsla"})
+	postponed := acks.track(ackSub, "Quote", []string{"msft"})
+	unsub := acks.track(ackUnsub, "Quote", []string{"nvda"})
+	acks.sent(ackSub, "Quote", []string{"aapl"})
+	acks.sent(ackSub, "Quote", []string{"tsla"})
+	acks.sent(ackUnsub, "Quote", []string{"nvda"})
+
+	acks.reject(ackSub, "Quote", "", 401, "Not allowed")
+	for _, a := range []*SubAck{first, second} {
+		if err := a.Err(); !errors.Is(err, ErrSubscriptionRejected) {
+			(*t).Errorf("the sent request isn't rejected: %v", err)
+		}
+	}
+	if res := ackResult(postponed); res != "pending" {
+		(*t).Errorf("the request, which isn't sent, is rejected: %s", res)
+	} else if res = ackResult(unsub); res != "pending" {
+		(*t).Errorf("the request of the other operation is rejected: %s", res)
+	}
+}
+
+func TestSubAcksSubscribed(t *testing.T) {
+	acks := newSubAcks()
+	acks.track(ackSub, "Quote", []string{"1"})
+	acks.confirm(ackSub, "Quote", "2")
+	acks.track(ackUnsub, "Quote", []string{"2"})
+	if !acks.subscribed("Quote", "1") || acks.subscribed("Quote", "2") || acks.subscribed("Quote", "3") {
+		(*t).Error("wrong subscribed keys")
+	}
+	acks.confirm(ackUnsub, "Quote", "2")
+	if confirmed := acks.confirmedKeys(); len(confirmed) != 0 {
+		(*t).Errorf("wrong confirmed keys: %v", confirmed)
+	} else if pending := acks.pendingKeys(ackSub); strings.Join(pending["Quote"], ",") != "1" {
+		(*t).Errorf("wrong pending keys: %v", pending)
+	}
+}
+
+func TestFmpWSNotConnected(t *testing.T) {
+	ws := newOfflineFmpWS(t)
+	if _, err := ws.SubscribeAck("aapl"); err == nil {
+		(*t).Error("subscribed without the connection")
+	} else if _, err = ws.UnsubscribeAck("aapl"); err == nil {
+		(*t).Error("unsubscribed without the connection")
+	}
+}
+
+// fmpEvent passes the event message to the client.
+func fmpEvent(t *testing.T, ws *FmpWS, msg string) {
+	if err := ws.onMessage(sch.WSTopicEvent, gjson.NewDecoder(strings.NewReader(msg))); err != nil {
+		(*t).Fatal(err)
+	}
+}
+
+// fmpRequests returns the "event ticker" of the queued requests.
+func fmpRequests(t *testing.T, ws *FmpWS) []string {
+	var res []string
+	for len((*ws).reqChan) > 0 {
+		var req sch.FmpReq
+		if err := gjson.Unmarshal(<-(*ws).reqChan, &req); err != nil {
+			(*t).Fatal(err)
+		}
+		res = append(res, req.Event+" "+req.Data["ticker"])
+	}
+	return res
+}
+
+// newOfflineFmpWS creates the client without the connection, the sent requests are left in reqChan.
+func newOfflineFmpWS(t *testing.T) *FmpWS {
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	ws, err := NewFmpWS(cfg, "test", "", NopLogger{}, nil, nil)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	return ws
+}
+
+func TestFmpWSResubscribe(t *testing.T) {
+	ws := newOfflineFmpWS(t)
+	confirmed, _ := ws.request(ackSub, "aapl")
+	pending, _ := ws.request(ackSub, "tsla")
+	fmpEvent(t, ws, `{"event":"subscribe","status":200,"message":"Subscribed to aapl"}`)
+	fmpRequests(t, ws)
+
+	// the new connection is logged in
+	ws.resetSubscriptions()
+	if subs := ws.ConfirmedSubscriptions(); len(subs) != 0 {
+		(*t).Fatalf("the subscriptions of the previous connection are confirmed: %v", subs)
+	}
+	fmpEvent(t, ws, `{"event":"login","status":200,"message":"Authenticated"}`)
+	if reqs := fmpRequests(t, ws); strings.Join(reqs, ";") != "subscribe aapl;subscribe tsla" {
+		(*t).Errorf("wrong requests: %v", reqs)
+	} else if subs := ws.PendingSubscriptions()[sch.WSTopicQuote]; strings.Join(subs, ",") != "aapl,tsla" {
+		(*t).Errorf("wrong pending subscriptions: %v", subs)
+	}
+	fmpEvent(t, ws, `{"event":"subscribe","status":200,"message":"Subscribed to tsla"}`)
+	if res := ackResult(confirmed); res != "ok" {
+		(*t).Errorf("wrong result of the confirmed subscription: %s", res)
+	} else if res = ackResult(pending); res != "ok" {
+		(*t).Errorf("wrong result of the resubscribed request: %s", res)
+	}
+}
+
+func TestFmpWSUnnamedReject(t *testing.T) {
+	ws := newOfflineFmpWS(t)
+	first, _ := ws.request(ackSub, "aapl")
+	second, _ := ws.request(ackSub, "tsla")
+	unsub, _ := ws.request(ackUnsub, "msft")
+
+	// the rejection fails all sent subscriptions, the keys may be requested again
+	fmpEvent(t, ws, `{"event":"subscribe","status":401,"message":"Not allowed"}`)
+	for _, a := range []*SubAck{first, second} {
+		if err := a.Err(); !errors.Is(err, ErrSubscriptionRejected) {
+			(*t).Errorf("the sent request isn't rejected: %v", err)
+		}
+	}
+	if res := ackResult(unsub); res != "pending" {
+		(*t).Errorf("the request of the other operation is rejected: %s", res)
+	} else if (*ws).acks.subscribed(sch.WSTopicQuote, "aapl") || (*ws).acks.subscribed(sch.WSTopicQuote, "tsla") {
+		(*t).Error("the rejected keys are subscribed")
+	}
+}
@@ -0,0 +1,119 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	gws "github.com/gorilla/websocket"
+)
+
+// ErrClosed is returned by Close of the already closed client.
+var ErrClosed = errors.New("client is closed")
+
+// closeFrameTimeout limits the writing of the close frame if the context has no deadline.
+const closeFrameTimeout = time.Second
+
+// Close shuts the client down gracefully: it unsubscribes from all topics if `unsubscribe` is set,
+// sends the close frame and waits for the server to close the connection, lets the streams deliver
+// the queued messages and closes the output channels, so the consumers ranging over them finish.
+// The waiting is limited by ctx, the connection is closed forcibly when it expires. The output channels
+// are left open if the handlers of a stream don't return before the deadline. The unacknowledged
+// subscription requests fail with ErrClosed.
+func (ws *WSClient) Close(ctx context.Context, unsubscribe bool) error {
+	(*ws).muStart.Lock()
+	closing := (*ws).closing.CompareAndSwap(false, true)
+	(*ws).muStart.Unlock()
+	if !closing {
+		return ErrClosed
+	}
+	defer (*ws).setState(ConnStopped)
+
+	var errs []error
+	if unsubscribe && (*ws).unsubscribeFn != nil && (*ws).IsOperational() {
+		if err := (*ws).unsubscribeFn(); err != nil {
+			errs = append(errs, fmt.Errorf("unsubscription fault: %w", err))
+		}
+		if err := (*ws).waitRequestsSent(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("unsubscription isn't sent: %w", err))
+		}
+	}
+
+	(*ws).mu.Lock()
+	conn := (*ws).conn
+	if conn != nil {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			deadline = time.Now().Add(closeFrameTimeout)
+		}
+		err := conn.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(gws.CloseNormalClosure, ""), deadline)
+		if err != nil && !errors.Is(err, gws.ErrCloseSent) {
+			errs = append(errs, fmt.Errorf("close frame fault: %w", err))
+		}
+	}
+	(*ws).mu.Unlock()
+
+	// the sender and the reconnection finish on ctx, the receiver finishes when the server closes the connection
+	(*ws).ctxCancel()
+	ioDone := make(chan struct{})
+	go func() {
+		(*ws).wg.Wait()
+		close(ioDone)
+	}()
+	select {
+	case <-ioDone:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("connection isn't closed by server: %w", ctx.Err()))
+	}
+	(*ws).closeConn()
+	(*ws).instr.ConnStateChanged((*ws).name, false)
+	<-ioDone
+	(*ws).acks.close()
+
+	if err := (*ws).drainStreams(ctx); err != nil {
+		errs = append(errs, err)
+	} else if (*ws).closeChansFn != nil {
+		(*ws).closeChansFn()
+	}
+	return errors.Join(errs...)
+}
+
+// waitRequestsSent waits until the sender writes all queued requests.
+func (ws *WSClient) waitRequestsSent(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case (*ws).flushChan <- ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainStreams delivers the queued messages of the streams and stops them.
+// It returns an error if any stream is still dispatching when ctx expires.
+func (ws *WSClient) drainStreams(ctx context.Context) error {
+	defer (*ws).streamsCancel()
+	if !(*ws).streamsStarted.Load() {
+		return nil
+	}
+	for topic, s := range (*ws).streams {
+		select {
+		case <-s.drain():
+		case <-ctx.Done():
+			// the channel adapters return on the cancellation, the handlers may not
+			(*ws).streamsCancel()
+			select {
+			case <-s.drain():
+			case <-time.After(closeFrameTimeout):
+				return fmt.Errorf("stream %s isn't finished: %w", topic, ctx.Err())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package goetna
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestEtnaWSCloseBlockedChannel(t *testing.T) {
+	ws := newOfflineEtnaWS(t, false)
+	ack, err := ws.SubscribeAck(sch.WSTopicQuote, "1")
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	ws.startStreams()
+	// the channel is full and the stream is blocked on it, the rest is queued
+	for i := 0; i < cap((*ws).BalanceChan)+5; i++ {
+		(*ws).balances.push((*ws).ctx, sch.TradingBalance{})
+	}
+	for len((*ws).BalanceChan) < cap((*ws).BalanceChan) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = ws.Close(ctx, true); err != nil {
+		(*t).Fatal(err)
+	}
+	received := 0
+	for range (*ws).BalanceChan {
+		received++
+	}
+	if received != cap((*ws).BalanceChan) {
+		(*t).Errorf("wrong number of the delivered messages: %d", received)
+	} else if !errors.Is(ack.Err(), ErrClosed) {
+		(*t).Errorf("the pending subscription isn't failed: %v", ack.Err())
+	} else if ws.State() != ConnStopped {
+		(*t).Errorf("wrong state: %s", ws.State())
+	} else if err = ws.Close(context.Background(), false); !errors.Is(err, ErrClosed) {
+		(*t).Errorf("the client is closed twice: %v", err)
+	}
+}
+
+func TestEtnaWSCloseBlockedHandler(t *testing.T) {
+	ws := newOfflineEtnaWS(t, false)
+	release := make(chan struct{})
+	defer close(release)
+	ws.OnBalance(func(sch.TradingBalance) { <-release })
+	ws.startStreams()
+	(*ws).balances.push((*ws).ctx, sch.TradingBalance{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := ws.Close(ctx, false)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), sch.WSTopicBalance) {
+		(*t).Errorf("wrong error: %v", err)
+	}
+	// the channels are left open, since the handler may still use the client
+	select {
+	case _, ok := <-(*ws).OrdersChan:
+		if !ok {
+			(*t).Error("the channel is closed while the handler is running")
+		}
+	default:
+	}
+}
+
+func TestEtnaWSCloseUnsubscribe(t *testing.T) {
+	ws := newOfflineEtnaWS(t, true)
+	if err := ws.SubscribeQuotes("1"); err != nil {
+		(*t).Fatal(err)
+	}
+	sentRequests(t, ws)
+
+	// the unsubscription is queued before the connection is closed, there is no sender to write it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := ws.Close(ctx, true); !errors.Is(err, context.DeadlineExceeded) {
+		(*t).Errorf("wrong error: %v", err)
+	}
+	if sent := sentRequests(t, ws); strings.Join(sent, ";") != "Unsubscribe.txt 1" {
+		(*t).Errorf("wrong requests: %v", sent)
+	} else if _, ok := <-(*ws).QuotesChan; ok {
+		(*t).Error("the channel isn't closed")
+	}
+}
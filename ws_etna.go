@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,19 +17,22 @@ import (
 	sch "github.com/long-js/goetna/schema"
 )
 
-type ConnState uint32
-
-// NewEtnaWS creates the instance of EtnaWS, connects it to th e `baseUrl` and starts receive and ping goroutines.
-func NewEtnaWS(name, url string, login, passwd []byte, userSessId, streamSessId sch.SessionId,
-	logger Logger, hdlConn ConnHandler, hdlDisconn DisconnHandler) *EtnaWS {
+// NewEtnaWS creates the instance of EtnaWS of the streamer `url` with the timeouts of the configuration,
+// the connection is established by Start.
+func NewEtnaWS(cfg *EtnaConfig, name, url string, login, passwd []byte, userSessId, streamSessId sch.SessionId,
+	logger Logger, hdlConn ConnHandler, hdlDisconn DisconnHandler) (*EtnaWS, error) {
+	if err := checkConfig(cfg); err != nil {
+		return nil, err
+	}
 	ws := EtnaWS{
-		WSClient:      NewWSClient(name, logger, hdlConn, hdlDisconn),
+		WSClient:      NewWSClient(cfg, name, logger, hdlConn, hdlDisconn),
 		url:           url,
 		login:         login,
 		passwd:        passwd,
 		userSessId:    userSessId,
 		streamSessId:  streamSessId,
-		subsciptions:  map[string][]sch.EtnaSubReq{},
+		subsciptions:  map[string]subRefs{},
+		subBatch:      DefaultSubscriptionBatch,
 		muSub:         sync.Mutex{},
 		QuotesChan:    make(chan sch.EtnaQuote, 1000),
 		BarsChan:      make(chan sch.Bar, 100),
@@ -37,10 +40,30 @@ func NewEtnaWS(name, url string, login, passwd []byte, userSessId, streamSessId
 		PositionsChan: make(chan sch.Position, 20),
 		OrdersChan:    make(chan sch.Order, 100),
 	}
+	ws.quotes = newStream(sch.WSTopicQuote, 1000, func(u Update[sch.EtnaQuote]) string { return u.Value.SymbolId })
+	ws.quotes.channel = updateChanAdapter(&ws.QuotesChan, &ws.QuoteUpdatesChan)
+	ws.orders = newStream(sch.WSTopicOrder, 100, func(u Update[sch.Order]) string {
+		return strconv.FormatUint(u.Value.Id, 10)
+	})
+	ws.orders.channel = updateChanAdapter(&ws.OrdersChan, &ws.OrderUpdatesChan)
+	ws.positions = newStream(sch.WSTopicPosition, 20, func(u Update[sch.Position]) string {
+		return strconv.FormatUint(uint64(u.Value.Id), 10)
+	})
+	ws.positions.channel = updateChanAdapter(&ws.PositionsChan, &ws.PositionUpdatesChan)
+	ws.balances = newStream(sch.WSTopicBalance, 20, func(b sch.TradingBalance) string { return b.AccountId })
+	ws.balances.channel = chanAdapter(ws.BalanceChan)
+	ws.bars = newStream(sch.WSTopicCandle, 100, func(b sch.Bar) string { return b.Key })
+	ws.bars.channel = chanAdapter(ws.BarsChan)
+	for topic, s := range map[string]streamRunner{sch.WSTopicQuote: ws.quotes, sch.WSTopicOrder: ws.orders,
+		sch.WSTopicPosition: ws.positions, sch.WSTopicBalance: ws.balances, sch.WSTopicCandle: ws.bars} {
+		ws.addStream(topic, s)
+	}
 	ws.SetConnectFunc(ws.connect)
 	ws.SetTopicFunc(getEtnaTopic)
 	ws.SetMessageHandler(ws.onMessage)
-	return &ws
+	ws.unsubscribeFn = ws.unsubscribeAll
+	ws.closeChansFn = ws.closeChans
+	return &ws, nil
 }
 
 type EtnaWS struct {
@@ -48,15 +71,90 @@ type EtnaWS struct {
 	url                      string
 	streamSessId, userSessId sch.SessionId
 	userId                   int32
+	muSess                   sync.Mutex // guards url, the session ids and userId
 	login, passwd            []byte
-	subsciptions             map[string][]sch.EtnaSubReq
+	subsciptions             map[string]subRefs // the subscribed keys by topic
+	subBatch                 int
 	muSub                    sync.Mutex
+	state                    *etnaState
+	quotes                   *stream[Update[sch.EtnaQuote]]
+	orders                   *stream[Update[sch.Order]]
+	positions                *stream[Update[sch.Position]]
+	balances                 *stream[sch.TradingBalance]
+	bars                     *stream[sch.Bar]
 
 	QuotesChan    chan sch.EtnaQuote
 	BarsChan      chan sch.Bar
 	BalanceChan   chan sch.TradingBalance
 	PositionsChan chan sch.Position
 	OrdersChan    chan sch.Order
+
+	// the merged updates, they're created by EnableStateMerge
+	QuoteUpdatesChan    chan Update[sch.EtnaQuote]
+	OrderUpdatesChan    chan Update[sch.Order]
+	PositionUpdatesChan chan Update[sch.Position]
+}
+
+// OnQuote registers the quote handler. The handlers are called sequentially in the stream's goroutine,
+// registering a handler stops the delivery to QuotesChan (and QuoteUpdatesChan).
+func (ws *EtnaWS) OnQuote(h func(quote sch.EtnaQuote)) {
+	(*ws).quotes.subscribe(func(u Update[sch.EtnaQuote]) { h(u.Value) })
+}
+
+// OnQuoteUpdate registers the handler of the quotes with the changed fields, see EnableStateMerge.
+func (ws *EtnaWS) OnQuoteUpdate(h func(upd Update[sch.EtnaQuote])) {
+	(*ws).quotes.subscribe(h)
+}
+
+// OnOrder registers the order handler, registering a handler stops the delivery to OrdersChan.
+func (ws *EtnaWS) OnOrder(h func(order sch.Order)) {
+	(*ws).orders.subscribe(func(u Update[sch.Order]) { h(u.Value) })
+}
+
+// OnOrderUpdate registers the handler of the orders with the changed fields, see EnableStateMerge.
+func (ws *EtnaWS) OnOrderUpdate(h func(upd Update[sch.Order])) {
+	(*ws).orders.subscribe(h)
+}
+
+// OnPosition registers the position handler, registering a handler stops the delivery to PositionsChan.
+func (ws *EtnaWS) OnPosition(h func(position sch.Position)) {
+	(*ws).positions.subscribe(func(u Update[sch.Position]) { h(u.Value) })
+}
+
+// OnPositionUpdate registers the handler of the positions with the changed fields, see EnableStateMerge.
+func (ws *EtnaWS) OnPositionUpdate(h func(upd Update[sch.Position])) {
+	(*ws).positions.subscribe(h)
+}
+
+// OnBalance registers the balance handler, registering a handler stops the delivery to BalanceChan.
+func (ws *EtnaWS) OnBalance(h func(balance sch.TradingBalance)) {
+	(*ws).balances.subscribe(h)
+}
+
+// OnBar registers the handler of the completed bars, registering a handler stops the delivery to BarsChan.
+func (ws *EtnaWS) OnBar(h func(bar sch.Bar)) {
+	(*ws).bars.subscribe(h)
+}
+
+// setStreamer sets the streamer address, the user and the session ids used by the next connection.
+func (ws *EtnaWS) setStreamer(url string, userId int32, userSessId, streamSessId sch.SessionId) {
+	(*ws).muSess.Lock()
+	(*ws).url, (*ws).userId, (*ws).userSessId, (*ws).streamSessId = url, userId, userSessId, streamSessId
+	(*ws).muSess.Unlock()
+}
+
+// sessionId returns the user session id sent with the subscription requests.
+func (ws *EtnaWS) sessionId() sch.SessionId {
+	(*ws).muSess.Lock()
+	defer (*ws).muSess.Unlock()
+	return (*ws).userSessId
+}
+
+// streamerUrl returns the address of the streamer.
+func (ws *EtnaWS) streamerUrl() string {
+	(*ws).muSess.Lock()
+	defer (*ws).muSess.Unlock()
+	return (*ws).url
 }
 
 // createUrl generates the WebSocket connection URL with the necessary authentication parameters.
@@ -68,21 +166,24 @@ func (ws *EtnaWS) createUrl() (string, error) {
 		decL = make([]byte, base64.StdEncoding.DecodedLen(len((*ws).login)))
 		decP = make([]byte, base64.StdEncoding.DecodedLen(len((*ws).passwd)))
 	)
+	(*ws).muSess.Lock()
+	addr, userId, userSessId, streamSessId := (*ws).url, (*ws).userId, (*ws).userSessId, (*ws).streamSessId
+	(*ws).muSess.Unlock()
 
-	if (*ws).streamSessId != "" && (*ws).userSessId != "" && (*ws).userId != 0 {
+	if streamSessId != "" && userSessId != "" && userId != 0 {
 		v = url.Values{
-			"User":     {fmt.Sprintf("%d:%s", (*ws).userId, (*ws).userSessId)},
-			"Password": {string((*ws).streamSessId)}, "HttpClientType": {"WebSocket"}}
+			"User":     {fmt.Sprintf("%d:%s", userId, userSessId)},
+			"Password": {string(streamSessId)}, "HttpClientType": {"WebSocket"}}
 	} else if _, err = base64.StdEncoding.Decode(decL, (*ws).login); err != nil {
-		return "", fmt.Errorf("can't decode login %v", err)
+		return "", fmt.Errorf("can't decode login: %w", err)
 	} else if _, err = base64.StdEncoding.Decode(decP, (*ws).passwd); err != nil {
-		return "", fmt.Errorf("can't decode password %v", err)
+		return "", fmt.Errorf("can't decode password: %w", err)
 	} else {
 		v = url.Values{
 			"User": {string(bytes.Trim(decL, "\x00"))}, "Password": {string(bytes.Trim(decP, "\x00"))},
 			"HttpClientType": {"WebSocket"}}
 	}
-	return fmt.Sprintf("%s/CreateSession.txt?%s", (*ws).url, v.Encode()), nil
+	return fmt.Sprintf("%s/CreateSession.txt?%s", addr, v.Encode()), nil
 }
 
 // connect establishes a new WebSocket connection to the Etna API.
@@ -93,7 +194,10 @@ func (ws *EtnaWS) connect() error {
 		err error
 		uri string
 	)
-	if (*ws).conn != nil {
+	(*ws).mu.Lock()
+	exist := (*ws).conn != nil
+	(*ws).mu.Unlock()
+	if exist {
 		return fmt.Errorf("connection already exists")
 	} else if uri, err = (*ws).createUrl(); err != nil {
 		return err
@@ -103,7 +207,7 @@ func (ws *EtnaWS) connect() error {
 	header["Accept-Encoding"] = []string{"gzip, deflate"}
 
 	dialer := gws.Dialer{EnableCompression: true, HandshakeTimeout: 45 * time.Second}
-	if useCert, err := strconv.ParseBool(os.Getenv("ETNA_USE_LOCALCERT")); err == nil && useCert {
+	if (*ws).cfg.UseLocalCert {
 		if cfg, err := useLocalEtnaCert(); err != nil {
 			return err
 		} else {
@@ -113,8 +217,12 @@ func (ws *EtnaWS) connect() error {
 
 	conn, response, err := dialer.DialContext((*ws).ctx, uri, header)
 	if err != nil {
+		// the query of the URL contains the credentials
 		_url := strings.Split(uri, "?")[0]
-		return fmt.Errorf("failed to connect %s: resp: %+v, %+v", _url, response, err)
+		if response != nil {
+			return fmt.Errorf("failed to connect %s: status: %s: %w", _url, response.Status, err)
+		}
+		return fmt.Errorf("failed to connect %s: %w", _url, err)
 	}
 	conn.SetPongHandler((*ws).onPong)
 	if (*ws).hdlDisconnect != nil {
@@ -124,7 +232,7 @@ func (ws *EtnaWS) connect() error {
 	(*ws).conn = conn
 	(*ws).mu.Unlock()
 	(*ws).connected.Store(true)
-	(*ws).logger.Info("connected: %s [%s] %s, close: %t", (*ws).url, response.Header.Get("Server"),
+	(*ws).logger.Info("connected: %s [%s] %s, close: %t", (*ws).streamerUrl(), response.Header.Get("Server"),
 		response.Header.Get("Date"), response.Close)
 	return nil
 }
@@ -138,98 +246,21 @@ func (ws *EtnaWS) sendJson(message *sch.EtnaSubReq) error {
 	if buf, err = gjson.Marshal(*message); err != nil {
 		return fmt.Errorf("can't marshal %+v, %+v", *message, err)
 	}
-	(*ws).reqChan <- buf
-	return nil
+	return (*ws).queue(buf)
 }
 
-// Subscribe sends a subscription request for a specific topic and keys.
-// It checks for an existing connection and prevents duplicate subscriptions.
-func (ws *EtnaWS) Subscribe(topic string, keys string) error {
-	(*ws).mu.Lock()
-	if (*ws).conn == nil {
-		(*ws).mu.Unlock()
-		return fmt.Errorf("not connected")
-	}
-	(*ws).mu.Unlock()
-	var (
-		exist bool
-		subs  []sch.EtnaSubReq
-	)
-	(*ws).muSub.Lock()
-	if subs, exist = (*ws).subsciptions[topic]; !exist {
-		subs = make([]sch.EtnaSubReq, 0, 1)
-		(*ws).subsciptions[topic] = subs
+// closeChans closes the output channels on Close.
+func (ws *EtnaWS) closeChans() {
+	close((*ws).QuotesChan)
+	close((*ws).BarsChan)
+	close((*ws).BalanceChan)
+	close((*ws).PositionsChan)
+	close((*ws).OrdersChan)
+	if (*ws).state != nil {
+		close((*ws).QuoteUpdatesChan)
+		close((*ws).OrderUpdatesChan)
+		close((*ws).PositionUpdatesChan)
 	}
-
-	exist = false
-	for i := 0; i < len(subs); i++ {
-		if subs[i].Keys == keys {
-			exist = true
-			break
-		}
-	}
-	(*ws).muSub.Unlock()
-	if !exist {
-		sub := sch.EtnaSubReq{
-			Cmd: "Subscribe.txt", SessionId: (*ws).userSessId, Keys: keys, Topic: topic, HttpClientType: "WebSocket"}
-		if err := (*ws).sendJson(&sub); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// Unsubscribe sends an unsubscription request for a specific topic and keys.
-// It checks for an existing connection and the presence of the subscription before sending the unsubscribe command.
-func (ws *EtnaWS) Unsubscribe(topic string, keys string) error {
-	(*ws).mu.Lock()
-	if (*ws).conn == nil {
-		(*ws).mu.Unlock()
-		return fmt.Errorf("not connected")
-	}
-	(*ws).mu.Unlock()
-	var (
-		exist bool
-		subs  []sch.EtnaSubReq
-	)
-	(*ws).muSub.Lock()
-	defer (*ws).muSub.Unlock()
-	if subs, exist = (*ws).subsciptions[topic]; !exist {
-		return fmt.Errorf("subscription type is absent: %s, %s", topic, keys)
-	}
-	for i := 0; i < len(subs); i++ {
-		if subs[i].Keys == keys {
-			sub := subs[i]
-			sub.Cmd = "Unsubscribe.txt"
-			if err := (*ws).sendJson(&sub); err != nil {
-				return err
-			}
-			subs = append(subs[:i], subs[i+1:]...)
-			break
-		}
-	}
-	return nil
-}
-
-func (ws *EtnaWS) resubscribe() error {
-	var err error
-
-	(*ws).muSub.Lock()
-	defer (*ws).muSub.Unlock()
-	if len((*ws).subsciptions) == 0 {
-		return nil
-	}
-	for topic, subs := range (*ws).subsciptions {
-		for sIdx := 0; sIdx < len(subs); sIdx++ {
-			subs[sIdx].SessionId = (*ws).userSessId
-			if err = (*ws).sendJson(&subs[sIdx]); err != nil {
-				return fmt.Errorf("can't resubscribe: %s %+v, %+v", topic, subs[sIdx], err)
-			} else {
-				(*ws).logger.Debug("resubscribed %s %+v", topic, subs[sIdx])
-			}
-		}
-	}
-	return nil
 }
 
 // onMessage processes incoming WebSocket messages based on the topic.
@@ -237,23 +268,22 @@ func (ws *EtnaWS) resubscribe() error {
 // and sends it to the appropriate channel.
 func (ws *EtnaWS) onMessage(topic string, dec *gjson.Decoder) error {
 	var (
-		err      error
-		quote    sch.EtnaQuote
-		bar      sch.Bar
-		balance  sch.TradingBalance
-		position sch.Position
-		order    sch.Order
-		sub      sch.EtnaSubReq
+		err     error
+		bar     sch.Bar
+		balance sch.TradingBalance
+		sub     sch.EtnaSubReq
+		state   etnaState
 	)
+	if (*ws).state != nil {
+		state = *(*ws).state
+	}
 	switch topic {
 	case sch.WSTopicQuote:
-		sBuf := map[string]string{}
-		if err = dec.Decode(&sBuf); err != nil {
-			return fmt.Errorf("quote decoding fault %+v", err)
-		} else if err = quote.Parse(sBuf); err != nil {
+		quote, err := decodeUpdate(dec, state.quotes)
+		if err != nil {
 			return fmt.Errorf("quote decoding fault %+v", err)
 		}
-		(*ws).QuotesChan <- quote
+		(*ws).quotes.push((*ws).ctx, quote)
 	case sch.WSTopicCandle:
 		sBuf := map[string]string{}
 		if err = dec.Decode(&sBuf); err != nil {
@@ -261,70 +291,86 @@ func (ws *EtnaWS) onMessage(topic string, dec *gjson.Decoder) error {
 		} else if err = bar.Parse(sBuf); err != nil {
 			return fmt.Errorf("bar decoding fault %+v", err)
 		} else if bar.IsCompleted {
-			(*ws).BarsChan <- bar
+			(*ws).bars.push((*ws).ctx, bar)
 		}
 	case sch.WSTopicOrder:
-		sBuf := map[string]string{}
-		if err = dec.Decode(&sBuf); err != nil {
-			return fmt.Errorf("order decoding fault %+v", err)
-		} else if err = order.Parse(sBuf); err != nil {
+		order, err := decodeUpdate(dec, state.orders)
+		if err != nil {
 			return fmt.Errorf("order decoding fault %+v", err)
+		} else if state.orders != nil && isOrderFinal(order.Value) {
+			state.orders.forget(strconv.FormatUint(order.Value.Id, 10))
 		}
-		(*ws).OrdersChan <- order
+		(*ws).orders.push((*ws).ctx, order)
 	case sch.WSTopicBalance:
 		if err = dec.Decode(&balance); err != nil {
 			return fmt.Errorf("balance decoding fault %+v", err)
 		} else if err = balance.Parse(); err != nil {
 			return fmt.Errorf("balance parsing fault %+v", err)
 		}
-		(*ws).BalanceChan <- balance
+		(*ws).balances.push((*ws).ctx, balance)
 	case sch.WSTopicPosition:
-		sBuf := map[string]string{}
-		if err = dec.Decode(&sBuf); err != nil {
-			return fmt.Errorf("position decoding fault %+v", err)
-		} else if err = position.Parse(sBuf); err != nil {
+		position, err := decodeUpdate(dec, state.positions)
+		if err != nil {
 			return fmt.Errorf("position decoding fault %+v", err)
 		}
-		(*ws).PositionsChan <- position
+		(*ws).positions.push((*ws).ctx, position)
 	case sch.WSCmdPing:
-		(*ws).reqChan <- sch.WSPongMsg
+		(*ws).heartbeat()
+		// the pong is dropped rather than blocking the receiver on the full queue
+		select {
+		case (*ws).reqChan <- sch.WSPongMsg:
+		default:
+			(*ws).logger.Error("pong is dropped, the request queue is full: %s", (*ws).name)
+		}
 	case sch.WSCmdSub:
 		if err = dec.Decode(&sub); err != nil {
 			return fmt.Errorf("subscription decoding fault %+v", err)
 		}
-		(*ws).muSub.Lock()
-		if subs, exist := (*ws).subsciptions[sub.Topic]; exist {
-			subs = append(subs, sub)
-			(*ws).logger.Info("Subscribed %s: %s [%s]", sub.Topic, sub.Keys, sub.SessionId)
+		logAttrs((*ws).logger, slog.LevelInfo, "Subscribed", slog.String("client", (*ws).name),
+			slog.String("topic", sub.Topic), slog.String("keys", sub.Keys), sessionAttr(string(sub.SessionId)))
+		for _, key := range strings.Split(sub.Keys, ",") {
+			(*ws).acks.confirm(ackSub, sub.Topic, key)
 		}
-		(*ws).muSub.Unlock()
+		(*ws).reportSubscriptions(sub.Topic)
 	case sch.WSCmdUnsub:
 		if err = dec.Decode(&sub); err != nil {
 			return fmt.Errorf("unsubscription decoding fault %+v", err)
 		}
-		(*ws).muSub.Lock()
-		if _, exist := (*ws).subsciptions[sub.Topic]; exist {
-			(*ws).logger.Info("Unsubscribed %s: %s [%s]", sub.Topic, sub.Keys, sub.SessionId)
+		logAttrs((*ws).logger, slog.LevelInfo, "Unsubscribed", slog.String("client", (*ws).name),
+			slog.String("topic", sub.Topic), slog.String("keys", sub.Keys), sessionAttr(string(sub.SessionId)))
+		for _, key := range strings.Split(sub.Keys, ",") {
+			(*ws).acks.confirm(ackUnsub, sub.Topic, key)
 		}
-		(*ws).muSub.Unlock()
+		(*ws).reportSubscriptions(sub.Topic)
 	case sch.WSCmdCreate:
 		msg := map[string]string{}
 		if err = dec.Decode(&msg); err != nil {
 			return fmt.Errorf("CreateSession decoding fault %+v", err)
 		}
+		(*ws).muSess.Lock()
 		(*ws).userSessId = sch.SessionId(msg["SessionId"])
-		(*ws).loggedIn.Store(true)
+		(*ws).muSess.Unlock()
+		(*ws).setLoggedIn(true)
 		if err = (*ws).resubscribe(); err != nil {
 			return err
 		}
 		(*ws).hdlConnect((*ws).name)
-		(*ws).logger.Info("Websocket session created: %s", msg["SessionId"])
+		logAttrs((*ws).logger, slog.LevelInfo, "Websocket session created", slog.String("client", (*ws).name),
+			sessionAttr(msg["SessionId"]))
 	default:
 		return fmt.Errorf("wrong message %s", topic)
 	}
 	return nil
 }
 
+// reportSubscriptions passes the number of the topic's subscriptions to the instrumentation.
+func (ws *EtnaWS) reportSubscriptions(topic string) {
+	(*ws).muSub.Lock()
+	count := len((*ws).subsciptions[topic])
+	(*ws).muSub.Unlock()
+	(*ws).instr.SubscriptionsChanged((*ws).name, topic, count)
+}
+
 // getTopic returns the message topic.
 func getEtnaTopic(data []byte) (string, error) {
 	var (
@@ -339,42 +385,6 @@ func getEtnaTopic(data []byte) (string, error) {
 	return topic, nil
 }
 
-// goPing is a goroutine that periodically sends WebSocket ping messages to the server
-// to maintain the connection. It checks the last received message timestamp and initiates
-// a disconnect and reconnect if the silent period exceeds a defined threshold.
-func (ws *EtnaWS) goPing() {
-	defer (*ws).logger.Info("pinger finished")
-
-	done := (*ws).ctx.Done()
-	ticker := time.NewTicker(time.Second).C
-	for now := range ticker {
-		if !(*ws).connected.Load() {
-			break
-		}
-		select {
-		case <-done:
-			return
-		default:
-			lastTs := (*ws).lastMsgTs.Load()
-			if now.Unix()-lastTs > WSMaxSilentPeriod {
-				(*ws).mu.Lock()
-				if (*ws).conn == nil {
-					(*ws).mu.Unlock()
-					continue
-				}
-				err := (*(*ws).conn).WriteControl(gws.PingMessage, []byte(strconv.FormatInt(now.Unix(), 10)),
-					now.Add(500*time.Millisecond))
-				(*ws).mu.Unlock()
-				if err != nil {
-					(*ws).connected.Store(false)
-					(*ws).logger.Error("ping fault: %v", err)
-				}
-
-			}
-		}
-	}
-}
-
 // etnaMsgType extracts the message type from a raw WebSocket message byte slice.
 func etnaMsgType(data []byte, isCmd bool) (string, error) {
 	end := 15
@@ -0,0 +1,47 @@
+package goetna
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestEtnaWSPing(t *testing.T) {
+	tests := map[string]struct {
+		queued int // the requests in the queue before the ping
+		pong   bool
+	}{
+		"queued":     {pong: true},
+		"queue_full": {queued: 100},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			ws := newOfflineEtnaWS(t, true)
+			for i := 0; i < tc.queued; i++ {
+				(*ws).reqChan <- []byte("{}")
+			}
+			res := make(chan error)
+			go func() {
+				res <- ws.onMessage(sch.WSCmdPing, gjson.NewDecoder(strings.NewReader(`{"Cmd":"Ping"}`)))
+			}()
+			select {
+			case err := <-res:
+				if err != nil {
+					(*t).Fatal(err)
+				}
+			case <-time.After(time.Second):
+				(*t).Fatal("the receiver is blocked")
+			}
+			var pong bool
+			for len((*ws).reqChan) > 0 {
+				pong = string(<-(*ws).reqChan) == string(sch.WSPongMsg)
+			}
+			if pong != tc.pong {
+				(*t).Errorf("wrong pong: %t", pong)
+			}
+		})
+	}
+}
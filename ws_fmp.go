@@ -3,6 +3,7 @@ package goetna
 import (
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	gjson "github.com/goccy/go-json"
@@ -10,31 +11,52 @@ import (
 	sch "github.com/long-js/goetna/schema"
 )
 
-// NewFmpWS creates the instance of FmpWS
-func NewFmpWS(name string, fmpKey string, logger Logger, hdlConn ConnHandler, hdlDisconn DisconnHandler) *FmpWS {
+// NewFmpWS creates the instance of FmpWS connecting to WSUrlPubFMP of the configuration.
+func NewFmpWS(cfg *EtnaConfig, name string, fmpKey string, logger Logger, hdlConn ConnHandler,
+	hdlDisconn DisconnHandler) (*FmpWS, error) {
+	if err := checkConfig(cfg); err != nil {
+		return nil, err
+	} else if (*cfg).WSUrlPubFMP == "" {
+		return nil, fmt.Errorf("config: WSUrlPubFMP is absent")
+	}
 	ws := FmpWS{
-		WSClient:     NewWSClient(name, logger, hdlConn, hdlDisconn),
-		fmpKey:       fmpKey,
-		subsciptions: make(map[string]struct{}),
-		QuotesChan:   make(chan sch.FmpQuote, 1000),
+		WSClient:   NewWSClient(cfg, name, logger, hdlConn, hdlDisconn),
+		fmpKey:     fmpKey,
+		QuotesChan: make(chan sch.FmpQuote, 1000),
 	}
+	ws.quotes = newStream(sch.WSTopicQuote, 1000, func(q sch.FmpQuote) string { return q.Symbol })
+	ws.quotes.channel = chanAdapter(ws.QuotesChan)
+	ws.addStream(sch.WSTopicQuote, ws.quotes)
 	ws.SetConnectFunc(ws.connect)
 	ws.SetTopicFunc(getFmpEvent)
 	ws.SetMessageHandler(ws.onMessage)
-	return &ws
+	ws.unsubscribeFn = ws.unsubscribeAll
+	ws.closeChansFn = func() { close(ws.QuotesChan) }
+	return &ws, nil
 }
 
 type FmpWS struct {
 	WSClient
-	fmpKey       string
-	subsciptions map[string]struct{}
-	QuotesChan   chan sch.FmpQuote
+	fmpKey     string
+	resub      []string // the keys of the previous connection, they're subscribed again after the login
+	muResub    sync.Mutex
+	quotes     *stream[sch.FmpQuote]
+	QuotesChan chan sch.FmpQuote
+}
+
+// OnQuote registers the trade quote handler, registering a handler stops the delivery to QuotesChan.
+func (ws *FmpWS) OnQuote(h func(quote sch.FmpQuote)) {
+	(*ws).quotes.subscribe(h)
 }
 
 // connect establishes a new WebSocket connection to the FMP API.
-// It sets headers, dials the server and updates the connection status.
+// It sets headers, dials the server and updates the connection status. The subscriptions of the previous
+// connection are restored after the login, see resubscribe.
 func (ws *FmpWS) connect() error {
-	if (*ws).conn != nil {
+	(*ws).mu.Lock()
+	exist := (*ws).conn != nil
+	(*ws).mu.Unlock()
+	if exist {
 		return fmt.Errorf("connection already exists")
 	}
 	header := make(http.Header)
@@ -42,13 +64,17 @@ func (ws *FmpWS) connect() error {
 	header["Accept-Encoding"] = []string{"gzip, deflate"}
 
 	dialer := gws.Dialer{EnableCompression: true, HandshakeTimeout: 45 * time.Second}
-	if conn, response, err := dialer.DialContext((*ws).ctx, DefaultConfig.WSUrlPubFMP, header); err != nil {
-		return fmt.Errorf("failed to connect, status: %s, %+v", (*response).Status, err)
+	if conn, response, err := dialer.DialContext((*ws).ctx, (*ws).cfg.WSUrlPubFMP, header); err != nil {
+		if response != nil {
+			return fmt.Errorf("failed to connect, status: %s: %w", response.Status, err)
+		}
+		return fmt.Errorf("failed to connect: %w", err)
 	} else {
 		conn.SetPongHandler((*ws).onPong)
 		if (*ws).hdlDisconnect != nil {
 			conn.SetCloseHandler((*ws).hdlDisconnect)
 		}
+		(*ws).resetSubscriptions()
 		(*ws).mu.Lock()
 		(*ws).conn = conn
 		(*ws).mu.Unlock()
@@ -65,48 +91,108 @@ func (ws *FmpWS) connect() error {
 
 // sendJson marshals a sch.Subscription struct into JSON and sends it as a binary WebSocket message.
 func (ws *FmpWS) sendJson(message *sch.FmpReq) error {
-	if buf, err := gjson.Marshal(*message); err != nil {
+	buf, err := gjson.Marshal(*message)
+	if err != nil {
 		return fmt.Errorf("can't marshal %+v, %+v", *message, err)
-	} else {
-		(*ws).reqChan <- buf
 	}
-	return nil
+	return (*ws).queue(buf)
 }
 
 // Subscribe sends a subscription request for a specific topic and keys.
 // It checks for an existing connection and prevents duplicate subscriptions.
 func (ws *FmpWS) Subscribe(key string) error {
+	_, err := (*ws).SubscribeAck(key)
+	return err
+}
+
+// SubscribeAck sends the subscription request of the ticker and returns the future of the acknowledgement.
+// The future fails with ErrSubscriptionRejected if the server responds with a non-200 status
+// and with ErrAckTimeout if it doesn't respond in time, see SetAckTimeout.
+func (ws *FmpWS) SubscribeAck(key string) (*SubAck, error) {
 	(*ws).mu.Lock()
 	if (*ws).conn == nil {
 		(*ws).mu.Unlock()
-		return fmt.Errorf("not connected")
+		return nil, fmt.Errorf("not connected")
 	}
 	(*ws).mu.Unlock()
-	if _, exist := (*ws).subsciptions[key]; !exist {
-		m := sch.FmpReq{Event: "subscribe", Data: map[string]string{"ticker": key}}
-		if err := (*ws).sendJson(&m); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("already subscribed %s", key)
+	if (*ws).acks.subscribed(sch.WSTopicQuote, key) {
+		return nil, fmt.Errorf("already subscribed %s", key)
 	}
-	return nil
+	return (*ws).request(ackSub, key)
 }
 
 // Unsubscribe sends an unsubscription request for a specific topic and keys.
 // It checks for an existing connection and the presence of the subscription before sending the unsubscribe command.
 func (ws *FmpWS) Unsubscribe(key string) error {
+	_, err := (*ws).UnsubscribeAck(key)
+	return err
+}
+
+// UnsubscribeAck sends the unsubscription request of the ticker and returns the future of the acknowledgement.
+func (ws *FmpWS) UnsubscribeAck(key string) (*SubAck, error) {
+	(*ws).mu.Lock()
 	if (*ws).conn == nil {
-		return fmt.Errorf("not connected")
+		(*ws).mu.Unlock()
+		return nil, fmt.Errorf("not connected")
 	}
-	if _, exist := (*ws).subsciptions[key]; !exist {
-		return fmt.Errorf("subscription is absent: %s", key)
-	} else {
-		m := sch.FmpReq{Event: "unsubscribe", Data: map[string]string{"ticker": key}}
-		if err := (*ws).sendJson(&m); err != nil {
+	(*ws).mu.Unlock()
+	if !(*ws).acks.subscribed(sch.WSTopicQuote, key) {
+		return nil, fmt.Errorf("subscription is absent: %s", key)
+	}
+	return (*ws).request(ackUnsub, key)
+}
+
+// unsubscribeAll sends the unsubscription requests of all tickers.
+func (ws *FmpWS) unsubscribeAll() error {
+	for _, key := range (*ws).acks.confirmedKeys()[sch.WSTopicQuote] {
+		if _, err := (*ws).request(ackUnsub, key); err != nil {
 			return err
 		}
-		delete((*ws).subsciptions, key)
+	}
+	return nil
+}
+
+// request sends the (un)subscription request of the ticker and returns the future of its acknowledgement.
+func (ws *FmpWS) request(op ackOp, key string) (*SubAck, error) {
+	ack := (*ws).acks.track(op, sch.WSTopicQuote, []string{key})
+	return ack, (*ws).send(op, key)
+}
+
+// send sends the (un)subscription request of the ticker and starts the waiting for its acknowledgement.
+func (ws *FmpWS) send(op ackOp, key string) error {
+	keys := []string{key}
+	if err := (*ws).sendJson(&sch.FmpReq{Event: op.String(), Data: map[string]string{"ticker": key}}); err != nil {
+		(*ws).acks.abort(op, sch.WSTopicQuote, keys, err)
+		return err
+	}
+	(*ws).acks.sent(op, sch.WSTopicQuote, keys)
+	return nil
+}
+
+// resetSubscriptions starts the tracking of the new connection: the confirmed and the requested tickers
+// of the previous one are pending until they're subscribed again after the login.
+func (ws *FmpWS) resetSubscriptions() {
+	keys := (*ws).acks.confirmedKeys()[sch.WSTopicQuote]
+	keys = append(keys, (*ws).acks.pendingKeys(ackSub)[sch.WSTopicQuote]...)
+	(*ws).acks.reset(func(string, string) bool { return true })
+	(*ws).muResub.Lock()
+	(*ws).resub = keys
+	(*ws).muResub.Unlock()
+}
+
+// resubscribe sends the subscription requests of the previous connection's tickers.
+func (ws *FmpWS) resubscribe() error {
+	(*ws).muResub.Lock()
+	keys := (*ws).resub
+	(*ws).resub = nil
+	(*ws).muResub.Unlock()
+	for _, key := range keys {
+		if err := (*ws).send(ackSub, key); err != nil {
+			return fmt.Errorf("can't resubscribe: %s, %w", key, err)
+		}
+	}
+	if len(keys) > 0 {
+		(*ws).logger.Debug("resubscribed %d keys", len(keys))
 	}
 	return nil
 }
@@ -125,29 +211,39 @@ func (ws *FmpWS) onMessage(topic string, dec *gjson.Decoder) error {
 			return fmt.Errorf("decoding fault, %+v", err)
 		}
 		if quote.Last != 0. && quote.Type == "T" {
-			(*ws).QuotesChan <- quote
+			(*ws).quotes.push((*ws).ctx, quote)
 		}
 	case sch.WSTopicEvent:
 		if err := dec.Decode(&resp); err != nil {
 			return fmt.Errorf("FMP event decoding fault %+v", err)
 		} else if resp.Event != sch.WSEvtHB && resp.Status != 200 {
 			(*ws).logger.Error("FMP: %d %s", resp.Status, resp.Message)
-			if resp.Event == sch.WSEvtLogin {
-				(*ws).loggedIn.Store(false)
+			// the rejection doesn't name the ticker, so all sent requests of the operation fail, including
+			// the ones, which would be accepted. Their keys are neither confirmed nor pending afterwards,
+			// so the caller may request them again.
+			switch resp.Event {
+			case sch.WSEvtLogin:
+				(*ws).setLoggedIn(false)
+			case sch.WSEvtSub:
+				(*ws).acks.reject(ackSub, sch.WSTopicQuote, "", int(resp.Status), resp.Message)
+			case sch.WSEvtUnsub:
+				(*ws).acks.reject(ackUnsub, sch.WSTopicQuote, "", int(resp.Status), resp.Message)
 			}
 			return nil
 		}
 
 		switch resp.Event {
 		case sch.WSEvtHB:
+			(*ws).heartbeat()
 			(*ws).logger.Debug("HB: %d", resp.Timestamp)
 		case sch.WSEvtSub:
 			if len(resp.Message) < 15 {
 				return fmt.Errorf("FMP wrong response message %s", resp.Message)
 			} else {
 				key := resp.Message[14:]
-				(*ws).subsciptions[key] = struct{}{}
+				(*ws).acks.confirm(ackSub, sch.WSTopicQuote, key)
 				(*ws).logger.Info("Subscribed: %d %s", resp.Status, key)
+				(*ws).reportSubscriptions()
 			}
 		case sch.WSEvtUnsub:
 			if len(resp.Message) < 19 {
@@ -155,20 +251,23 @@ func (ws *FmpWS) onMessage(topic string, dec *gjson.Decoder) error {
 			}
 
 			key := resp.Message[18:]
-			if _, exist := (*ws).subsciptions[key]; !exist {
-				return fmt.Errorf("subscription doesn't exist: %s, %s", key, resp.Message)
-			} else {
-				delete((*ws).subsciptions, key)
-				(*ws).logger.Info("Unsubscribed: %d %s", resp.Status, key)
-			}
+			(*ws).acks.confirm(ackUnsub, sch.WSTopicQuote, key)
+			(*ws).logger.Info("Unsubscribed: %d %s", resp.Status, key)
+			(*ws).reportSubscriptions()
 		case sch.WSEvtLogin:
-			(*ws).loggedIn.Store(true)
+			(*ws).setLoggedIn(true)
 			(*ws).logger.Info("Logged in: %d %s", resp.Status, resp.Message)
+			return (*ws).resubscribe()
 		}
 	}
 	return nil
 }
 
+// reportSubscriptions passes the number of the confirmed subscriptions to the instrumentation.
+func (ws *FmpWS) reportSubscriptions() {
+	(*ws).instr.SubscriptionsChanged((*ws).name, sch.WSTopicQuote, len((*ws).acks.confirmedKeys()[sch.WSTopicQuote]))
+}
+
 func getFmpEvent(data []byte) (string, error) {
 	if len(data) < 10 || data[0] != 123 {
 		return "", fmt.Errorf("wrong FMP data: %s", data)
@@ -4,38 +4,26 @@ package goetna
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 	"time"
-
-	"github.com/long-js/goetna/schema"
 )
 
 func createEtnaWS(private bool) *EtnaWS {
 	var (
-		sessType     schema.WSSessionType
-		stream       schema.Streamer
-		streamSessId schema.SessionId
+		ws  *EtnaWS
+		err error
 	)
-	l, p := loadCreds()
-
-	if resp, err := (*rest).GetStreamers(ctx, false); err != nil {
-		panic(err)
-	} else if private {
-		stream = resp.DataAddresses[0]
-	} else {
-		stream = resp.QuoteAddresses[0]
-	}
-	stream.Url, _ = strings.CutSuffix(stream.Url, ":443")
 
+	manager := NewStreamManager(rest, ColouredLogger("WSData"))
 	if private {
-		sessType = schema.WSSessData
+		ws, err = manager.NewDataWS("TestEtnaWS", onConnect, onDisconnect)
 	} else {
-		sessType = schema.WSSessQuote
+		ws, err = manager.NewQuoteWS("TestEtnaWS", onConnect, onDisconnect)
+	}
+	if err != nil {
+		panic(err)
 	}
-	streamSessId, _ = (*rest).RecoverStreamerSession(ctx, sessType)
-	return NewEtnaWS("TestEtnaWS", stream.Url, l, p, stream.SessionId, streamSessId,
-		ColouredLogger("WSData"), onConnect, onDisconnect)
+	return ws
 }
 
 func onConnect(name string) {
@@ -64,9 +52,9 @@ func TestEtnaWsQuotes(t *testing.T) {
 
 	if err := (*ws).Start(); err != nil {
 		(*t).Error(err)
-	} else if err = (*ws).Subscribe(schema.WSTopicQuote, "230226"); err != nil { // 3803 AAPL demo, 230226 AAPL prod
+	} else if err = (*ws).SubscribeQuotes("230226"); err != nil { // 3803 AAPL demo, 230226 AAPL prod
 		(*t).Error(err)
-		// } else if err = (*ws).Subscribe(schema.WSTopicCandle, "AAPL|NGS|USD:1m"); err != nil { // 3803 AAPL demo, 230226 AAPL prod
+		// } else if err = (*ws).SubscribeCandles("AAPL|NGS|USD", "1m"); err != nil {
 		// 	(*t).Error(err)
 	}
 
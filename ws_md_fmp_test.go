@@ -16,7 +16,11 @@ func createFmpWS() *FmpWS {
 		fmpKey = resp.FMPKey
 	}
 
-	return NewFmpWS("TestFmpWS", fmpKey, ColouredLogger("WSFmp"), onConnect, onDisconnect)
+	ws, err := NewFmpWS(testCfg, "TestFmpWS", fmpKey, ColouredLogger("WSFmp"), onConnect, onDisconnect)
+	if err != nil {
+		panic(err)
+	}
+	return ws
 }
 
 func TestFmpWsStart(t *testing.T) {
@@ -48,6 +52,21 @@ func TestFmpWsQuotes(t *testing.T) {
 	}
 }
 
+func TestFmpWsSubscribeAck(t *testing.T) {
+	(*t).Skip()
+	ws := createFmpWS()
+
+	if err := (*ws).Start(); err != nil {
+		(*t).Fatal(err)
+	}
+	if ack, err := (*ws).SubscribeAck("aapl"); err != nil {
+		(*t).Error(err)
+	} else if err = ack.Wait(ctx); err != nil {
+		(*t).Error(err)
+	}
+	(*t).Logf("confirmed: %v, pending: %v\n", (*ws).ConfirmedSubscriptions(), (*ws).PendingSubscriptions())
+}
+
 func TestEtnaWsReconnect(t *testing.T) {
 	(*t).Skip()
 	// TODO
@@ -0,0 +1,141 @@
+package goetna
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+// FieldSet is the set of the struct field names changed by the update.
+type FieldSet []string
+
+// Has reports whether the field is changed.
+func (fs FieldSet) Has(name string) bool {
+	for _, f := range fs {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Update is the full state of the entity after merging the partial WebSocket message into the previous state.
+type Update[T any] struct {
+	Value   T
+	Changed FieldSet
+}
+
+// parser is the pointer to the schema struct, which can be patched with the message values.
+type parser[T any] interface {
+	*T
+	Parse(values map[string]string) error
+}
+
+// stateCache keeps the last full state of the entities by the message key.
+type stateCache[T any, PT parser[T]] struct {
+	keyField string // the message field with the entity key
+	mu       sync.Mutex
+	items    map[string]T
+}
+
+func newStateCache[T any, PT parser[T]](keyField string) *stateCache[T, PT] {
+	return &stateCache[T, PT]{keyField: keyField, items: make(map[string]T)}
+}
+
+// merge applies the message values to the last state of the entity. The message without a key
+// is parsed as is, all its non-zero fields are reported as changed.
+func (c *stateCache[T, PT]) merge(values map[string]string) (Update[T], error) {
+	var prev, next T
+
+	key := values[(*c).keyField]
+	(*c).mu.Lock()
+	defer (*c).mu.Unlock()
+	if key != "" {
+		prev = (*c).items[key]
+	}
+	next = prev
+	if err := PT(&next).Parse(values); err != nil {
+		return Update[T]{}, err
+	}
+	if key != "" {
+		(*c).items[key] = next
+	}
+	return Update[T]{Value: next, Changed: changedFields(prev, next)}, nil
+}
+
+// forget drops the state of the entity.
+func (c *stateCache[T, PT]) forget(key string) {
+	(*c).mu.Lock()
+	delete((*c).items, key)
+	(*c).mu.Unlock()
+}
+
+// decodeUpdate decodes the message and merges it into the state if the cache isn't nil.
+func decodeUpdate[T any, PT parser[T]](dec *gjson.Decoder, cache *stateCache[T, PT]) (Update[T], error) {
+	var upd Update[T]
+
+	values := map[string]string{}
+	if err := dec.Decode(&values); err != nil {
+		return upd, err
+	} else if cache != nil {
+		return cache.merge(values)
+	}
+	err := PT(&upd.Value).Parse(values)
+	return upd, err
+}
+
+// updateChanAdapter delivers the updates to the updates channel if it's created by EnableStateMerge,
+// otherwise the values are delivered to the plain channel.
+func updateChanAdapter[T any](plain *chan T, updates *chan Update[T]) chanDelivery[Update[T]] {
+	return func(ctx context.Context, upd Update[T], policy OverflowPolicy) bool {
+		if *updates != nil {
+			return chanAdapter(*updates)(ctx, upd, policy)
+		}
+		return chanAdapter(*plain)(ctx, upd.Value, policy)
+	}
+}
+
+// changedFields returns the names of the struct fields, which differ in the values.
+func changedFields[T any](prev, next T) FieldSet {
+	var res FieldSet
+
+	vPrev, vNext := reflect.ValueOf(prev), reflect.ValueOf(next)
+	if vNext.Kind() != reflect.Struct {
+		return res
+	}
+	for i := 0; i < vNext.NumField(); i++ {
+		if !vNext.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(vPrev.Field(i).Interface(), vNext.Field(i).Interface()) {
+			res = append(res, vNext.Type().Field(i).Name)
+		}
+	}
+	return res
+}
+
+// etnaState keeps the merged state of the EtnaWS entities, see EtnaWS.EnableStateMerge.
+type etnaState struct {
+	quotes    *stateCache[sch.EtnaQuote, *sch.EtnaQuote]
+	orders    *stateCache[sch.Order, *sch.Order]
+	positions *stateCache[sch.Position, *sch.Position]
+}
+
+// EnableStateMerge makes EtnaWS keep the last full state of the quotes (by the security key), orders and
+// positions (by Id). Every message is applied as a patch to that state and the merged struct is delivered
+// with the set of changed fields through QuoteUpdatesChan, OrderUpdatesChan and PositionUpdatesChan
+// instead of QuotesChan, OrdersChan and PositionsChan, or to the OnQuoteUpdate, OnOrderUpdate
+// and OnPositionUpdate handlers. It must be called before Start.
+func (ws *EtnaWS) EnableStateMerge() {
+	(*ws).state = &etnaState{
+		quotes:    newStateCache[sch.EtnaQuote]("Key"),
+		orders:    newStateCache[sch.Order]("Id"),
+		positions: newStateCache[sch.Position]("Id"),
+	}
+	(*ws).QuoteUpdatesChan = make(chan Update[sch.EtnaQuote], cap((*ws).QuotesChan))
+	(*ws).OrderUpdatesChan = make(chan Update[sch.Order], cap((*ws).OrdersChan))
+	(*ws).PositionUpdatesChan = make(chan Update[sch.Position], cap((*ws).PositionsChan))
+}
@@ -0,0 +1,80 @@
+package goetna
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+func TestStateCacheMerge(t *testing.T) {
+	tests := map[string]struct {
+		msgs    []string
+		expect  sch.Order // the result of the last message
+		changed string    // the sorted changed fields of the last message
+	}{
+		"patch": {
+			msgs: []string{`{"Id":"1","Status":"New","Quantity":"10","Price":"100"}`,
+				`{"Id":"1","Status":"PartiallyFilled","ExecutedQuantity":"4"}`},
+			expect:  sch.Order{Id: 1, Status: "PartiallyFilled", Quantity: 10, Price: 100, ExecutedQuantity: 4},
+			changed: "ExecutedQuantity,Status"},
+		"other_key": {
+			msgs:    []string{`{"Id":"1","Status":"New","Quantity":"10"}`, `{"Id":"2","Status":"New"}`},
+			expect:  sch.Order{Id: 2, Status: "New"},
+			changed: "Id,Status"},
+		"no_key": {
+			msgs:    []string{`{"Status":"New","Quantity":"10"}`, `{"Status":"New"}`},
+			expect:  sch.Order{Status: "New"},
+			changed: "Status"},
+		"repeated": {
+			msgs:   []string{`{"Id":"1","Status":"New"}`, `{"Id":"1","Status":"New"}`},
+			expect: sch.Order{Id: 1, Status: "New"}},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			var (
+				err error
+				upd Update[sch.Order]
+			)
+			cache := newStateCache[sch.Order]("Id")
+			for _, msg := range tc.msgs {
+				if upd, err = decodeUpdate(gjson.NewDecoder(strings.NewReader(msg)), cache); err != nil {
+					(*t).Fatal(err)
+				}
+			}
+			changed := slices.Clone(upd.Changed)
+			slices.Sort(changed)
+			if upd.Value != tc.expect {
+				(*t).Errorf("wrong value: %+v", upd.Value)
+			} else if strings.Join(changed, ",") != tc.changed {
+				(*t).Errorf("wrong changed fields: %v", changed)
+			}
+		})
+	}
+}
+
+func TestEtnaWSStateMerge(t *testing.T) {
+	ws := newOfflineEtnaWS(t, true)
+	ws.EnableStateMerge()
+	for _, msg := range []string{`{"Id":"1","Status":"New","Quantity":"10"}`, `{"Id":"1","Status":"Filled"}`,
+		`{"Id":"1","Status":"New"}`} {
+		if err := ws.onMessage(sch.WSTopicOrder, gjson.NewDecoder(strings.NewReader(msg))); err != nil {
+			(*t).Fatal(err)
+		}
+	}
+
+	// the final order is forgotten, so the next message of the same id starts the new state
+	var res []float64
+	for len((*ws).orders.queue) > 0 {
+		upd := (<-(*ws).orders.queue).v
+		res = append(res, upd.Value.Quantity)
+		if !upd.Changed.Has("Status") {
+			(*t).Errorf("the status isn't changed: %v", upd.Changed)
+		}
+	}
+	if !slices.Equal(res, []float64{10, 10, 0}) {
+		(*t).Errorf("wrong quantities: %v", res)
+	}
+}
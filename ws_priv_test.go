@@ -4,8 +4,6 @@ package goetna
 
 import (
 	"testing"
-
-	"github.com/long-js/goetna/schema"
 )
 
 func TestWsSubscription(t *testing.T) {
@@ -15,12 +13,12 @@ func TestWsSubscription(t *testing.T) {
 	if err := (*ws).Start(); err != nil {
 		(*t).Error(err)
 	}
-	accId := "421" // 292
-	if err := (*ws).Subscribe(schema.WSTopicOrder, accId); err != nil {
+	accId := uint32(421) // 292
+	if err := (*ws).SubscribeOrders(accId); err != nil {
 		(*t).Error(err)
-	} else if err = (*ws).Subscribe(schema.WSTopicBalance, accId); err != nil {
+	} else if err = (*ws).SubscribeBalance(accId); err != nil {
 		(*t).Error(err)
-	} else if err = (*ws).Subscribe(schema.WSTopicPosition, accId); err != nil {
+	} else if err = (*ws).SubscribePositions(accId); err != nil {
 		(*t).Error(err)
 	}
 
@@ -0,0 +1,273 @@
+package goetna
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	sch "github.com/long-js/goetna/schema"
+)
+
+// DefaultSubscriptionBatch is the default maximum number of the keys in one subscription request.
+const DefaultSubscriptionBatch = 100
+
+// subRefs are the reference counts of the subscribed keys.
+type subRefs map[string]int
+
+// keys returns the sorted keys.
+func (r subRefs) keys() []string {
+	res := make([]string, 0, len(r))
+	for key := range r {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// SetSubscriptionBatch sets the maximum number of the keys sent in one Subscribe.txt or Unsubscribe.txt request.
+func (ws *EtnaWS) SetSubscriptionBatch(size int) {
+	if size < 1 {
+		size = 1
+	}
+	(*ws).muSub.Lock()
+	(*ws).subBatch = size
+	(*ws).muSub.Unlock()
+}
+
+// SubscribeQuotes subscribes to the quotes of the securities by their ids, e.g. "230226".
+func (ws *EtnaWS) SubscribeQuotes(secIds ...string) error {
+	return (*ws).subscribe(sch.WSTopicQuote, secIds)
+}
+
+// UnsubscribeQuotes releases the quote subscriptions made by SubscribeQuotes.
+func (ws *EtnaWS) UnsubscribeQuotes(secIds ...string) error {
+	return (*ws).unsubscribe(sch.WSTopicQuote, secIds)
+}
+
+// SubscribeCandles subscribes to the bars of the instrument "SYMBOL|EXCHANGE|CURRENCY", e.g. "AAPL|NGS|USD",
+// the timeframe is one of sch.VALID_TFS, e.g. "1m".
+func (ws *EtnaWS) SubscribeCandles(instrument, tf string) error {
+	key, err := candleKey(instrument, tf)
+	if err != nil {
+		return err
+	}
+	return (*ws).subscribe(sch.WSTopicCandle, []string{key})
+}
+
+// UnsubscribeCandles releases the bar subscription made by SubscribeCandles.
+func (ws *EtnaWS) UnsubscribeCandles(instrument, tf string) error {
+	key, err := candleKey(instrument, tf)
+	if err != nil {
+		return err
+	}
+	return (*ws).unsubscribe(sch.WSTopicCandle, []string{key})
+}
+
+// SubscribeOrders subscribes to the order updates of the account.
+func (ws *EtnaWS) SubscribeOrders(accId uint32) error {
+	return (*ws).subscribe(sch.WSTopicOrder, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// UnsubscribeOrders releases the subscription made by SubscribeOrders.
+func (ws *EtnaWS) UnsubscribeOrders(accId uint32) error {
+	return (*ws).unsubscribe(sch.WSTopicOrder, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// SubscribePositions subscribes to the position updates of the account.
+func (ws *EtnaWS) SubscribePositions(accId uint32) error {
+	return (*ws).subscribe(sch.WSTopicPosition, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// UnsubscribePositions releases the subscription made by SubscribePositions.
+func (ws *EtnaWS) UnsubscribePositions(accId uint32) error {
+	return (*ws).unsubscribe(sch.WSTopicPosition, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// SubscribeBalance subscribes to the trading balance updates of the account.
+func (ws *EtnaWS) SubscribeBalance(accId uint32) error {
+	return (*ws).subscribe(sch.WSTopicBalance, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// UnsubscribeBalance releases the subscription made by SubscribeBalance.
+func (ws *EtnaWS) UnsubscribeBalance(accId uint32) error {
+	return (*ws).unsubscribe(sch.WSTopicBalance, []string{strconv.FormatUint(uint64(accId), 10)})
+}
+
+// Subscribe subscribes to the comma separated keys of the topic, e.g. sch.WSTopicQuote.
+// The typed methods (SubscribeQuotes etc.) are preferred.
+func (ws *EtnaWS) Subscribe(topic string, keys string) error {
+	return (*ws).subscribe(topic, strings.Split(keys, ","))
+}
+
+// Unsubscribe releases the comma separated keys of the topic subscribed by Subscribe.
+func (ws *EtnaWS) Unsubscribe(topic string, keys string) error {
+	return (*ws).unsubscribe(topic, strings.Split(keys, ","))
+}
+
+// SubscribeAck subscribes to the keys of the topic and returns the future of the acknowledgement,
+// e.g. the security ids of sch.WSTopicQuote, the account id of sch.WSTopicOrder or "AAPL|NGS|USD:1m"
+// of sch.WSTopicCandle. The keys already confirmed are resolved immediately. The future fails with ErrAckTimeout
+// if the server doesn't echo the request in time, see SetAckTimeout, the time counts from the sending, so
+// the request postponed till the session is created doesn't expire.
+func (ws *EtnaWS) SubscribeAck(topic string, keys ...string) (*SubAck, error) {
+	return (*ws).subscribeAck(topic, keys)
+}
+
+// UnsubscribeAck releases the keys of the topic and returns the future of the acknowledgement,
+// the keys still used by the other subscriptions are resolved immediately.
+func (ws *EtnaWS) UnsubscribeAck(topic string, keys ...string) (*SubAck, error) {
+	return (*ws).unsubscribeAck(topic, keys)
+}
+
+// Subscriptions returns the subscribed keys by topic, both confirmed and pending,
+// see ConfirmedSubscriptions and PendingSubscriptions.
+func (ws *EtnaWS) Subscriptions() map[string][]string {
+	(*ws).muSub.Lock()
+	defer (*ws).muSub.Unlock()
+	res := make(map[string][]string, len((*ws).subsciptions))
+	for topic, refs := range (*ws).subsciptions {
+		res[topic] = refs.keys()
+	}
+	return res
+}
+
+func (ws *EtnaWS) subscribe(topic string, keys []string) error {
+	_, err := (*ws).subscribeAck(topic, keys)
+	return err
+}
+
+func (ws *EtnaWS) unsubscribe(topic string, keys []string) error {
+	_, err := (*ws).unsubscribeAck(topic, keys)
+	return err
+}
+
+// subscribeAck increments the reference counts of the keys and requests the keys, which weren't subscribed yet.
+// Without the session the requests are postponed till the session is created, the same way
+// the subscriptions are restored after the reconnection.
+func (ws *EtnaWS) subscribeAck(topic string, keys []string) (*SubAck, error) {
+	(*ws).muSub.Lock()
+	defer (*ws).muSub.Unlock()
+
+	var added, tracked []string
+	refs, exist := (*ws).subsciptions[topic]
+	if !exist {
+		refs = subRefs{}
+		(*ws).subsciptions[topic] = refs
+	}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		tracked = append(tracked, key)
+		// the key, which request has failed, is requested again
+		if refs[key]++; refs[key] == 1 || (*ws).acks.unacked(topic, key) && !slices.Contains(added, key) {
+			added = append(added, key)
+		}
+	}
+	if len(refs) == 0 {
+		delete((*ws).subsciptions, topic)
+	}
+	ack := (*ws).acks.track(ackSub, topic, tracked)
+	if !(*ws).IsOperational() {
+		return ack, nil
+	}
+	return ack, (*ws).sendBatched(sch.WSCmdSub, topic, added)
+}
+
+// unsubscribeAck decrements the reference counts of the keys and unsubscribes from the keys,
+// which aren't used anymore.
+func (ws *EtnaWS) unsubscribeAck(topic string, keys []string) (*SubAck, error) {
+	(*ws).muSub.Lock()
+	defer (*ws).muSub.Unlock()
+
+	var (
+		removed []string
+		errs    []error
+	)
+	refs := (*ws).subsciptions[topic]
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		} else if refs[key] == 0 {
+			errs = append(errs, fmt.Errorf("subscription is absent: %s %s", topic, key))
+			continue
+		}
+		if refs[key]--; refs[key] == 0 {
+			delete(refs, key)
+			removed = append(removed, key)
+		}
+	}
+	if len(refs) == 0 {
+		delete((*ws).subsciptions, topic)
+	}
+	if !(*ws).IsOperational() {
+		// the postponed subscriptions aren't requested anymore
+		(*ws).acks.abort(ackSub, topic, removed, ErrSubscriptionCanceled)
+		return (*ws).acks.track(ackUnsub, topic, nil), errors.Join(errs...)
+	}
+	ack := (*ws).acks.track(ackUnsub, topic, removed)
+	errs = append(errs, (*ws).sendBatched(sch.WSCmdUnsub, topic, removed))
+	return ack, errors.Join(errs...)
+}
+
+// resubscribe requests all subscriptions in the new session.
+func (ws *EtnaWS) resubscribe() error {
+	(*ws).muSub.Lock()
+	defer (*ws).muSub.Unlock()
+
+	(*ws).acks.reset(func(topic, key string) bool { return (*ws).subsciptions[topic][key] > 0 })
+	for topic, refs := range (*ws).subsciptions {
+		if err := (*ws).sendBatched(sch.WSCmdSub, topic, refs.keys()); err != nil {
+			return fmt.Errorf("can't resubscribe: %s, %w", topic, err)
+		}
+		(*ws).logger.Debug("resubscribed %s %d keys", topic, len(refs))
+	}
+	return nil
+}
+
+// unsubscribeAll sends the unsubscription requests of all subscriptions and forgets them.
+func (ws *EtnaWS) unsubscribeAll() error {
+	(*ws).muSub.Lock()
+	defer (*ws).muSub.Unlock()
+	for topic, refs := range (*ws).subsciptions {
+		if err := (*ws).sendBatched(sch.WSCmdUnsub, topic, refs.keys()); err != nil {
+			return err
+		}
+		delete((*ws).subsciptions, topic)
+	}
+	return nil
+}
+
+// sendBatched sends the command with the keys split into the requests of subBatch keys and starts
+// the waiting for their acknowledgements. The caller must hold the muSub lock.
+func (ws *EtnaWS) sendBatched(cmd, topic string, keys []string) error {
+	op := ackSub
+	if cmd == sch.WSCmdUnsub {
+		op = ackUnsub
+	}
+	for len(keys) > 0 {
+		n := min((*ws).subBatch, len(keys))
+		req := sch.EtnaSubReq{Cmd: cmd, SessionId: (*ws).sessionId(), Keys: strings.Join(keys[:n], ","), Topic: topic,
+			HttpClientType: "WebSocket"}
+		if err := (*ws).sendJson(&req); err != nil {
+			(*ws).acks.abort(op, topic, keys, err)
+			return err
+		}
+		(*ws).acks.sent(op, topic, keys[:n])
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// candleKey returns the subscription key of the bars.
+func candleKey(instrument, tf string) (string, error) {
+	if _, exist := sch.VALID_TFS[tf]; !exist {
+		return "", fmt.Errorf("wrong timeframe: %s", tf)
+	} else if strings.Count(instrument, "|") != 2 {
+		return "", fmt.Errorf("wrong instrument %q, SYMBOL|EXCHANGE|CURRENCY is expected", instrument)
+	}
+	return instrument + ":" + tf, nil
+}
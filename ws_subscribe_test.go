@@ -0,0 +1,198 @@
+package goetna
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gjson "github.com/goccy/go-json"
+	sch "github.com/long-js/goetna/schema"
+)
+
+// newOfflineEtnaWS creates the client without the connection, the sent requests are left in reqChan.
+func newOfflineEtnaWS(t *testing.T, operational bool) *EtnaWS {
+	cfg, err := NewConfig(ProfileDemo)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	ws, err := NewEtnaWS(cfg, "test", "", nil, nil, "", "", NopLogger{}, nil, nil)
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	(*ws).connected.Store(operational)
+	(*ws).loggedIn.Store(operational)
+	return ws
+}
+
+// sentRequests returns the "Cmd Keys" of the queued requests.
+func sentRequests(t *testing.T, ws *EtnaWS) []string {
+	var res []string
+	for len((*ws).reqChan) > 0 {
+		var req sch.EtnaSubReq
+		if err := gjson.Unmarshal(<-(*ws).reqChan, &req); err != nil {
+			(*t).Fatal(err)
+		}
+		res = append(res, req.Cmd+" "+req.Keys)
+	}
+	return res
+}
+
+func TestEtnaWSSubscriptionRefs(t *testing.T) {
+	type step struct {
+		sub       bool
+		keys      []string
+		sent      []string
+		expectErr bool
+	}
+	tests := map[string]struct {
+		steps  []step
+		remain []string
+	}{
+		"shared_key": {
+			steps: []step{
+				{sub: true, keys: []string{"1", "2"}, sent: []string{"Subscribe.txt 1,2"}},
+				{sub: true, keys: []string{"2", "3"}, sent: []string{"Subscribe.txt 3"}},
+				{keys: []string{"2"}},
+				{keys: []string{"1", "2"}, sent: []string{"Unsubscribe.txt 1,2"}},
+			},
+			remain: []string{"3"}},
+		"absent_key": {
+			steps: []step{
+				{sub: true, keys: []string{"1"}, sent: []string{"Subscribe.txt 1"}},
+				{keys: []string{"1", "9"}, sent: []string{"Unsubscribe.txt 1"}, expectErr: true},
+			}},
+		"blank_keys": {
+			steps: []step{
+				{sub: true, keys: []string{" 1", "", "1 "}, sent: []string{"Subscribe.txt 1"}},
+			},
+			remain: []string{"1"}},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			ws := newOfflineEtnaWS(t, true)
+			for i, s := range tc.steps {
+				var err error
+				if s.sub {
+					_, err = ws.SubscribeAck(sch.WSTopicQuote, s.keys...)
+				} else {
+					_, err = ws.UnsubscribeAck(sch.WSTopicQuote, s.keys...)
+				}
+				if (err != nil) != s.expectErr {
+					(*t).Fatalf("step %d: wrong error: %v", i, err)
+				} else if sent := sentRequests(t, ws); strings.Join(sent, ";") != strings.Join(s.sent, ";") {
+					(*t).Fatalf("step %d: wrong requests: %v", i, sent)
+				}
+			}
+			if remain := ws.Subscriptions()[sch.WSTopicQuote]; strings.Join(remain, ",") != strings.Join(tc.remain, ",") {
+				(*t).Errorf("wrong subscriptions: %v", remain)
+			}
+		})
+	}
+}
+
+func TestEtnaWSSubscriptionBatch(t *testing.T) {
+	tests := map[string]struct {
+		batch  int
+		keys   []string
+		expect []string
+	}{
+		"single":  {batch: 2, keys: []string{"1"}, expect: []string{"Subscribe.txt 1"}},
+		"exact":   {batch: 2, keys: []string{"1", "2", "3", "4"}, expect: []string{"Subscribe.txt 1,2", "Subscribe.txt 3,4"}},
+		"rest":    {batch: 2, keys: []string{"1", "2", "3"}, expect: []string{"Subscribe.txt 1,2", "Subscribe.txt 3"}},
+		"min_one": {batch: 0, keys: []string{"1", "2"}, expect: []string{"Subscribe.txt 1", "Subscribe.txt 2"}},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			ws := newOfflineEtnaWS(t, true)
+			ws.SetSubscriptionBatch(tc.batch)
+			if err := ws.SubscribeQuotes(tc.keys...); err != nil {
+				(*t).Fatal(err)
+			}
+			if sent := sentRequests(t, ws); strings.Join(sent, ";") != strings.Join(tc.expect, ";") {
+				(*t).Errorf("wrong requests: %v", sent)
+			}
+		})
+	}
+}
+
+func TestEtnaWSPostponedSubscription(t *testing.T) {
+	ws := newOfflineEtnaWS(t, false)
+	ws.SetSubscriptionBatch(2)
+	if err := ws.SubscribeQuotes("3", "1", "2"); err != nil {
+		(*t).Fatal(err)
+	}
+	ack, err := ws.SubscribeAck(sch.WSTopicOrder, "7")
+	if err != nil {
+		(*t).Fatal(err)
+	}
+	if err = ws.UnsubscribeOrders(7); err != nil {
+		(*t).Fatal(err)
+	} else if sent := sentRequests(t, ws); len(sent) != 0 {
+		(*t).Fatalf("the requests are sent offline: %v", sent)
+	} else if !errors.Is(ack.Err(), ErrSubscriptionCanceled) {
+		(*t).Errorf("the released subscription isn't canceled: %v", ack.Err())
+	}
+
+	(*ws).connected.Store(true)
+	(*ws).loggedIn.Store(true)
+	if err = ws.resubscribe(); err != nil {
+		(*t).Fatal(err)
+	}
+	expect := "Subscribe.txt 1,2;Subscribe.txt 3"
+	if sent := sentRequests(t, ws); strings.Join(sent, ";") != expect {
+		(*t).Errorf("wrong requests: %v", sent)
+	} else if pending := ws.PendingSubscriptions()[sch.WSTopicQuote]; len(pending) != 3 {
+		(*t).Errorf("wrong pending subscriptions: %v", pending)
+	}
+}
+
+func TestCandleKey(t *testing.T) {
+	tests := map[string]struct {
+		instrument, tf string
+		expect         string
+		expectErr      bool
+	}{
+		"valid":         {instrument: "AAPL|NGS|USD", tf: "1m", expect: "AAPL|NGS|USD:1m"},
+		"wrong_tf":      {instrument: "AAPL|NGS|USD", tf: "7m", expectErr: true},
+		"no_exchange":   {instrument: "AAPL|USD", tf: "1m", expectErr: true},
+		"no_instrument": {instrument: "", tf: "1m", expectErr: true},
+	}
+	for name, tc := range tests {
+		(*t).Run(name, func(t *testing.T) {
+			if key, err := candleKey(tc.instrument, tc.tf); (err != nil) != tc.expectErr {
+				(*t).Errorf("wrong error: %v", err)
+			} else if key != tc.expect {
+				(*t).Errorf("wrong key: %s", key)
+			}
+		})
+	}
+}
+
+func TestEtnaWSSubscribeQueueFull(t *testing.T) {
+	ws := newOfflineEtnaWS(t, true)
+	for len((*ws).reqChan) < cap((*ws).reqChan) {
+		(*ws).reqChan <- []byte("{}")
+	}
+	res := make(chan error)
+	go func() { res <- ws.SubscribeQuotes("1") }()
+
+	// the subscription waits for the sender, the lock of the subscriptions is released by Stop
+	select {
+	case err := <-res:
+		(*t).Fatalf("the request is queued: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	ws.Stop()
+	select {
+	case err := <-res:
+		if !errors.Is(err, ErrClosed) {
+			(*t).Errorf("wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		(*t).Fatal("the subscription isn't interrupted")
+	}
+	if subs := ws.PendingSubscriptions(); len(subs) != 0 {
+		(*t).Errorf("wrong pending subscriptions: %v", subs)
+	}
+}